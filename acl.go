@@ -0,0 +1,161 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+)
+
+// ErrInvalidACL is returned when a canned ACL
+// is not one of the values known to S3.
+var ErrInvalidACL = errors.New("invalid canned ACL")
+
+// cannedACLs is the set of canned ACLs accepted by S3.
+var cannedACLs = []string{
+	"private",
+	"public-read",
+	"public-read-write",
+	"aws-exec-read",
+	"authenticated-read",
+	"bucket-owner-read",
+	"bucket-owner-full-control",
+	"log-delivery-write",
+}
+
+// ValidACL returns whether or not acl
+// is the name of a canned ACL.
+func ValidACL(acl string) bool {
+	return slices.Contains(cannedACLs, acl)
+}
+
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// ACL is the access control policy of an object.
+type ACL struct {
+	XMLName xml.Name `xml:"AccessControlPolicy"`
+	Owner   Grantee  `xml:"Owner"`
+	Grants  []Grant  `xml:"AccessControlList>Grant"`
+}
+
+// Grant gives a permission to a grantee.
+type Grant struct {
+	Grantee    Grantee `xml:"Grantee"`
+	Permission string  `xml:"Permission"` // FULL_CONTROL, WRITE, WRITE_ACP, READ or READ_ACP
+}
+
+// Grantee identifies the recipient of a grant
+// or the owner of an object.
+type Grantee struct {
+	Type        string `xml:"type,attr,omitempty"` // CanonicalUser, AmazonCustomerByEmail or Group
+	ID          string `xml:"ID,omitempty"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+	Email       string `xml:"EmailAddress,omitempty"`
+	URI         string `xml:"URI,omitempty"`
+}
+
+// MarshalXML implements xml.Marshaler so that
+// the grantee type is written as an xsi:type attribute.
+func (g Grantee) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain Grantee
+	if g.Type != "" {
+		start.Attr = append(start.Attr,
+			xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: xsiNamespace},
+			xml.Attr{Name: xml.Name{Local: "xsi:type"}, Value: g.Type},
+		)
+	}
+	p := plain(g)
+	p.Type = ""
+	return e.EncodeElement(p, start)
+}
+
+func (b *Bucket) aclRequest(ctx context.Context, method, key string) (*http.Request, error) {
+	key = path.Clean(key)
+	if !fs.ValidPath(key) || key == "." {
+		return nil, badpath("s3 acl", key)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, uri(b.key, b.bkt, key)+"?acl=", nil)
+	if err != nil {
+		return nil, err
+	}
+	setUserAgent(req, b.UserAgent)
+	return req, nil
+}
+
+// subresourceError converts an unsuccessful response to a
+// request on an object sub-resource (e.g. ?acl) into an error.
+func subresourceError(op, key string, res *http.Response) error {
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		return &fs.PathError{Op: op, Path: key, Err: fs.ErrNotExist}
+	case http.StatusForbidden:
+		return &fs.PathError{Op: op, Path: key, Err: fs.ErrPermission}
+	default:
+		return responseError("s3 "+op, res)
+	}
+}
+
+// GetACL returns the access control policy of the object at key.
+func (b *Bucket) GetACL(ctx context.Context, key string) (*ACL, error) {
+	req, err := b.aclRequest(ctx, http.MethodGet, key)
+	if err != nil {
+		return nil, err
+	}
+	b.key.SignV4(req, nil)
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, subresourceError("getacl", key, res)
+	}
+	acl := new(ACL)
+	if err := xml.NewDecoder(res.Body).Decode(acl); err != nil {
+		return nil, fmt.Errorf("xml decoding response: %w", err)
+	}
+	return acl, nil
+}
+
+// PutACL replaces the access control policy of the object at key.
+func (b *Bucket) PutACL(ctx context.Context, key string, acl *ACL) error {
+	req, err := b.aclRequest(ctx, http.MethodPut, key)
+	if err != nil {
+		return err
+	}
+	policy := *acl
+	policy.XMLName = xml.Name{Space: "http://s3.amazonaws.com/doc/2006-03-01/", Local: "AccessControlPolicy"}
+	body, err := xml.Marshal(&policy)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	b.key.SignV4(req, body)
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return subresourceError("putacl", key, res)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"io/fs"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACL(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	t.Run("canned on upload", func(t *testing.T) {
+		_, err := b.Write(ctx, "acl/public.txt", []byte("public"), UploadOptions{ACL: "public-read"})
+		assert.NoError(t, err)
+
+		acl, err := b.GetACL(ctx, "acl/public.txt")
+		assert.NoError(t, err)
+		assert.Contains(t, acl.Grants, Grant{
+			Grantee:    Grantee{Type: "Group", URI: "http://acs.amazonaws.com/groups/global/AllUsers"},
+			Permission: "READ",
+		})
+	})
+
+	t.Run("put and get", func(t *testing.T) {
+		_, err := b.Write(ctx, "acl/private.txt", []byte("private"))
+		assert.NoError(t, err)
+
+		want := &ACL{
+			Owner: Grantee{ID: "owner-id"},
+			Grants: []Grant{
+				{Grantee: Grantee{Type: "CanonicalUser", ID: "owner-id"}, Permission: "FULL_CONTROL"},
+				{Grantee: Grantee{Type: "AmazonCustomerByEmail", Email: "reader@example.com"}, Permission: "READ"},
+			},
+		}
+		assert.NoError(t, b.PutACL(ctx, "acl/private.txt", want))
+
+		got, err := b.GetACL(ctx, "acl/private.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, want.Owner, got.Owner)
+		assert.Equal(t, want.Grants, got.Grants)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := b.Write(ctx, "acl/bad.txt", []byte("bad"), UploadOptions{ACL: "world-writable"})
+		assert.ErrorIs(t, err, ErrInvalidACL)
+		assert.False(t, mockServer.ObjectExists("acl/bad.txt"))
+
+		_, err = b.GetACL(ctx, "acl/missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("xsi type", func(t *testing.T) {
+		out, err := xml.Marshal(Grantee{Type: "Group", URI: "uri"})
+		assert.NoError(t, err)
+		assert.Contains(t, string(out), `xsi:type="Group"`)
+	})
+}
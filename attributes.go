@@ -0,0 +1,122 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Object attributes that can be requested from Bucket.Attributes.
+const (
+	AttrETag         = "ETag"
+	AttrChecksum     = "Checksum"
+	AttrObjectParts  = "ObjectParts"
+	AttrStorageClass = "StorageClass"
+	AttrObjectSize   = "ObjectSize"
+)
+
+// allAttributes is the set of attributes requested
+// when Bucket.Attributes is called without any.
+var allAttributes = []string{
+	AttrETag,
+	AttrChecksum,
+	AttrObjectParts,
+	AttrStorageClass,
+	AttrObjectSize,
+}
+
+// Checksum holds the additional checksums of
+// an object or part. Only the checksum algorithm
+// used when uploading the object is populated.
+type Checksum struct {
+	CRC32     string `xml:"ChecksumCRC32"`
+	CRC32C    string `xml:"ChecksumCRC32C"`
+	CRC64NVME string `xml:"ChecksumCRC64NVME"`
+	SHA1      string `xml:"ChecksumSHA1"`
+	SHA256    string `xml:"ChecksumSHA256"`
+}
+
+// ObjectPart describes a single part of a multipart object.
+type ObjectPart struct {
+	Checksum
+	PartNumber int   `xml:"PartNumber"`
+	Size       int64 `xml:"Size"`
+}
+
+// ObjectParts describes the parts of a multipart object.
+type ObjectParts struct {
+	PartsCount           int          `xml:"PartsCount"`
+	Parts                []ObjectPart `xml:"Part"`
+	IsTruncated          bool         `xml:"IsTruncated"`
+	MaxParts             int          `xml:"MaxParts"`
+	PartNumberMarker     int          `xml:"PartNumberMarker"`
+	NextPartNumberMarker int          `xml:"NextPartNumberMarker"`
+}
+
+// ObjectAttributes is the result of Bucket.Attributes.
+// Fields that were not requested are left empty.
+type ObjectAttributes struct {
+	XMLName      xml.Name     `xml:"GetObjectAttributesResponse"`
+	ETag         string       `xml:"ETag"`
+	Checksum     *Checksum    `xml:"Checksum"`
+	ObjectParts  *ObjectParts `xml:"ObjectParts"` // nil unless the object was uploaded in parts
+	StorageClass string       `xml:"StorageClass"`
+	ObjectSize   int64        `xml:"ObjectSize"`
+}
+
+// Attributes returns the attributes of the object at key
+// without downloading its contents. The which argument
+// lists the attributes to return (see AttrETag and friends);
+// if it is empty, all attributes are returned.
+func (b *Bucket) Attributes(ctx context.Context, key string, which []string) (*ObjectAttributes, error) {
+	key = path.Clean(key)
+	if !fs.ValidPath(key) || key == "." {
+		return nil, badpath("s3 attributes", key)
+	}
+	if len(which) == 0 {
+		which = allAttributes
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri(b.key, b.bkt, key)+"?attributes=", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-amz-object-attributes", strings.Join(which, ","))
+	setUserAgent(req, b.UserAgent)
+	b.key.SignV4(req, nil, "x-amz-object-attributes")
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, subresourceError("attributes", key, res)
+	}
+	attrs := new(ObjectAttributes)
+	if err := xml.NewDecoder(res.Body).Decode(attrs); err != nil {
+		return nil, fmt.Errorf("xml decoding response: %w", err)
+	}
+	// unlike other responses, the ETag is not quoted here,
+	// so quote it to make it comparable with the other APIs
+	if attrs.ETag != "" && !strings.HasPrefix(attrs.ETag, `"`) {
+		attrs.ETag = `"` + attrs.ETag + `"`
+	}
+	return attrs, nil
+}
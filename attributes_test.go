@@ -0,0 +1,81 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributes(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	t.Run("multipart", func(t *testing.T) {
+		data := make([]byte, MinPartSize+1000)
+		assert.NoError(t, b.WriteFrom(ctx, "attrs/large.bin", bytes.NewReader(data), int64(len(data))))
+
+		attrs, err := b.Attributes(ctx, "attrs/large.bin", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(data)), attrs.ObjectSize)
+		assert.Equal(t, "STANDARD", attrs.StorageClass)
+		if assert.NotNil(t, attrs.ObjectParts) {
+			assert.Equal(t, 2, attrs.ObjectParts.PartsCount)
+			assert.Equal(t, []ObjectPart{
+				{PartNumber: 1, Size: MinPartSize},
+				{PartNumber: 2, Size: 1000},
+			}, attrs.ObjectParts.Parts)
+		}
+
+		f, err := b.Open("attrs/large.bin")
+		assert.NoError(t, err)
+		assert.Equal(t, f.(*File).ETag, attrs.ETag)
+		assert.NoError(t, f.Close())
+	})
+
+	t.Run("selected", func(t *testing.T) {
+		_, err := b.Write(ctx, "attrs/small.txt", []byte("hello"))
+		assert.NoError(t, err)
+
+		attrs, err := b.Attributes(ctx, "attrs/small.txt", []string{AttrObjectSize})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), attrs.ObjectSize)
+		assert.Empty(t, attrs.ETag)
+		assert.Nil(t, attrs.ObjectParts)
+
+		gets := mockServer.GetRequestsWithMethod("GET")
+		last := gets[len(gets)-1]
+		assert.Equal(t, AttrObjectSize, last.Headers["X-Amz-Object-Attributes"])
+		assert.Contains(t, signedHeaders(last), "x-amz-object-attributes")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := b.Attributes(ctx, "attrs/missing.txt", nil)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package aws
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// StreamingTrailer is the x-amz-content-sha256 of a request signed
+// with SignV4Trailer: an aws-chunked body whose chunks are not signed,
+// followed by trailing headers.
+const StreamingTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+// chunkSize is the size of every chunk
+// of a body signed with SignV4Trailer
+// but the last one.
+const chunkSize = 64 * 1024
+
+// SignV4Trailer signs req like SignV4, but for an upload of the size
+// bytes read from body using the aws-chunked content encoding, which
+// streams the body and sends the trailing header named trailer after
+// it. The value of the trailer is the base64-encoded sum of h, which
+// is fed every byte of body, e.g. a CRC32C for x-amz-checksum-crc32c.
+// This lets S3 check the integrity of a body that is not known in
+// advance, without reading it twice.
+//
+// As with SignV4, the payload itself is not signed. The body of req
+// can only be sent once, and reading it fails with io.ErrUnexpectedEOF
+// if body ends before size bytes.
+func (s *SigningKey) SignV4Trailer(req *http.Request, body io.Reader, size int64, trailer string, h hash.Hash, signed ...string) {
+	encoding := "aws-chunked"
+	if enc := req.Header.Get("Content-Encoding"); enc != "" {
+		encoding += "," + enc
+	}
+	req.Header.Set("Content-Encoding", encoding)
+	req.Header.Set("x-amz-decoded-content-length", strconv.FormatInt(size, 10))
+	req.Header.Set("x-amz-trailer", trailer)
+	signed = append(signed[:len(signed):len(signed)], "x-amz-decoded-content-length", "x-amz-trailer")
+	s.signV4(req, StreamingTrailer, signed)
+
+	req.Body = io.NopCloser(&chunkedReader{
+		src:     body,
+		left:    size,
+		trailer: trailer,
+		h:       h,
+	})
+	req.ContentLength = chunkedLength(size, trailer, base64.StdEncoding.EncodedLen(h.Size()))
+	req.GetBody = nil
+}
+
+// chunkedLength returns the length of an aws-chunked body
+// of size bytes, followed by a trailer with a value of n bytes.
+func chunkedLength(size int64, trailer string, n int) int64 {
+	chunk := func(size int64) int64 {
+		return int64(len(strconv.FormatInt(size, 16))) + size + 4
+	}
+	out := (size / chunkSize) * chunk(chunkSize)
+	if rem := size % chunkSize; rem > 0 {
+		out += chunk(rem)
+	}
+	// the final chunk is empty, followed by the trailer
+	return out + chunk(0) + int64(len(trailer)+1+n+2)
+}
+
+// chunkedReader encodes a body as aws-chunked,
+// followed by a trailing checksum of the body.
+type chunkedReader struct {
+	src     io.Reader
+	left    int64  // bytes of src still to be encoded
+	trailer string // name of the trailing header
+	h       hash.Hash
+	chunk   []byte       // buffer for a chunk of src
+	out     bytes.Buffer // encoded bytes not yet read
+	done    bool         // set once the trailer is encoded
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for c.out.Len() == 0 {
+		if err := c.fill(); err != nil {
+			return 0, err
+		}
+	}
+	return c.out.Read(p)
+}
+
+// fill encodes the next chunk, or the trailer
+// after the last one, into c.out.
+func (c *chunkedReader) fill() error {
+	switch {
+	case c.left > 0:
+		if c.chunk == nil {
+			c.chunk = make([]byte, min(c.left, chunkSize))
+		}
+		data := c.chunk[:min(c.left, chunkSize)]
+		if _, err := io.ReadFull(c.src, data); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		c.h.Write(data)
+		c.left -= int64(len(data))
+		c.out.WriteString(strconv.FormatInt(int64(len(data)), 16))
+		c.out.WriteString("\r\n")
+		c.out.Write(data)
+		c.out.WriteString("\r\n")
+	case !c.done:
+		c.done = true
+		c.out.WriteString("0\r\n")
+		c.out.WriteString(c.trailer)
+		c.out.WriteByte(':')
+		c.out.WriteString(base64.StdEncoding.EncodeToString(c.h.Sum(nil)))
+		c.out.WriteString("\r\n\r\n")
+	default:
+		return io.EOF
+	}
+	return nil
+}
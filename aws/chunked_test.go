@@ -0,0 +1,103 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package aws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignV4Trailer(t *testing.T) {
+	key := DeriveKey("", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "s3")
+	sign := func(body []byte, size int64) *http.Request {
+		req, err := http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/object", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Content-Encoding", "gzip")
+		key.SignV4Trailer(req, bytes.NewReader(body), size, "x-amz-checksum-crc32c", crc32.New(crc32.MakeTable(crc32.Castagnoli)))
+		return req
+	}
+
+	t.Run("headers", func(t *testing.T) {
+		req := sign([]byte("hello"), 5)
+		assert.Equal(t, StreamingTrailer, req.Header.Get("x-amz-content-sha256"))
+		assert.Equal(t, "aws-chunked,gzip", req.Header.Get("Content-Encoding"))
+		assert.Equal(t, "5", req.Header.Get("x-amz-decoded-content-length"))
+		assert.Equal(t, "x-amz-checksum-crc32c", req.Header.Get("x-amz-trailer"))
+		assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-decoded-content-length;x-amz-trailer,")
+		assert.Nil(t, req.GetBody)
+	})
+
+	t.Run("small", func(t *testing.T) {
+		req := sign([]byte("hello"), 5)
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+
+		sum := binary.BigEndian.AppendUint32(nil, crc32.Checksum([]byte("hello"), crc32.MakeTable(crc32.Castagnoli)))
+		want := "5\r\nhello\r\n0\r\nx-amz-checksum-crc32c:" + base64.StdEncoding.EncodeToString(sum) + "\r\n\r\n"
+		assert.Equal(t, want, string(body))
+		assert.Equal(t, int64(len(body)), req.ContentLength)
+	})
+
+	t.Run("chunks", func(t *testing.T) {
+		for _, size := range []int{0, chunkSize, 2*chunkSize + 10} {
+			data := bytes.Repeat([]byte("abcdefgh"), size/8+2)[:size]
+			req := sign(data, int64(size))
+			body, err := io.ReadAll(req.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(body)), req.ContentLength, "size %d", size)
+
+			// decode the chunks back
+			got := []byte{}
+			r := bufio.NewReader(bytes.NewReader(body))
+			for {
+				line, err := r.ReadString('\n')
+				assert.NoError(t, err)
+				n, err := strconv.ParseInt(strings.TrimSuffix(line, "\r\n"), 16, 64)
+				assert.NoError(t, err)
+				if n == 0 {
+					break
+				}
+				chunk := make([]byte, n+2)
+				_, err = io.ReadFull(r, chunk)
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, n, int64(chunkSize))
+				got = append(got, chunk[:n]...)
+			}
+			assert.Equal(t, data, got, "size %d", size)
+
+			trailer, err := r.ReadString('\n')
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(trailer, "x-amz-checksum-crc32c:"))
+			rest, _ := io.ReadAll(r)
+			assert.Equal(t, "\r\n", string(rest))
+		}
+	})
+
+	t.Run("short body", func(t *testing.T) {
+		req := sign([]byte("hello"), 10)
+		_, err := io.ReadAll(req.Body)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
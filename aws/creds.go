@@ -223,6 +223,118 @@ func WebIdentityCreds(client *http.Client) (id, secret, region, token string, ex
 	return
 }
 
+// AssumeRoleCreds assumes the role identified by roleARN through STS, signing the
+// request with the ambient credentials (see AmbientCreds). A non-zero duration
+// sets the lifetime of the session, and an optional external ID is passed along
+// for roles whose trust policy requires one. The returned region is the region
+// of the ambient credentials.
+func AssumeRoleCreds(client *http.Client, roleARN, sessionName string, duration time.Duration, externalID ...string) (id, secret, region, token string, expiration time.Time, err error) {
+	switch {
+	case roleARN == "":
+		return "", "", "", "", time.Time{}, fmt.Errorf("role ARN not set")
+	case len(externalID) > 1:
+		return "", "", "", "", time.Time{}, fmt.Errorf("at most one external ID may be given")
+	}
+	if sessionName == "" {
+		sessionName = "default"
+	}
+
+	baseID, baseSecret, region, baseToken, err := AmbientCreds("")
+	if err != nil {
+		return "", "", "", "", time.Time{}, fmt.Errorf("can't find credentials to assume %q with: %w", roleARN, err)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	u, _ := url.Parse("https://sts.amazonaws.com/?Action=AssumeRole&Version=2011-06-15")
+	q := u.Query()
+	q.Add("RoleSessionName", sessionName)
+	q.Add("RoleArn", roleARN)
+	if duration > 0 {
+		q.Add("DurationSeconds", fmt.Sprint(int64(duration/time.Second)))
+	}
+	if len(externalID) == 1 && externalID[0] != "" {
+		q.Add("ExternalId", externalID[0])
+	}
+	u.RawQuery = q.Encode()
+
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{
+			"Accept": []string{"application/xml"},
+		},
+	}
+
+	// STS is a global service, so its requests are always signed for us-east-1
+	key := DeriveKey("", baseID, baseSecret, "us-east-1", "sts")
+	key.Token = baseToken
+	key.SignV4(req, nil)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", "", "", time.Time{}, fmt.Errorf("can't assume role %q: %w", roleARN, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", "", "", time.Time{}, fmt.Errorf("AssumeRole returned HTTP status %s", resp.Status)
+	}
+
+	var result struct {
+		Result struct {
+			Credentials struct {
+				AccessKeyID     string    `xml:"AccessKeyId"`
+				SecretAccessKey string    `xml:"SecretAccessKey"`
+				SessionToken    string    `xml:"SessionToken"`
+				Expiration      time.Time `xml:"Expiration"`
+			} `xml:"Credentials"`
+		} `xml:"AssumeRoleResult"`
+	}
+
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", "", "", "", time.Time{}, err
+	}
+
+	creds := result.Result.Credentials
+
+	id = creds.AccessKeyID
+	secret = creds.SecretAccessKey
+	token = creds.SessionToken
+	expiration = creds.Expiration
+	return
+}
+
+// AssumeRoleKey assumes the role identified by roleARN (see AssumeRoleCreds)
+// and produces a signing key for the service from the temporary credentials.
+// The key is derived using derive, unless it is nil, in which case
+// DefaultDerive is used instead.
+func AssumeRoleKey(client *http.Client, service, roleARN, sessionName string, duration time.Duration, derive DeriveFn, externalID ...string) (*SigningKey, error) {
+	if derive == nil {
+		derive = DefaultDerive
+	}
+
+	id, secret, region, token, _, err := AssumeRoleCreds(client, roleARN, sessionName, duration, externalID...)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURI := ""
+	switch service {
+	case "s3":
+		baseURI = S3EndPoint(region)
+	case "b2":
+		baseURI = B2EndPoint(region)
+	default:
+		return nil, fmt.Errorf("unknown service %s", service)
+	}
+
+	return derive(baseURI, id, secret, token, region, service)
+}
+
 // AmbientKey tries to produce a signing key
 // from the ambient filesystem, environment, etc.
 // The key is derived using derive, unless it is nil,
@@ -253,10 +365,22 @@ func AmbientKey(service, regionName string, derive DeriveFn) (*SigningKey, error
 	return derive(baseURI, id, secret, token, region, service)
 }
 
+// endpointVars are the environment variables that
+// override the S3 endpoint, in order of precedence.
+var endpointVars = []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL", "S3_ENDPOINT"}
+
 // S3EndPoint returns the endpoint of the object
-// storage service.
+// storage service. The endpoint is read from the
+// first of AWS_ENDPOINT_URL_S3, AWS_ENDPOINT_URL,
+// and S3_ENDPOINT that is set; if none is set,
+// the AWS S3 endpoint of the region is used.
 func S3EndPoint(region string) string {
-	endPoint := os.Getenv("S3_ENDPOINT")
+	var endPoint string
+	for _, env := range endpointVars {
+		if endPoint = os.Getenv(env); endPoint != "" {
+			break
+		}
+	}
 	if endPoint == "" {
 		endPoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
 	}
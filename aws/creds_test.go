@@ -140,6 +140,81 @@ func TestCreds(t *testing.T) {
 		})
 	})
 
+	t.Run("assume role", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", "BASEID")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "BASESECRET")
+		t.Setenv("AWS_REGION", "eu-west-1")
+		t.Setenv("AWS_SESSION_TOKEN", "BASETOKEN")
+		t.Setenv("HOME", t.TempDir())
+
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/xml", r.Header.Get("Accept"))
+			assert.Contains(t, r.Header.Get("Authorization"), "Credential=BASEID/")
+			assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/sts/aws4_request")
+			assert.Equal(t, "BASETOKEN", r.Header.Get("X-Amz-Security-Token"))
+
+			q := r.URL.Query()
+			assert.Equal(t, "AssumeRole", q.Get("Action"))
+			assert.Equal(t, "2011-06-15", q.Get("Version"))
+			assert.Equal(t, "arn:aws:iam::123456789012:role/test", q.Get("RoleArn"))
+			assert.Equal(t, "mysession", q.Get("RoleSessionName"))
+			assert.Equal(t, "900", q.Get("DurationSeconds"))
+			assert.Equal(t, "ext-id", q.Get("ExternalId"))
+
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`
+<AssumeRoleResponse>
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>AKID</AccessKeyId>
+      <SecretAccessKey>SECRET</SecretAccessKey>
+      <SessionToken>SESSION</SessionToken>
+      <Expiration>2025-01-02T03:04:05Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`))
+		}
+
+		withSTSServer(t, handler, func(client *http.Client) {
+			id, secret, region, token, expiration, err := AssumeRoleCreds(client,
+				"arn:aws:iam::123456789012:role/test", "mysession", 15*time.Minute, "ext-id")
+			assert.NoError(t, err)
+			assert.Equal(t, "AKID", id)
+			assert.Equal(t, "SECRET", secret)
+			assert.Equal(t, "eu-west-1", region)
+			assert.Equal(t, "SESSION", token)
+			wantTime, _ := time.Parse(time.RFC3339, "2025-01-02T03:04:05Z")
+			assert.True(t, expiration.Equal(wantTime))
+
+			key, err := AssumeRoleKey(client, "s3", "arn:aws:iam::123456789012:role/test",
+				"mysession", 15*time.Minute, nil, "ext-id")
+			assert.NoError(t, err)
+			assert.Equal(t, "AKID", key.AccessKey)
+			assert.Equal(t, "SESSION", key.Token)
+			assert.Equal(t, "eu-west-1", key.Region)
+		})
+	})
+
+	t.Run("assume role without external id", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", "BASEID")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "BASESECRET")
+		t.Setenv("AWS_REGION", "eu-west-1")
+		t.Setenv("HOME", t.TempDir())
+
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			_, ok := r.URL.Query()["ExternalId"]
+			assert.False(t, ok)
+			_, ok = r.URL.Query()["DurationSeconds"]
+			assert.False(t, ok)
+			w.WriteHeader(http.StatusForbidden)
+		}
+
+		withSTSServer(t, handler, func(client *http.Client) {
+			_, _, _, _, _, err := AssumeRoleCreds(client, "arn:aws:iam::123456789012:role/test", "", 0)
+			assert.ErrorContains(t, err, "403")
+		})
+	})
+
 	t.Run("ambient", func(t *testing.T) {
 		t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
 		t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
@@ -191,5 +266,11 @@ func TestCreds(t *testing.T) {
 		assert.Equal(t, "SECRET", key.Secret)
 		assert.Equal(t, "eu-central-1", key.Region)
 		assert.Equal(t, "", key.Token)
+
+		// Verify that the endpoint is taken from the environment
+		t.Setenv("AWS_ENDPOINT_URL_S3", "http://localhost:9000")
+		key, err = AmbientKey("s3", "eu-central-1", DefaultDerive)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost:9000", key.BaseURI)
 	})
 }
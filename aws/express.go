@@ -0,0 +1,127 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package aws
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionSlack is how long before its expiration
+// a cached session is considered stale.
+const sessionSlack = time.Minute
+
+// ExpressSessions obtains and caches session keys
+// for S3 Express One Zone directory buckets.
+//
+// Directory buckets authenticate requests using
+// short-lived session credentials returned by
+// the CreateSession API rather than the long-term
+// credentials of the caller. The zero value of
+// ExpressSessions is ready to use.
+type ExpressSessions struct {
+	// Client is the HTTP client used to call
+	// CreateSession. If it is nil, then
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	lock     sync.Mutex
+	sessions map[string]*expressSession
+}
+
+type expressSession struct {
+	key     *SigningKey
+	expires time.Time
+}
+
+// Key returns a signing key for the directory bucket
+// served at endpoint (e.g.
+// "https://bucket--usw2-az1--x-s3.s3express-usw2-az1.us-west-2.amazonaws.com"),
+// calling CreateSession with base if there is no
+// cached session or the cached session is about
+// to expire.
+//
+// The returned key carries the session token in
+// S3Session and is scoped to the "s3express" service.
+func (e *ExpressSessions) Key(base *SigningKey, endpoint string) (*SigningKey, error) {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	now := signtime()
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if s, ok := e.sessions[endpoint]; ok && now.Add(sessionSlack).Before(s.expires) {
+		return s.key, nil
+	}
+
+	key, expires, err := e.create(base, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if e.sessions == nil {
+		e.sessions = make(map[string]*expressSession)
+	}
+	e.sessions[endpoint] = &expressSession{key: key, expires: expires}
+	return key, nil
+}
+
+// create performs a CreateSession call against endpoint.
+func (e *ExpressSessions) create(base *SigningKey, endpoint string) (*SigningKey, time.Time, error) {
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint+"/?session=", nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	bc := base.credentials()
+	signer := DeriveKey(base.BaseURI, bc.AccessKey, bc.Secret, base.Region, "s3express")
+	signer.Token = bc.Token
+	signer.SignV4(req, nil)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("aws.CreateSession: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("aws.CreateSession: %s", res.Status)
+	}
+
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	}
+	if err := xml.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, time.Time{}, fmt.Errorf("aws.CreateSession: decoding response: %w", err)
+	}
+
+	creds := result.Credentials
+	if creds.SessionToken == "" {
+		return nil, time.Time{}, fmt.Errorf("aws.CreateSession: response missing session token")
+	}
+	key := DeriveKey(base.BaseURI, creds.AccessKeyID, creds.SecretAccessKey, base.Region, "s3express")
+	key.S3Session = creds.SessionToken
+	return key, creds.Expiration, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpressSessions(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.True(t, r.URL.Query().Has("session"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/s3express/aws4_request")
+		fmt.Fprintf(w, `<CreateSessionResult><Credentials>`+
+			`<SessionToken>session-token</SessionToken>`+
+			`<SecretAccessKey>session-secret</SecretAccessKey>`+
+			`<AccessKeyId>session-id</AccessKeyId>`+
+			`<Expiration>%s</Expiration>`+
+			`</Credentials></CreateSessionResult>`, fakenow.Add(5*time.Minute).UTC().Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	var sessions ExpressSessions
+	base := DeriveKey("", "id", "secret", "us-west-2", "s3")
+	key, err := sessions.Key(base, srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "session-id", key.AccessKey)
+	assert.Equal(t, "session-token", key.S3Session)
+	assert.Equal(t, "s3express", key.Service)
+
+	// the session is cached until it is about to expire
+	again, err := sessions.Key(base, srv.URL+"/")
+	assert.NoError(t, err)
+	assert.Same(t, key, again)
+	assert.Equal(t, 1, calls)
+
+	// the session token is sent and signed
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/object", nil)
+	key.SignV4(req, nil)
+	assert.Equal(t, "session-token", req.Header.Get("x-amz-s3session-token"))
+}
@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"os"
 	"strings"
 	"testing"
 
@@ -102,12 +101,21 @@ func TestMetadata(t *testing.T) {
 	})
 
 	t.Run("s3 endpoint", func(t *testing.T) {
-		os.Unsetenv("S3_ENDPOINT")
+		t.Setenv("AWS_ENDPOINT_URL_S3", "")
+		t.Setenv("AWS_ENDPOINT_URL", "")
+		t.Setenv("S3_ENDPOINT", "")
 		assert.Equal(t, "https://s3.us-west-1.amazonaws.com", S3EndPoint("us-west-1"))
 
-		os.Setenv("S3_ENDPOINT", "http://localhost:9000/")
-		defer os.Unsetenv("S3_ENDPOINT")
+		t.Setenv("S3_ENDPOINT", "http://localhost:9000/")
 		assert.Equal(t, "http://localhost:9000", S3EndPoint("ignored"))
+
+		// AWS_ENDPOINT_URL takes precedence over S3_ENDPOINT
+		t.Setenv("AWS_ENDPOINT_URL", "http://localhost:9001")
+		assert.Equal(t, "http://localhost:9001", S3EndPoint("ignored"))
+
+		// AWS_ENDPOINT_URL_S3 takes precedence over both
+		t.Setenv("AWS_ENDPOINT_URL_S3", "http://localhost:9002/")
+		assert.Equal(t, "http://localhost:9002", S3EndPoint("ignored"))
 	})
 
 	t.Run("b2 endpoint", func(t *testing.T) {
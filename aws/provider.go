@@ -0,0 +1,77 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package aws
+
+import (
+	"time"
+)
+
+// Credentials are the AWS credentials used to sign a request.
+type Credentials struct {
+	AccessKey string // AWS Access Key ID
+	Secret    string // AWS Secret key
+	Token     string // Token, if the credentials are from STS
+}
+
+// CredentialsProvider supplies the credentials of a SigningKey
+// each time a request is signed, so that rotating, SSO or refreshing
+// credentials can be used without re-deriving the key.
+//
+// Credentials is called once per signed request, possibly from many
+// goroutines at once, and cannot fail: an implementation that refreshes
+// credentials in the background should keep returning the last valid
+// credentials until the refresh succeeds.
+type CredentialsProvider interface {
+	Credentials() Credentials
+}
+
+// StaticCredentials is a CredentialsProvider
+// that always returns the same credentials.
+type StaticCredentials Credentials
+
+// Credentials implements CredentialsProvider.
+func (c StaticCredentials) Credentials() Credentials { return Credentials(c) }
+
+// NewKey returns a SigningKey that obtains its credentials from
+// provider every time a request is signed. Unlike a key returned
+// by DeriveKey, it does not expire, but it derives the signing key
+// for every request.
+func NewKey(baseURI string, provider CredentialsProvider, region, service string) *SigningKey {
+	return &SigningKey{
+		BaseURI:  baseURI,
+		Region:   region,
+		Service:  service,
+		Provider: provider,
+		Derived:  signtime().UTC(),
+	}
+}
+
+// credentials returns the credentials to sign a request with,
+// which are those held by s unless it has a Provider.
+func (s *SigningKey) credentials() Credentials {
+	if s.Provider != nil {
+		return s.Provider.Credentials()
+	}
+	return Credentials{AccessKey: s.AccessKey, Secret: s.Secret, Token: s.Token}
+}
+
+// signingKey returns the key used to sign
+// a request made at when with creds.
+func (s *SigningKey) signingKey(when time.Time, creds Credentials) []byte {
+	if s.Provider != nil {
+		return derive(creds.Secret, when, s.Region, s.Service)
+	}
+	return s.pickKey(when)
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package aws
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rotatingCreds returns the next credentials on every call.
+type rotatingCreds struct {
+	calls atomic.Int32
+	creds []Credentials
+}
+
+func (r *rotatingCreds) Credentials() Credentials {
+	n := int(r.calls.Add(1)) - 1
+	return r.creds[min(n, len(r.creds)-1)]
+}
+
+func TestCredentialsProvider(t *testing.T) {
+	creds := []Credentials{
+		{AccessKey: "AKID1", Secret: "secret1"},
+		{AccessKey: "AKID2", Secret: "secret2", Token: "token2"},
+	}
+
+	// sign builds the same request and signs it with k
+	sign := func(k *SigningKey) *http.Request {
+		req, err := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/object", nil)
+		assert.NoError(t, err)
+		k.SignV4(req, nil)
+		return req
+	}
+
+	// static returns the key DeriveKey produces for c
+	static := func(c Credentials) *SigningKey {
+		k := DeriveKey("", c.AccessKey, c.Secret, "us-east-1", "s3")
+		k.Token = c.Token
+		return k
+	}
+
+	t.Run("SignV4", func(t *testing.T) {
+		p := &rotatingCreds{creds: creds}
+		k := NewKey("", p, "us-east-1", "s3")
+		for _, c := range creds {
+			req := sign(k)
+			auth := req.Header.Get("Authorization")
+			assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+c.AccessKey+"/"), auth)
+			assert.Equal(t, c.Token, req.Header.Get("X-Amz-Security-Token"))
+			assert.Equal(t, sign(static(c)).Header.Get("Authorization"), auth)
+		}
+		assert.Equal(t, int32(2), p.calls.Load())
+	})
+
+	t.Run("SignURL", func(t *testing.T) {
+		k := NewKey("", &rotatingCreds{creds: creds}, "us-east-1", "s3")
+		const uri = "https://bucket.s3.amazonaws.com/object"
+		for _, c := range creds {
+			got, err := k.SignURL(uri, time.Hour)
+			assert.NoError(t, err)
+			want, err := static(c).SignURL(uri, time.Hour)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		}
+	})
+
+	t.Run("InRegion", func(t *testing.T) {
+		p := &rotatingCreds{creds: creds[1:]}
+		k := NewKey("", p, "us-east-1", "s3").InRegion("eu-west-1")
+		assert.Same(t, p, k.Provider)
+		assert.Contains(t, sign(k).Header.Get("Authorization"), "AKID2/20150830/eu-west-1/s3/")
+	})
+
+	t.Run("static", func(t *testing.T) {
+		c := creds[1]
+		k := NewKey("", StaticCredentials(c), "us-east-1", "s3")
+		assert.Equal(t, sign(static(c)).Header.Get("Authorization"), sign(k).Header.Get("Authorization"))
+		assert.Nil(t, static(c).Provider)
+	})
+}
@@ -26,6 +26,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -51,12 +52,18 @@ const (
 // note: this list needs to be alphabetically sorted
 var sigheaders = []string{
 	"host",
+	"x-amz-acl",
 	"x-amz-content-sha256",
 	"x-amz-copy-source",
 	"x-amz-copy-source-if-match",
 	"x-amz-copy-source-range",
 	"x-amz-date",
+	"x-amz-s3session-token",
 	"x-amz-security-token",
+	"x-amz-server-side-encryption",
+	"x-amz-server-side-encryption-aws-kms-key-id",
+	"x-amz-server-side-encryption-bucket-key-enabled",
+	"x-amz-server-side-encryption-context",
 }
 
 func (s *SigningKey) toscope(dst *bytes.Buffer, now time.Time) {
@@ -83,9 +90,33 @@ func (s *SigningKey) tosign(dst *bytes.Buffer, now time.Time, reqhash string) {
 	dst.WriteString(reqhash)
 }
 
-// write the 'canonical request' into dst
+// signedHeaders returns the names of the headers of req
+// that are signed, in sorted order: those of sigheaders
+// and extra that are present in the request.
+func signedHeaders(req *http.Request, extra []string) []string {
+	names := sigheaders
+	if len(extra) > 0 {
+		names = slices.Clone(sigheaders)
+		for _, h := range extra {
+			if h = strings.ToLower(h); !slices.Contains(names, h) {
+				names = append(names, h)
+			}
+		}
+		slices.Sort(names)
+	}
+	out := make([]string, 0, len(names))
+	for _, h := range names {
+		if req.Header.Get(h) != "" {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// write the 'canonical request' into dst, signing
+// the headers of extra along with those of sigheaders
 // see https://docs.aws.amazon.com/general/latest/gr/sigv4-create-canonical-request.html
-func canonical(dst *bytes.Buffer, req *http.Request) {
+func canonical(dst *bytes.Buffer, req *http.Request, extra ...string) {
 	dst.WriteString(req.Method)
 	dst.WriteByte('\n')
 
@@ -108,12 +139,9 @@ func canonical(dst *bytes.Buffer, req *http.Request) {
 	}
 
 	var bodyhash string
-	for i := range sigheaders {
-		h := sigheaders[i]
+	signed := signedHeaders(req, extra)
+	for _, h := range signed {
 		hdr := req.Header.Get(h)
-		if hdr == "" {
-			continue
-		}
 		if h == "x-amz-content-sha256" {
 			bodyhash = hdr
 		}
@@ -125,15 +153,7 @@ func canonical(dst *bytes.Buffer, req *http.Request) {
 	dst.WriteByte('\n')
 
 	// signed headers string
-	for i := range sigheaders {
-		if req.Header.Get(sigheaders[i]) == "" {
-			continue
-		}
-		if i != 0 {
-			dst.WriteByte(';')
-		}
-		dst.WriteString(sigheaders[i])
-	}
+	dst.WriteString(strings.Join(signed, ";"))
 	dst.WriteByte('\n')
 	// the value to be hashed here
 	// needs to match the header,
@@ -158,69 +178,79 @@ func canonical(dst *bytes.Buffer, req *http.Request) {
 // Query parameters with no arguments must include
 // a bare trailing '=' so that they are canonicalized
 // correctly.
-func (s *SigningKey) SignV4(req *http.Request, body []byte) {
+//
+// The headers named in signed are signed along with
+// the fixed set of headers used by S3 requests; any
+// other x-amz-* header of req must be named there,
+// as S3 requires all of them to be signed.
+func (s *SigningKey) SignV4(req *http.Request, body []byte, signed ...string) {
+	if body == nil {
+		s.signV4(req, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", signed)
+	} else {
+		// note: could also just calculate the sha256 of the payload,
+		// but really we should just use HTTPS, which provides
+		// better integrity guarantees anyway...
+		s.signV4(req, "UNSIGNED-PAYLOAD", signed)
+	}
+
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	} else {
+		req.Body = nil
+	}
+}
+
+// signV4 populates the Authorization header of req,
+// using payload as the x-amz-content-sha256 header.
+func (s *SigningKey) signV4(req *http.Request, payload string, signed []string) {
 	var buf bytes.Buffer
 
 	now := signtime().UTC()
+	creds := s.credentials()
 	req.Header.Set("x-amz-date", now.Format(longFormat))
-	if s.Token != "" {
-		req.Header.Set("x-amz-security-token", s.Token)
+	if creds.Token != "" {
+		req.Header.Set("x-amz-security-token", creds.Token)
+	}
+	if s.S3Session != "" {
+		req.Header.Set("x-amz-s3session-token", s.S3Session)
 	}
 
 	// canonical() uses the value we set here
 	// as the hash of the body
-	if body == nil {
-		req.Header.Set("x-amz-content-sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
-	} else {
-		// note: could also just calculate the sha256 of the payload,
-		// but really we should just use HTTPS, which provides
-		// better integrity guarantees anyway...
-		req.Header.Set("x-amz-content-sha256", "UNSIGNED-PAYLOAD")
-	}
+	req.Header.Set("x-amz-content-sha256", payload)
 
 	// compute signature
 	// and stick it into hexbuf
 	var hexbuf [2 * sha256.Size]byte
-	canonical(&buf, req)
+	canonical(&buf, req, signed...)
 	h := sha256.Sum256(buf.Bytes())
 	buf.Reset()
 	s.tosign(&buf, now, hex.EncodeToString(h[:]))
-	s.sign(buf.Bytes(), hexbuf[:], now)
+	s.sign(buf.Bytes(), hexbuf[:], now, creds)
 
 	buf.Reset()
 	buf.WriteString("AWS4-HMAC-SHA256 Credential=")
-	buf.WriteString(s.AccessKey)
+	buf.WriteString(creds.AccessKey)
 	buf.WriteByte('/')
 	s.toscope(&buf, now)
 	buf.WriteString(", SignedHeaders=")
-	for i := range sigheaders {
-		if req.Header.Get(sigheaders[i]) == "" {
-			continue
-		}
-		if i != 0 {
-			buf.WriteByte(';')
-		}
-		buf.WriteString(sigheaders[i])
-	}
+	buf.WriteString(strings.Join(signedHeaders(req, signed), ";"))
 	buf.WriteString(", Signature=")
 	buf.Write(hexbuf[:])
 
 	req.Header.Set("Authorization", buf.String())
-
-	if body != nil {
-		req.Body = io.NopCloser(bytes.NewReader(body))
-		req.ContentLength = int64(len(body))
-		req.GetBody = func() (io.ReadCloser, error) {
-			return io.NopCloser(bytes.NewReader(body)), nil
-		}
-	} else {
-		req.Body = nil
-	}
 }
 
 // SignURL signs an HTTP request by creating
 // a presigned URL string. The returned string
 // is valid for only the specified duration.
+// If the key carries a session token, it is
+// included as the signed X-Amz-Security-Token
+// parameter, as temporary credentials require.
 func (s *SigningKey) SignURL(uri string, validfor time.Duration) (string, error) {
 	now := signtime().UTC()
 	u, err := url.Parse(uri)
@@ -228,8 +258,9 @@ func (s *SigningKey) SignURL(uri string, validfor time.Duration) (string, error)
 		return "", err
 	}
 	host := u.Host
+	creds := s.credentials()
 	var scope bytes.Buffer
-	scope.WriteString(s.AccessKey)
+	scope.WriteString(creds.AccessKey)
 	scope.WriteByte('/')
 	s.toscope(&scope, now)
 
@@ -239,12 +270,10 @@ func (s *SigningKey) SignURL(uri string, validfor time.Duration) (string, error)
 	q.Add("X-Amz-Date", now.Format(longFormat))
 	q.Add("X-Amz-Expires", strconv.FormatInt(int64(validfor/time.Second), 10))
 	q.Add("X-Amz-SignedHeaders", "host")
-	if s.Token != "" {
-		q.Add("X-Amz-Security-Token", s.Token)
+	if creds.Token != "" {
+		q.Add("X-Amz-Security-Token", creds.Token)
 	}
 
-	// TODO: if we have a SecurityToken, add it
-
 	// build 'canonical request'
 	// method
 	var dst bytes.Buffer
@@ -267,7 +296,7 @@ func (s *SigningKey) SignURL(uri string, validfor time.Duration) (string, error)
 	dst.Reset()
 	reqhash := hex.EncodeToString(h[:])
 	s.tosign(&dst, now, reqhash)
-	s.sign(dst.Bytes(), hexbuf[:], now)
+	s.sign(dst.Bytes(), hexbuf[:], now, creds)
 	query := q.Encode() + "&X-Amz-Signature=" + string(hexbuf[:])
 	// we're overriding the request scheme here to HTTPS,
 	// since we're only signing the host header
@@ -279,7 +308,7 @@ func (s *SigningKey) SignURL(uri string, validfor time.Duration) (string, error)
 //
 // Keys expire daily, as they use the current
 // time in the derivation, so they must be refreshed
-// regularly.
+// regularly, unless they have a Provider.
 type SigningKey struct {
 	BaseURI   string    // S3 base URI (empty is default AWS S3)
 	Region    string    // AWS Region
@@ -287,8 +316,16 @@ type SigningKey struct {
 	AccessKey string    // AWS Access Key ID
 	Secret    string    // AWS Secret key
 	Token     string    // Token, if key is from STS
+	S3Session string    // S3 Express session token, if key is from CreateSession
 	Derived   time.Time // time token was derived
 
+	// Provider, if set, supplies the credentials
+	// each time a request is signed, in which case
+	// AccessKey, Secret and Token are ignored.
+	// Otherwise, those fields are used as static
+	// credentials, as set up by DeriveKey.
+	Provider CredentialsProvider
+
 	// we only store the clamped secret
 	// so that this object can't be repurposed
 	// for other services / regions
@@ -339,7 +376,9 @@ func (s *SigningKey) InRegion(region string) *SigningKey {
 		AccessKey: s.AccessKey,
 		Secret:    s.Secret,
 		Token:     s.Token,
+		S3Session: s.S3Session,
 		Derived:   s.Derived,
+		Provider:  s.Provider,
 		clamped0:  derive(s.Secret, s.Derived, region, s.Service),
 		clamped1:  derive(s.Secret, s.Derived.Add(24*time.Hour), region, s.Service),
 	}
@@ -353,9 +392,9 @@ func (s *SigningKey) pickKey(when time.Time) []byte {
 	return s.clamped0
 }
 
-func (s *SigningKey) sign(src, dst []byte, when time.Time) {
+func (s *SigningKey) sign(src, dst []byte, when time.Time, creds Credentials) {
 	var tmp [sha256.Size]byte
-	m := hmac.New(sha256.New, s.pickKey(when))
+	m := hmac.New(sha256.New, s.signingKey(when, creds))
 	m.Write(src)
 	hex.Encode(dst, m.Sum(tmp[:0]))
 }
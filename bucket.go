@@ -16,33 +16,73 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"iter"
+	"log/slog"
+	"maps"
 	"net/http"
 	"path"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/kelindar/s3/aws"
 	"github.com/kelindar/s3/fsutil"
+	"golang.org/x/sync/errgroup"
 )
 
-// Bucket implements fs.FS, fs.ReadDirFS, and fs.SubFS.
+// Bucket implements fs.FS, fs.ReadDirFS, fs.ReadFileFS, and fs.SubFS.
 type Bucket struct {
-	key    *aws.SigningKey // signing key
-	bkt    string          // bucket name
-	Client *http.Client    // HTTP client used for requests, if nil then DefaultClient is used
-	Lazy   bool            // If true, causes the initial Open call to use a HEAD operation rather than a GET operation.
+	key        *aws.SigningKey // signing key
+	bkt        string          // bucket name
+	Client     *http.Client    // HTTP client used for requests, if nil then DefaultClient is used
+	Lazy       bool            // If true, causes the initial Open call to use a HEAD operation rather than a GET operation.
+	UserAgent  string          // User-Agent sent with every request, if empty then DefaultUserAgent is used
+	StrictKeys bool            // If true, writes reject keys that would be changed by path.Clean rather than writing to the cleaned key.
+	Limiter    Limiter         // Limiter, if not nil, is waited on before every request, including the parts of multipart uploads.
+	Logger     *slog.Logger    // Logger, if not nil, receives a debug record for every request, with signatures redacted.
+
+	// ReadBudget, if not nil, is the number of bytes of object contents
+	// that may still be read through the bucket and the files, prefixes
+	// and readers it returns. It is decremented atomically as contents
+	// are read, so it may be shared by several buckets, and reads fail
+	// with ErrBudgetExceeded once it is exhausted.
+	ReadBudget *int64
+
+	// ErrorMapper, if not nil, maps the status code and S3 error code of
+	// an unsuccessful response to Open, Stat or a listing to the error
+	// returned, e.g. fs.ErrNotExist for the 403 that some backends return
+	// for missing objects to prevent enumerating keys. If it returns nil,
+	// 404 is mapped to fs.ErrNotExist and 403 to fs.ErrPermission.
+	ErrorMapper ErrorMapper
+
+	// MinPartOverride, if non-zero, replaces MinPartSize as the minimum
+	// size of multipart upload parts. Only set this for S3-compatible
+	// backends that accept parts smaller than 5MB, as AWS does not.
+	MinPartOverride int
+
+	stats  *counters    // counters returned by Stats
+	listV1 *atomic.Bool // set once the server has rejected ListObjectsV2
 }
 
 // NewBucket creates a new Bucket instance.
 func NewBucket(key *aws.SigningKey, bucket string) *Bucket {
 	return &Bucket{
-		key: key,
-		bkt: bucket,
+		key:    key,
+		bkt:    bucket,
+		stats:  new(counters),
+		listV1: new(atomic.Bool),
 	}
 }
 
@@ -55,10 +95,33 @@ func (b *Bucket) client() *http.Client {
 
 func (b *Bucket) sub(name string) *Prefix {
 	return &Prefix{
-		Key:    b.key,
-		Client: b.Client,
-		Bucket: b.bkt,
-		Path:   name,
+		Key:         b.key,
+		Client:      b.Client,
+		Bucket:      b.bkt,
+		Path:        name,
+		UserAgent:   b.UserAgent,
+		Limiter:     b.Limiter,
+		Logger:      b.Logger,
+		ReadBudget:  b.ReadBudget,
+		ErrorMapper: b.ErrorMapper,
+		stats:       b.stats,
+		listV1:      b.listV1,
+	}
+}
+
+// reader returns a Reader with the settings of b,
+// so that every object read through b shares them.
+func (b *Bucket) reader() Reader {
+	return Reader{
+		Key:         b.key,
+		Client:      b.Client,
+		Bucket:      b.bkt,
+		UserAgent:   b.UserAgent,
+		Limiter:     b.Limiter,
+		Logger:      b.Logger,
+		ReadBudget:  b.ReadBudget,
+		ErrorMapper: b.ErrorMapper,
+		stats:       b.stats,
 	}
 }
 
@@ -70,9 +133,187 @@ func badpath(op, name string) error {
 	}
 }
 
+// cleanKey cleans an object key for writing. If b.StrictKeys
+// is set, keys that are changed by cleaning are rejected.
+func (b *Bucket) cleanKey(op, key string) (string, error) {
+	clean := path.Clean(key)
+	if b.StrictKeys && clean != key {
+		return "", badpath(op, key)
+	}
+	return clean, nil
+}
+
+// UploadOptions configures the object created by
+// Write, WriteFrom, and the other upload methods.
+type UploadOptions struct {
+	ACL        string            // ACL is the canned ACL (x-amz-acl) applied to the new object, if not empty.
+	Encryption string            // Encryption is the server-side encryption algorithm, e.g. "AES256" or "aws:kms".
+	KMSKeyID   string            // KMSKeyID is the KMS key used when Encryption is "aws:kms".
+	KMSContext map[string]string // KMSContext is the KMS encryption context when Encryption is "aws:kms".
+	BucketKey  bool              // BucketKey enables an S3 Bucket Key when Encryption is "aws:kms".
+
+	CacheControl       string // CacheControl is the Cache-Control header served with the object, e.g. "max-age=3600".
+	ContentDisposition string // ContentDisposition is the Content-Disposition header served with the object, e.g. "attachment".
+
+	// Expires, if not zero, is the Expires header served with the object,
+	// which tells caches when it becomes stale. It does not delete the
+	// object by itself; S3 only expires objects through lifecycle rules,
+	// which are reported back in Reader.Expiration.
+	Expires time.Time
+
+	// Tee, if not nil, receives a copy of the uploaded contents in order
+	// as they are read from the source, e.g. to compute a digest of the
+	// object during WriteFrom without reading the source twice.
+	Tee io.Writer
+
+	// ChecksumSHA256, if not empty, is the expected composite SHA256
+	// checksum of an object uploaded with WriteFrom, as computed by
+	// Bucket.CompositeSHA256. Every part is then uploaded with its
+	// SHA256 checksum, and once the upload is complete the checksum
+	// of the object is read back and compared against ChecksumSHA256,
+	// returning an error matching ErrChecksumMismatch if they differ.
+	// Note that the object has already been written in that case.
+	ChecksumSHA256 string
+
+	// ChecksumAlgorithm, if not empty, is the algorithm ("CRC32C" or
+	// "SHA256") of a checksum computed over the contents of a Write or
+	// WriteInfo and sent along, so that S3 rejects the object with a
+	// BadDigest error if it was corrupted in transit. It is ignored by
+	// the multipart uploads of WriteFrom, which use ChecksumSHA256.
+	ChecksumAlgorithm string
+
+	// ExtraHeaders are additional headers sent when creating the object,
+	// such as Content-Language or X-Amz-Website-Redirect-Location, for
+	// which there is no dedicated option. They are signed along with the
+	// request, and the fields above take precedence over them. User
+	// metadata set with x-amz-meta-* headers is checked before it is
+	// sent, failing with ErrInvalidMetadata if S3 would reject it.
+	ExtraHeaders http.Header
+
+	// CompleteTimeout, if positive, is the timeout of the request that
+	// completes a multipart upload, which S3 may take a long time to
+	// answer while it assembles a large object. It replaces the Timeout
+	// of the http.Client and the ResponseHeaderTimeout of its transport
+	// for that request only, if they are shorter, so that they can stay
+	// short for the parts. The context passed to Close still applies.
+	CompleteTimeout time.Duration
+
+	// ExpectContinue, if set, sends the PUT requests of Write and of
+	// the parts of multipart uploads with Expect: 100-continue, so that
+	// the body is only sent once the server accepts the request, rather
+	// than being streamed in full before an early rejection, e.g. of a
+	// bad signature, on a high-latency link. It costs a round trip per
+	// request, so it is mostly worthwhile for large bodies. The client
+	// waits for the ExpectContinueTimeout of its transport, which is set
+	// by DefaultClient, for the server to answer before sending anyway.
+	ExpectContinue bool
+}
+
+// reservedHeaders are the headers set by the client
+// itself, which cannot be overridden by ExtraHeaders.
+var reservedHeaders = []string{
+	"Authorization",
+	"Content-Length",
+	"Host",
+	"X-Amz-Content-Sha256",
+	"X-Amz-Date",
+	"X-Amz-Security-Token",
+}
+
+// validate checks that the options are valid.
+func (o *UploadOptions) validate() error {
+	if o.ACL != "" && !ValidACL(o.ACL) {
+		return fmt.Errorf("%w: %q", ErrInvalidACL, o.ACL)
+	}
+	switch o.ChecksumAlgorithm {
+	case "", "CRC32C", "SHA256":
+	default:
+		return fmt.Errorf("s3: unsupported checksum algorithm %q", o.ChecksumAlgorithm)
+	}
+	kms := o.KMSKeyID != "" || len(o.KMSContext) > 0 || o.BucketKey
+	if kms && !strings.HasPrefix(o.Encryption, "aws:kms") {
+		return fmt.Errorf("s3: KMS options require aws:kms encryption, got %q", o.Encryption)
+	}
+	for name := range o.ExtraHeaders {
+		if slices.Contains(reservedHeaders, http.CanonicalHeaderKey(name)) {
+			return fmt.Errorf("s3: header %q cannot be set with ExtraHeaders", name)
+		}
+	}
+	return validMetadata(headerMetadata(o.ExtraHeaders))
+}
+
+// signed returns the names of the extra headers,
+// which are signed along with the request.
+func (o *UploadOptions) signed() []string {
+	names := make([]string, 0, len(o.ExtraHeaders))
+	for name := range o.ExtraHeaders {
+		names = append(names, name)
+	}
+	return names
+}
+
+// expect sets Expect: 100-continue on req if
+// ExpectContinue is set and req has a body.
+func (o *UploadOptions) expect(req *http.Request) {
+	if o.ExpectContinue && req.ContentLength > 0 {
+		req.Header.Set("Expect", "100-continue")
+	}
+}
+
+// apply sets the headers described by the options on req.
+func (o *UploadOptions) apply(req *http.Request) {
+	for name, values := range o.ExtraHeaders {
+		// only the first value of a header is signed,
+		// so multiple values are sent as a single one
+		req.Header.Set(name, strings.Join(values, ","))
+	}
+	if o.ACL != "" {
+		req.Header.Set("x-amz-acl", o.ACL)
+	}
+	if o.Encryption != "" {
+		req.Header.Set("x-amz-server-side-encryption", o.Encryption)
+	}
+	if o.KMSKeyID != "" {
+		req.Header.Set("x-amz-server-side-encryption-aws-kms-key-id", o.KMSKeyID)
+	}
+	if len(o.KMSContext) > 0 {
+		// the context is a JSON object with string values,
+		// so marshaling it cannot fail
+		ctx, _ := json.Marshal(o.KMSContext)
+		req.Header.Set("x-amz-server-side-encryption-context", base64.StdEncoding.EncodeToString(ctx))
+	}
+	if o.BucketKey {
+		req.Header.Set("x-amz-server-side-encryption-bucket-key-enabled", "true")
+	}
+	if o.CacheControl != "" {
+		req.Header.Set("Cache-Control", o.CacheControl)
+	}
+	if o.ContentDisposition != "" {
+		req.Header.Set("Content-Disposition", o.ContentDisposition)
+	}
+	if !o.Expires.IsZero() {
+		req.Header.Set("Expires", o.Expires.UTC().Format(http.TimeFormat))
+	}
+}
+
+// uploadOptions returns the first of opts, if any.
+func uploadOptions(opts []UploadOptions) (UploadOptions, error) {
+	if len(opts) == 0 {
+		return UploadOptions{}, nil
+	}
+	return opts[0], opts[0].validate()
+}
+
 // Write performs a PutObject operation at the object key 'key' and returns the ETag of the newly-created object.
-func (b *Bucket) Write(ctx context.Context, key string, contents []byte) (string, error) {
-	key = path.Clean(key)
+//
+// The key is cleaned with path.Clean before writing, unless b.StrictKeys is set,
+// in which case keys that are not already clean are rejected.
+// If opts is provided, the first element configures the new object.
+func (b *Bucket) Write(ctx context.Context, key string, contents []byte, opts ...UploadOptions) (string, error) {
+	key, err := b.cleanKey("s3 PUT", key)
+	if err != nil {
+		return "", err
+	}
 	_, base := path.Split(key)
 	switch {
 	case !fs.ValidPath(key):
@@ -81,23 +322,481 @@ func (b *Bucket) Write(ctx context.Context, key string, contents []byte) (string
 		// Don't allow a path that is nominally a directory
 		return "", badpath("s3 PUT", key)
 	}
+	info, err := b.put(ctx, key, contents, opts)
+	if err != nil {
+		return "", err
+	}
+	return info.ETag, nil
+}
+
+// ObjectInfo describes an object created by a write.
+type ObjectInfo struct {
+	ETag         string    // ETag of the new object
+	Size         int64     // Size of the object in bytes
+	LastModified time.Time // LastModified is taken from the Date header of the response
+	VersionID    string    // VersionID is the version of the object, if the bucket is versioned
+	BucketKey    bool      // BucketKey reports whether the object is encrypted using an S3 Bucket Key
+	Parts        int       // Parts is the number of parts of a multipart upload, or zero for a single PUT
+}
+
+// WriteInfo performs a PutObject operation in the same way as Write, but returns
+// the ETag, size, modification time and version of the newly-created object as
+// reported by the PutObject response, avoiding a separate HEAD request.
+func (b *Bucket) WriteInfo(ctx context.Context, key string, contents []byte, opts ...UploadOptions) (*ObjectInfo, error) {
+	key, err := b.cleanKey("s3 PUT", key)
+	if err != nil {
+		return nil, err
+	}
+	_, base := path.Split(key)
+	if !fs.ValidPath(key) || base == "." {
+		return nil, badpath("s3 PUT", key)
+	}
+	return b.put(ctx, key, contents, opts)
+}
 
+// WriteRaw performs a PutObject operation at exactly the object key 'key'
+// and returns the ETag of the newly-created object.
+//
+// Unlike Write, WriteRaw does not clean or validate the key, so it can be
+// used to write objects whose keys contain ".", ".." or empty path segments.
+// Such objects are not addressable through the fs.FS interface of the Bucket.
+func (b *Bucket) WriteRaw(ctx context.Context, key string, contents []byte, opts ...UploadOptions) (string, error) {
+	if key == "" {
+		return "", badpath("s3 PUT", key)
+	}
+	info, err := b.put(ctx, key, contents, opts)
+	if err != nil {
+		return "", err
+	}
+	return info.ETag, nil
+}
+
+// MkdirMarker writes an empty object at the key dir + "/", which
+// tools that expect directory markers show as an empty directory.
+// The directory dir must be a valid, clean path other than ".".
+func (b *Bucket) MkdirMarker(ctx context.Context, dir string) error {
+	if !fs.ValidPath(dir) || dir == "." || path.Clean(dir) != dir {
+		return badpath("s3 mkdir", dir)
+	}
+	_, err := b.put(ctx, dir+"/", nil, nil)
+	return err
+}
+
+func (b *Bucket) put(ctx context.Context, key string, contents []byte, opts []UploadOptions) (*ObjectInfo, error) {
+	o, err := uploadOptions(opts)
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uri(b.key, b.bkt, key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Tee != nil {
+		if _, err := o.Tee.Write(contents); err != nil {
+			return nil, fmt.Errorf("writing to tee: %w", err)
+		}
+	}
+	o.apply(req)
+	signed := o.signed()
+	if o.ChecksumAlgorithm != "" {
+		header, sum := objectChecksum(o.ChecksumAlgorithm, contents)
+		req.Header.Set("x-amz-sdk-checksum-algorithm", o.ChecksumAlgorithm)
+		req.Header.Set(header, sum)
+		signed = append(signed, "x-amz-sdk-checksum-algorithm", header)
+	}
+	setUserAgent(req, b.UserAgent)
+	b.key.SignV4(req, contents, signed...)
+	o.expect(req)
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, responseError("s3 PUT", res)
+	}
+	info := &ObjectInfo{
+		ETag:      res.Header.Get("ETag"),
+		Size:      int64(len(contents)),
+		VersionID: res.Header.Get("x-amz-version-id"),
+		BucketKey: bucketKeyEnabled(res.Header),
+	}
+	if date, err := http.ParseTime(res.Header.Get("Date")); err == nil {
+		info.LastModified = date
+	}
+	return info, nil
+}
+
+// CopyOptions configures the object created by Copy.
+type CopyOptions struct {
+	// MetadataDirective is either "COPY" (the default), to preserve the
+	// metadata of the source object, or "REPLACE", to replace it with
+	// the headers and Metadata below. Replacing the metadata drops
+	// every header of the source object that is not set here.
+	MetadataDirective string
+
+	ContentType        string            // ContentType is the Content-Type of the new object when replacing metadata.
+	CacheControl       string            // CacheControl is the Cache-Control of the new object when replacing metadata.
+	ContentDisposition string            // ContentDisposition is the Content-Disposition of the new object when replacing metadata.
+	ContentEncoding    string            // ContentEncoding is the Content-Encoding of the new object when replacing metadata.
+	ContentLanguage    string            // ContentLanguage is the Content-Language of the new object when replacing metadata.
+	Expires            time.Time         // Expires, if not zero, is the Expires of the new object when replacing metadata.
+	Metadata           map[string]string // Metadata is the user metadata (x-amz-meta-*) of the new object when replacing metadata.
+}
+
+// headers returns the standard headers set by the options.
+func (o *CopyOptions) headers() http.Header {
+	h := make(http.Header)
+	for name, v := range map[string]string{
+		"Content-Type":        o.ContentType,
+		"Cache-Control":       o.CacheControl,
+		"Content-Disposition": o.ContentDisposition,
+		"Content-Encoding":    o.ContentEncoding,
+		"Content-Language":    o.ContentLanguage,
+	} {
+		if v != "" {
+			h.Set(name, v)
+		}
+	}
+	if !o.Expires.IsZero() {
+		h.Set("Expires", o.Expires.UTC().Format(http.TimeFormat))
+	}
+	return h
+}
+
+// validate checks that the options are valid.
+func (o *CopyOptions) validate() error {
+	switch o.MetadataDirective {
+	case "", "COPY":
+		if len(o.headers()) > 0 || len(o.Metadata) > 0 {
+			return fmt.Errorf("s3: copy metadata requires the REPLACE directive, got %q", o.MetadataDirective)
+		}
+	case "REPLACE":
+	default:
+		return fmt.Errorf("s3: invalid metadata directive %q", o.MetadataDirective)
+	}
+	return validMetadata(o.Metadata)
+}
+
+// signed returns the names of the headers set by apply,
+// which are signed along with the request.
+func (o *CopyOptions) signed() []string {
+	if o.MetadataDirective == "" {
+		return nil
+	}
+	h := o.headers()
+	names := make([]string, 0, len(h)+len(o.Metadata)+1)
+	names = append(names, "x-amz-metadata-directive")
+	for k := range h {
+		names = append(names, k)
+	}
+	for k := range o.Metadata {
+		names = append(names, "x-amz-meta-"+k)
+	}
+	return names
+}
+
+// apply sets the headers described by the options on req.
+func (o *CopyOptions) apply(req *http.Request) {
+	if o.MetadataDirective == "" {
+		return
+	}
+	req.Header.Set("x-amz-metadata-directive", o.MetadataDirective)
+	for k, v := range o.headers() {
+		req.Header[k] = v
+	}
+	for k, v := range o.Metadata {
+		req.Header.Set("x-amz-meta-"+k, v)
+	}
+}
+
+// Copy performs a server-side copy of the object at src
+// to dst within the bucket and returns the ETag of the
+// newly-created object. Both keys are cleaned in the
+// same way as for Write.
+//
+// The new object keeps the metadata of src, unless opts
+// is provided and its first element replaces it.
+func (b *Bucket) Copy(ctx context.Context, src, dst string, opts ...CopyOptions) (string, error) {
+	var o CopyOptions
+	if len(opts) > 0 {
+		o = opts[0]
+		if err := o.validate(); err != nil {
+			return "", err
+		}
+	}
+	src = path.Clean(src)
+	if !fs.ValidPath(src) || src == "." {
+		return "", badpath("s3 copy", src)
+	}
+	dst, err := b.cleanKey("s3 copy", dst)
 	if err != nil {
 		return "", err
 	}
+	if !fs.ValidPath(dst) || dst == "." {
+		return "", badpath("s3 copy", dst)
+	}
+	return b.copy(ctx, src, dst, o, false)
+}
 
-	b.key.SignV4(req, contents)
-	res, err := flakyDo(b.client(), req)
+// CopyIfAbsent performs a server-side copy of the object at src to dst
+// like Copy, but only if there is no object at dst yet, which makes it
+// safe to repeat, e.g. in a backfill. It returns copied=false, and no
+// error, if dst already exists, in which case it is left untouched.
+//
+// The check is made by S3 with If-None-Match, so it holds even against
+// a concurrent write of dst. Backends which do not implement conditional
+// writes may ignore it and overwrite dst.
+func (b *Bucket) CopyIfAbsent(ctx context.Context, src, dst string) (copied bool, etag string, err error) {
+	src = path.Clean(src)
+	if !fs.ValidPath(src) || src == "." {
+		return false, "", badpath("s3 copy", src)
+	}
+	dst, err = b.cleanKey("s3 copy", dst)
+	if err != nil {
+		return false, "", err
+	}
+	if !fs.ValidPath(dst) || dst == "." {
+		return false, "", badpath("s3 copy", dst)
+	}
+	etag, err = b.copy(ctx, src, dst, CopyOptions{}, true)
+	var serr *Error
+	if errors.As(err, &serr) && serr.StatusCode == http.StatusPreconditionFailed {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	return true, etag, nil
+}
+
+// copy copies src to dst, only if dst does not exist if absent is set.
+func (b *Bucket) copy(ctx context.Context, src, dst string, o CopyOptions, absent bool) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uri(b.key, b.bkt, dst), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("x-amz-copy-source", "/"+b.bkt+"/"+almostPathEscape(src))
+	if absent {
+		req.Header.Set("If-None-Match", "*")
+	}
+	o.apply(req)
+	setUserAgent(req, b.UserAgent)
+	b.key.SignV4(req, nil, o.signed()...)
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
 	if err != nil {
 		return "", err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		return "", fmt.Errorf("s3 PUT: %s %s", res.Status, extractMessage(res.Body))
+		return "", responseError("s3 copy", res)
+	}
+	// S3 may report a failed copy with a 200 status
+	// and an <Error/> body, so check the body as well
+	rt := struct {
+		XMLName xml.Name
+		ETag    string `xml:"ETag"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}{}
+	if err := xml.NewDecoder(res.Body).Decode(&rt); err != nil {
+		return "", fmt.Errorf("s3 copy: decoding response: %w", err)
+	}
+	if rt.XMLName.Local == "Error" {
+		return "", &Error{
+			Op:         "s3 copy",
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Code:       rt.Code,
+			Message:    rt.Message,
+			RequestID:  res.Header.Get("x-amz-request-id"),
+			HostID:     res.Header.Get("x-amz-id-2"),
+		}
+	}
+	return rt.ETag, nil
+}
+
+// Touch replaces the user metadata of the object at key with
+// metadata, without changing its contents, by copying the object
+// onto itself with the REPLACE metadata directive, and returns the
+// ETag of the result. This also refreshes the LastModified time of
+// the object, and with it the evaluation of lifecycle rules.
+//
+// The Content-Type, Cache-Control, Content-Disposition,
+// Content-Encoding, Content-Language and Expires headers of the
+// object are preserved. Some S3-compatible backends reject copying
+// an object onto itself with unchanged metadata; Touch then returns
+// an error saying so, wrapping the *Error from the backend.
+func (b *Bucket) Touch(ctx context.Context, key string, metadata map[string]string) (string, error) {
+	key, err := b.cleanKey("s3 touch", key)
+	if err != nil {
+		return "", err
+	}
+	if !fs.ValidPath(key) || key == "." {
+		return "", badpath("s3 touch", key)
+	}
+	if err := validMetadata(metadata); err != nil {
+		return "", err
+	}
+	r := b.reader()
+	r.ctx = ctx
+	body, err := r.open(b.key, b.bkt, key, false)
+	if body != nil {
+		body.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	etag, err := b.copy(ctx, key, key, CopyOptions{
+		MetadataDirective:  "REPLACE",
+		ContentType:        r.ContentType,
+		CacheControl:       r.CacheControl,
+		ContentDisposition: r.ContentDisposition,
+		ContentEncoding:    r.Headers.Get("Content-Encoding"),
+		ContentLanguage:    r.Headers.Get("Content-Language"),
+		Expires:            r.Expires,
+		Metadata:           metadata,
+	}, false)
+	var serr *Error
+	if errors.As(err, &serr) && selfCopyRejected(serr) {
+		return "", fmt.Errorf("s3 touch %s: copying the object onto itself was rejected, the backend may not support replacing its metadata: %w", key, err)
+	}
+	return etag, err
+}
+
+// selfCopyRejected reports whether e is the response of S3
+// to a copy of an object onto itself that changes nothing.
+func selfCopyRejected(e *Error) bool {
+	return e.Code == "InvalidRequest" && strings.Contains(e.Message, "to itself")
+}
+
+// ObjectURL returns the canonical, unsigned URL of the object
+// at key, using the same endpoint as every other request made
+// through b. Unlike URL, the result carries no signature, so
+// it is only directly usable for objects that are publicly
+// readable; it is mostly useful for logging and for links.
+func (b *Bucket) ObjectURL(key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." {
+		return "", badpath("s3 url", key)
+	}
+	return uri(b.key, b.bkt, key), nil
+}
+
+// HeadBucket checks that the bucket exists and that
+// the caller has permission to access it. The returned
+// error matches fs.ErrNotExist if the bucket does not
+// exist and fs.ErrPermission if access is denied.
+func (b *Bucket) HeadBucket(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURI(b.key, b.bkt, ""), nil)
+	if err != nil {
+		return err
 	}
-	etag := res.Header.Get("ETag")
-	return etag, nil
+	setUserAgent(req, b.UserAgent)
+	b.key.SignV4(req, nil)
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return &fs.PathError{Op: "headbucket", Path: b.bkt, Err: fs.ErrNotExist}
+	case http.StatusForbidden:
+		return &fs.PathError{Op: "headbucket", Path: b.bkt, Err: fs.ErrPermission}
+	default:
+		return responseError("s3 HEAD", res)
+	}
+}
+
+// Ping checks that S3 can be reached and that the
+// credentials of the bucket are valid for it, using a
+// listing of a single key, so that applications can use
+// it as a health check. The returned error matches
+// ErrUnreachable if S3 could not be reached at all,
+// fs.ErrPermission if the request was denied, e.g. for
+// a SignatureDoesNotMatch or InvalidAccessKeyId error,
+// and fs.ErrNotExist if the bucket does not exist. The
+// *Error returned by S3, if any, is wrapped as well.
+//
+// Like listings, Ping falls back to the original
+// ListObjects API on servers which reject ListObjectsV2.
+func (b *Bucket) Ping(ctx context.Context) error {
+	if !ValidBucket(b.bkt) {
+		return badBucket(b.bkt)
+	}
+	v1 := useListV1(b.listV1)
+	err := b.ping(ctx, v1)
+	var e *Error
+	if !v1 && errors.As(err, &e) && listV2Unsupported(e) {
+		if b.listV1 != nil {
+			b.listV1.Store(true)
+		}
+		err = b.ping(ctx, true)
+	}
+	return err
+}
+
+// ping lists a single key with ListObjects
+// rather than ListObjectsV2 if v1 is set.
+func (b *Bucket) ping(ctx context.Context, v1 bool) error {
+	query := "?list-type=2&max-keys=1"
+	if v1 {
+		query = "?max-keys=1"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURI(b.key, b.bkt, query), nil)
+	if err != nil {
+		return err
+	}
+	setUserAgent(req, b.UserAgent)
+	b.key.SignV4(req, nil)
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+	if err != nil {
+		var re *RetryError
+		if ctx.Err() != nil || !errors.As(err, &re) || re.StatusCode != 0 {
+			return err
+		}
+		return &fs.PathError{Op: "ping", Path: b.bkt, Err: fmt.Errorf("%w: %w", ErrUnreachable, err)}
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return &fs.PathError{Op: "ping", Path: b.bkt, Err: fmt.Errorf("%w: %w", fs.ErrNotExist, responseError("s3.Ping", res))}
+	case http.StatusForbidden:
+		return &fs.PathError{Op: "ping", Path: b.bkt, Err: fmt.Errorf("%w: %w", fs.ErrPermission, responseError("s3.Ping", res))}
+	default:
+		return responseError("s3.Ping", res)
+	}
+}
+
+// Warmup primes the connection pool of the bucket's HTTP
+// client by issuing n concurrent HEAD bucket requests, so
+// that a subsequent burst of requests can reuse the idle
+// connections rather than paying for new TLS handshakes.
+//
+// If the client uses an *http.Transport, n is capped at
+// its MaxIdleConnsPerHost, since any further connections
+// would be closed as soon as they become idle.
+func (b *Bucket) Warmup(ctx context.Context, n int) error {
+	if t, ok := b.client().Transport.(*http.Transport); ok {
+		limit := t.MaxIdleConnsPerHost
+		if limit == 0 {
+			limit = http.DefaultMaxIdleConnsPerHost
+		}
+		n = min(n, limit)
+	}
+	var g errgroup.Group
+	for i := 0; i < n; i++ {
+		g.Go(func() error {
+			return b.HeadBucket(ctx)
+		})
+	}
+	return g.Wait()
 }
 
 // Sub implements fs.SubFS.Sub.
@@ -120,9 +819,17 @@ func (b *Bucket) Sub(dir string) (fs.FS, error) {
 // leads to multiple objects.
 // If name does not refer to an object or a path prefix,
 // then Open returns an error matching fs.ErrNotExist.
+//
+// An object and a path prefix may share a name, as with
+// the key "foo" and the keys under "foo/". Open("foo")
+// prefers the object and only lists "foo/" if there is
+// no such object, whereas Open("foo/") always resolves
+// the path prefix and never fetches the object, so a
+// trailing slash can be used to force directory semantics.
 func (b *Bucket) Open(name string) (fs.File, error) {
 	// interpret a trailing / to mean
-	// a directory
+	// a directory; this must be checked
+	// before path.Clean strips it
 	isDir := strings.HasSuffix(name, "/")
 	name = path.Clean(name)
 	if !fs.ValidPath(name) {
@@ -136,13 +843,89 @@ func (b *Bucket) Open(name string) (fs.File, error) {
 		// try a HEAD or GET operation; these
 		// are cheaper and faster than
 		// full listing operations
-		f, err := Open(b.key, b.bkt, name, !b.Lazy)
-		if err == nil || !errors.Is(err, fs.ErrNotExist) {
-			return f, err
+		f := &File{Reader: b.reader()}
+		err := f.open(b.key, b.bkt, name, !b.Lazy)
+		if err == nil {
+			return f, nil
 		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	// list with the trailing slash so that
+	// a sibling like "foobar" does not make
+	// "foo" appear to be a directory
+	return b.sub(name + "/").openDir()
+}
+
+// OpenTyped is like Open, but returns the opened
+// object as a *File or the opened directory as a
+// *Prefix, so that callers do not need to know the
+// concrete types returned by Open. Exactly one of
+// the two is non-nil if the error is nil.
+func (b *Bucket) OpenTyped(name string) (*File, *Prefix, error) {
+	f, err := b.Open(name)
+	if err != nil {
+		return nil, nil, err
 	}
+	switch f := f.(type) {
+	case *File:
+		return f, nil, nil
+	case *Prefix:
+		return nil, f, nil
+	default:
+		f.Close()
+		return nil, nil, fmt.Errorf("s3.OpenTyped: unexpected file type %T", f)
+	}
+}
+
+// ReadFile implements fs.ReadFileFS.ReadFile
+//
+// ReadFile fetches the object at name with a single
+// GET request, regardless of b.Lazy, so it is cheaper
+// than Open followed by reading the whole file.
+func (b *Bucket) ReadFile(name string) ([]byte, error) {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) || name == "." {
+		return nil, badpath("readfile", name)
+	}
+	req, err := http.NewRequest(http.MethodGet, uri(b.key, b.bkt, name), nil)
+	if err != nil {
+		return nil, err
+	}
+	setUserAgent(req, b.UserAgent)
+	b.key.SignV4(req, nil)
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(budgeted(b.ReadBudget, res.Body))
+	case http.StatusNotFound:
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	case http.StatusForbidden:
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrPermission}
+	default:
+		return nil, responseError("s3 GET", res)
+	}
+}
 
-	return b.sub(name).openDir()
+// OpenRaw opens the object at exactly the key 'key'.
+//
+// Unlike Open, OpenRaw does not clean or validate the key,
+// and it never returns a directory. See WriteRaw.
+func (b *Bucket) OpenRaw(key string) (*File, error) {
+	if key == "" {
+		return nil, badpath("open", key)
+	}
+	f := &File{Reader: b.reader()}
+	if err := f.open(b.key, b.bkt, key, !b.Lazy); err != nil {
+		return nil, err
+	}
+	return f, nil
 }
 
 // OpenRange produces an [io.ReadCloser] that reads data from
@@ -155,13 +938,8 @@ func (b *Bucket) OpenRange(name, etag string, start, width int64) (io.ReadCloser
 	if !fs.ValidPath(name) || name == "." {
 		return nil, badpath("OpenRange", name)
 	}
-	r := Reader{
-		Client: b.Client,
-		Key:    b.key,
-		Bucket: b.bkt,
-		Path:   name,
-		ETag:   etag,
-	}
+	r := b.reader()
+	r.Path, r.ETag = name, etag
 	return r.RangeReader(start, width)
 }
 
@@ -177,10 +955,68 @@ func (b *Bucket) VisitDir(name, seek, pattern string, walk fsutil.VisitDirFn) er
 	return b.sub(name+"/").VisitDir(".", seek, pattern, walk)
 }
 
+// DiskUsage implements fsutil.DiskUsageFS
+//
+// Rather than walking the tree one directory at a time,
+// DiskUsage lists every key below root with a flat listing,
+// returning up to maxListKeys objects per request. Directory
+// markers (keys ending in "/") are not counted.
+func (b *Bucket) DiskUsage(root string) (count int, bytes int64, err error) {
+	root = path.Clean(root)
+	if !fs.ValidPath(root) {
+		return 0, 0, badpath("diskusage", root)
+	}
+	prefix := b.sub(".")
+	if root != "." {
+		prefix = b.sub(root + "/")
+	}
+
+	var token string
+	for {
+		ret, err := prefix.listWith(context.Background(), ListOptions{
+			MaxKeys:           maxListKeys,
+			ContinuationToken: token,
+		})
+		if err != nil {
+			return 0, 0, &fs.PathError{Op: "diskusage", Path: root, Err: err}
+		}
+		for i := range ret.Contents {
+			if !strings.HasSuffix(ret.Contents[i].Path(), "/") {
+				count++
+				bytes += ret.Contents[i].Size()
+			}
+		}
+		if !ret.IsTruncated {
+			break
+		}
+		token = ret.NextToken
+	}
+
+	// nothing below root: it may be a single
+	// file, an empty directory, or missing
+	if count == 0 && root != "." {
+		info, err := fs.Stat(b, root)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !info.IsDir() {
+			return 1, info.Size(), nil
+		}
+	}
+	return count, bytes, nil
+}
+
 // ReadDir implements fs.ReadDirFS
 func (b *Bucket) ReadDir(name string) ([]fs.DirEntry, error) {
+	return b.ReadDirContext(context.Background(), name)
+}
+
+// ReadDirContext is like ReadDir, but the listing
+// requests are bound to ctx, so that a slow listing
+// can be cancelled part way through.
+func (b *Bucket) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
 	var entries []fs.DirEntry
-	for entry, err := range b.List(context.Background(), name) {
+	for entry, err := range b.List(ctx, name) {
 		if err != nil {
 			return nil, err
 		}
@@ -189,8 +1025,15 @@ func (b *Bucket) ReadDir(name string) ([]fs.DirEntry, error) {
 
 	name = path.Clean(name)
 	if len(entries) == 0 && name != "." {
-		// An empty listing usually means the directory does not exist.
-		f, err := b.sub(name + "/").openDir()
+		// An empty listing usually means the directory does not exist,
+		// unless name is an object rather than a directory.
+		dir := b.sub(name + "/")
+		f, err := dir.openDirContext(ctx)
+		if errors.Is(err, fs.ErrNotExist) {
+			if err := dir.notDir(ctx); err != nil {
+				return nil, err
+			}
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -236,52 +1079,666 @@ func (b *Bucket) List(ctx context.Context, name string) iter.Seq2[fs.DirEntry, e
 	}
 }
 
+// FileInfo describes an object in a DirListing.
+type FileInfo struct {
+	Name    string    // Name is the base name of the object
+	Size    int64     // Size of the object in bytes
+	ModTime time.Time // ModTime is the LastModified time of the object
+	ETag    string    // ETag of the object
+}
+
+// DirListing is a single page of the immediate
+// children of a directory, as returned by ListPage.
+type DirListing struct {
+	Files       []FileInfo // Files are the objects directly in the directory
+	Dirs        []string   // Dirs are the base names of the subdirectories
+	NextToken   string     // NextToken resumes the listing if IsTruncated is set
+	IsTruncated bool       // IsTruncated is set if there are more children
+}
+
+// ListPage lists a single page of at most max immediate children
+// of the directory name, starting at the continuation token, or
+// at the beginning if token is empty. If max is not positive, the
+// server default page size is used.
+//
+// Unlike ReadDir, ListPage separates the objects from the
+// subdirectories and does not check that the directory exists,
+// so a missing directory yields an empty listing.
+func (b *Bucket) ListPage(ctx context.Context, name, token string, max int) (*DirListing, error) {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return nil, badpath("readdir", name)
+	}
+
+	prefix := b.sub(".")
+	if name != "." {
+		prefix = b.sub(name + "/")
+	}
+
+	ret, err := prefix.listWith(ctx, ListOptions{
+		Delimiter:         "/",
+		MaxKeys:           max,
+		ContinuationToken: token,
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: prefix.Path, Err: err}
+	}
+
+	out := &DirListing{
+		Files:       make([]FileInfo, 0, len(ret.Contents)),
+		Dirs:        make([]string, 0, len(ret.CommonPrefixes)),
+		NextToken:   ret.NextToken,
+		IsTruncated: ret.IsTruncated,
+	}
+	for i := range ret.Contents {
+		f := &ret.Contents[i]
+		if ignoreKey(f.Path(), false) {
+			continue
+		}
+		out.Files = append(out.Files, FileInfo{
+			Name:    f.Name(),
+			Size:    f.Reader.Size,
+			ModTime: f.LastModified,
+			ETag:    f.ETag,
+		})
+	}
+	for i := range ret.CommonPrefixes {
+		if ignoreKey(ret.CommonPrefixes[i].Path, true) {
+			continue
+		}
+		out.Dirs = append(out.Dirs, ret.CommonPrefixes[i].Name())
+	}
+	return out, nil
+}
+
+// ReadDirAll lists each of the given prefixes as with ReadDir,
+// listing up to parallel prefixes concurrently, and returns the
+// entries of every prefix keyed by the prefix as it was given.
+// If parallel is not positive, all the prefixes are listed at once.
+//
+// ReadDirAll carries on past prefixes that could not be listed;
+// if any failed, the returned error is a *KeyError mapping each
+// of them to its error, and they are missing from the result.
+func (b *Bucket) ReadDirAll(ctx context.Context, prefixes []string, parallel int) (map[string][]fs.DirEntry, error) {
+	var lock sync.Mutex
+	out := make(map[string][]fs.DirEntry, len(prefixes))
+	failed := make(map[string]error)
+
+	var g errgroup.Group
+	if parallel > 0 {
+		g.SetLimit(parallel)
+	}
+	for _, name := range prefixes {
+		g.Go(func() error {
+			entries, err := b.ReadDirContext(ctx, name)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				failed[name] = err
+			} else {
+				out[name] = entries
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	if len(failed) > 0 {
+		return out, &KeyError{Op: "s3 ReadDirAll", Failed: failed}
+	}
+	return out, nil
+}
+
 // Delete removes the object at fullpath.
+//
+// The returned error matches fs.ErrInvalid if fullpath is not
+// a valid path, fs.ErrNotExist if the backend reports that the
+// object does not exist and fs.ErrPermission if access is denied.
+// Errors returned by S3 can be inspected further with errors.As
+// and an *Error target.
 func (b *Bucket) Delete(ctx context.Context, fullpath string) error {
 	fullpath = path.Clean(fullpath)
 	if !fs.ValidPath(fullpath) {
-		return fmt.Errorf("%s: %s", fullpath, fs.ErrInvalid)
+		return badpath("delete", fullpath)
+	}
+	return b.delete(ctx, fullpath, "", false)
+}
+
+// errNoConditionalDelete is returned from delete
+// when the backend does not implement If-Match.
+var errNoConditionalDelete = errors.New("conditional delete not implemented")
+
+// DeleteIfMatch removes the object at fullpath only if its ETag matches etag,
+// returning an error matching ErrPreconditionFailed otherwise.
+//
+// If the backend does not implement conditional deletes, DeleteIfMatch falls
+// back to checking the ETag with a HEAD request before deleting the object.
+// In that case an object written between the two requests is deleted even
+// though its ETag does not match.
+func (b *Bucket) DeleteIfMatch(ctx context.Context, fullpath, etag string) error {
+	fullpath = path.Clean(fullpath)
+	if !fs.ValidPath(fullpath) || etag == "" {
+		return badpath("delete", fullpath)
+	}
+	err := b.delete(ctx, fullpath, etag, false)
+	if !errors.Is(err, errNoConditionalDelete) {
+		return err
 	}
+
+	r := b.reader()
+	body, err := r.open(b.key, b.bkt, fullpath, false)
+	if body != nil {
+		body.Close()
+	}
+	switch {
+	case err != nil:
+		return err
+	case r.ETag != etag:
+		return &fs.PathError{Op: "delete", Path: fullpath, Err: ErrPreconditionFailed}
+	}
+	return b.delete(ctx, fullpath, "", false)
+}
+
+// DeleteRaw removes the object at exactly the key 'key'.
+//
+// Unlike Delete, DeleteRaw does not clean or validate the key.
+// See WriteRaw.
+func (b *Bucket) DeleteRaw(ctx context.Context, key string) error {
+	if key == "" {
+		return badpath("delete", key)
+	}
+	return b.delete(ctx, key, "", false)
+}
+
+// delete removes the object at fullpath, provided that
+// its ETag matches etag if etag is not empty. If bypass
+// is set, the object is removed even if it is protected
+// by a governance mode retention.
+func (b *Bucket) delete(ctx context.Context, fullpath, etag string, bypass bool) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, uri(b.key, b.bkt, fullpath), nil)
 	if err != nil {
 		return err
 	}
-	b.key.SignV4(req, nil)
-	res, err := flakyDo(b.client(), req)
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	if bypass {
+		req.Header.Set("x-amz-bypass-governance-retention", "true")
+	}
+	setUserAgent(req, b.UserAgent)
+	b.key.SignV4(req, nil, "x-amz-bypass-governance-retention")
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
-	if res.StatusCode != 204 {
-		return fmt.Errorf("s3 DELETE: %s %s", res.Status, extractMessage(res.Body))
+	switch res.StatusCode {
+	case 204:
+		return nil
+	case http.StatusPreconditionFailed:
+		return &fs.PathError{Op: "delete", Path: fullpath, Err: ErrPreconditionFailed}
+	case http.StatusNotFound:
+		return &fs.PathError{Op: "delete", Path: fullpath, Err: fmt.Errorf("%w: %w", fs.ErrNotExist, responseError("s3 DELETE", res))}
+	case http.StatusForbidden:
+		return &fs.PathError{Op: "delete", Path: fullpath, Err: fmt.Errorf("%w: %w", fs.ErrPermission, responseError("s3 DELETE", res))}
+	case http.StatusNotImplemented:
+		if etag != "" {
+			return errNoConditionalDelete
+		}
 	}
-	return nil
+	return responseError("s3 DELETE", res)
 }
 
-// WriteFrom performs a multipart upload of data from an io.ReaderAt to the specified key.
-func (b *Bucket) WriteFrom(ctx context.Context, key string, r io.ReaderAt, size int64) error {
-	key = path.Clean(key)
-	_, base := path.Split(key)
+// maxDeleteKeys is the maximum number of keys
+// accepted by a single multi-object delete request.
+const maxDeleteKeys = 1000
+
+// RemoveAll deletes every object under prefix and
+// returns the number of objects removed. The prefix
+// is listed one page at a time and each page is
+// removed with a single multi-object delete request,
+// so the full set of keys is never held in memory.
+func (b *Bucket) RemoveAll(ctx context.Context, prefix string) (int, error) {
+	prefix = path.Clean(prefix)
+	if !fs.ValidPath(prefix) {
+		return 0, badpath("removeall", prefix)
+	}
+	if prefix == "." {
+		return b.removeAll(ctx, b.sub("."))
+	}
+	return b.removeAll(ctx, b.sub(prefix+"/"))
+}
+
+func (b *Bucket) removeAll(ctx context.Context, p *Prefix) (int, error) {
+	var count int
+	err := b.walkKeys(ctx, p, func(keys []string) error {
+		if err := b.deleteObjects(ctx, keys); err != nil {
+			return err
+		}
+		count += len(keys)
+		return nil
+	})
+	return count, err
+}
+
+// walkKeys calls fn with each page of object keys under p,
+// descending into each of the common prefixes in turn.
+func (b *Bucket) walkKeys(ctx context.Context, p *Prefix, fn func(keys []string) error) error {
+	var token string
+	for {
+		ret, err := p.listContext(ctx, maxDeleteKeys, token, "", "")
+		if err != nil {
+			return err
+		}
+		if len(ret.Contents) > 0 {
+			keys := make([]string, len(ret.Contents))
+			for i := range ret.Contents {
+				keys[i] = ret.Contents[i].Path()
+			}
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		for i := range ret.CommonPrefixes {
+			if err := b.walkKeys(ctx, b.sub(ret.CommonPrefixes[i].Path), fn); err != nil {
+				return err
+			}
+		}
+		if !ret.IsTruncated {
+			return nil
+		}
+		token = ret.NextToken
+	}
+}
+
+// KeyError reports the individual keys that could not
+// be processed by an operation on many objects.
+type KeyError struct {
+	Op     string           // Op is the operation that failed, e.g. "s3 RenamePrefix".
+	Failed map[string]error // Failed maps each failed key to its error.
+}
+
+// Error implements error.Error
+func (e *KeyError) Error() string {
+	keys := slices.Sorted(maps.Keys(e.Failed))
+	return fmt.Sprintf("%s: %d keys failed; %s: %s", e.Op, len(keys), keys[0], e.Failed[keys[0]])
+}
+
+// renameParallelism is the number of objects
+// copied concurrently by RenamePrefix.
+const renameParallelism = 8
+
+// RenamePrefix moves every object under oldPrefix to the same
+// relative key under newPrefix using server-side copies, and
+// returns the number of objects moved. The originals are only
+// deleted once they have been copied.
+//
+// RenamePrefix carries on past individual failures; if any object
+// could not be moved, the returned error is a *KeyError listing
+// the keys that failed, which are left in place under oldPrefix.
+func (b *Bucket) RenamePrefix(ctx context.Context, oldPrefix, newPrefix string) (int, error) {
+	oldPrefix, newPrefix = path.Clean(oldPrefix), path.Clean(newPrefix)
 	switch {
-	case !fs.ValidPath(key):
-		return badpath("s3 Upload", key)
-	case base == ".":
-		return badpath("s3 Upload", key)
-	case size < 0:
-		return fmt.Errorf("size must be non-negative, got %d", size)
+	case !fs.ValidPath(oldPrefix) || oldPrefix == ".":
+		return 0, badpath("renameprefix", oldPrefix)
+	case !fs.ValidPath(newPrefix) || newPrefix == ".":
+		return 0, badpath("renameprefix", newPrefix)
+	case treeContains(oldPrefix, newPrefix) || treeContains(newPrefix, oldPrefix):
+		return 0, fmt.Errorf("s3 RenamePrefix: %q and %q overlap", oldPrefix, newPrefix)
+	}
+
+	var lock sync.Mutex
+	var count int
+	failed := make(map[string]error)
+	fail := func(key string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		failed[key] = err
+	}
+
+	err := b.walkKeys(ctx, b.sub(oldPrefix+"/"), func(keys []string) error {
+		copied := make([]string, 0, len(keys))
+		var g errgroup.Group
+		g.SetLimit(renameParallelism)
+		for _, key := range keys {
+			g.Go(func() error {
+				dst := newPrefix + strings.TrimPrefix(key, oldPrefix)
+				if _, err := b.copy(ctx, key, dst, CopyOptions{}, false); err != nil {
+					fail(key, err)
+					return nil
+				}
+				lock.Lock()
+				copied = append(copied, key)
+				lock.Unlock()
+				return nil
+			})
+		}
+		g.Wait()
+		if len(copied) == 0 {
+			return ctx.Err()
+		}
+
+		err := b.deleteObjects(ctx, copied)
+		var kerr *KeyError
+		switch {
+		case err == nil:
+			count += len(copied)
+		case errors.As(err, &kerr):
+			count += len(copied) - len(kerr.Failed)
+			for key, err := range kerr.Failed {
+				fail(key, err)
+			}
+		default:
+			return err
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return count, err
+	}
+	if len(failed) > 0 {
+		return count, &KeyError{Op: "s3 RenamePrefix", Failed: failed}
+	}
+	return count, nil
+}
+
+// treeContains returns whether the key or
+// prefix p is within the tree rooted at dir.
+func treeContains(dir, p string) bool {
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// deleteObjects deletes up to maxDeleteKeys
+// keys with a single multi-object delete request.
+func (b *Bucket) deleteObjects(ctx context.Context, keys []string) error {
+	type object struct {
+		Key string `xml:"Key"`
+	}
+	objects := make([]object, len(keys))
+	for i := range keys {
+		objects[i].Key = keys[i]
+	}
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Delete"`
+		Quiet   bool     `xml:"Quiet"`
+		Objects []object `xml:"Object"`
+	}{Quiet: true, Objects: objects})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURI(b.key, b.bkt, "?delete="), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(body)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	req.Header.Set("Content-Type", "application/xml")
+	// deleting the same keys again is harmless, so mark the
+	// request as idempotent; a nil value is not sent
+	req.Header["Idempotency-Key"] = nil
+	setUserAgent(req, b.UserAgent)
+	b.key.SignV4(req, body)
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return responseError("s3 DeleteObjects", res)
+	}
+	var result struct {
+		Errors []struct {
+			Key     string `xml:"Key"`
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		} `xml:"Error"`
+	}
+	if err := xml.NewDecoder(res.Body).Decode(&result); err != nil {
+		return fmt.Errorf("xml decoding response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		failed := make(map[string]error, len(result.Errors))
+		for _, e := range result.Errors {
+			failed[e.Key] = fmt.Errorf("%s %s", e.Code, e.Message)
+		}
+		return &KeyError{Op: "s3 DeleteObjects", Failed: failed}
 	}
+	return nil
+}
 
-	uploader := &uploader{
-		Key:    b.key,
-		Client: b.Client,
-		Bucket: b.bkt,
-		Object: key,
+// WriteFrom performs a multipart upload of data from an io.ReaderAt to the specified key.
+// Keys are cleaned and opts are interpreted in the same way as for Write.
+func (b *Bucket) WriteFrom(ctx context.Context, key string, r io.ReaderAt, size int64, opts ...UploadOptions) error {
+	_, err := b.WriteFromInfo(ctx, key, r, size, opts...)
+	return err
+}
+
+// WriteFromInfo performs a multipart upload in the same way as WriteFrom, but
+// returns the ETag, size and number of parts of the newly-created object. The
+// modification time and version of the object are not populated.
+func (b *Bucket) WriteFromInfo(ctx context.Context, key string, r io.ReaderAt, size int64, opts ...UploadOptions) (*ObjectInfo, error) {
+	uploader, err := b.newUploader(key, size, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	// Start multipart upload
 	if err := uploader.Start(ctx); err != nil {
-		return fmt.Errorf("starting multipart upload: %w", err)
+		return nil, fmt.Errorf("starting multipart upload: %w", err)
+	}
+
+	if err := uploader.UploadFrom(ctx, r, size); err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{
+		ETag:  uploader.ETag(),
+		Size:  uploader.Size(),
+		Parts: uploader.CompletedParts(),
+	}, nil
+}
+
+// UploadSeeker is like WriteFrom, but reads the data from an io.ReadSeeker
+// and returns the ETag of the new object. Each part is read by seeking to
+// its offset in rs, so that a part which fails to upload can be re-read and
+// retried without holding the entire contents of rs in memory. Reads from
+// rs are serialized, while the parts themselves are still uploaded in
+// parallel. The current offset of rs is not preserved.
+func (b *Bucket) UploadSeeker(ctx context.Context, key string, rs io.ReadSeeker, size int64, opts ...UploadOptions) (string, error) {
+	uploader, err := b.newUploader(key, size, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := uploader.Start(ctx); err != nil {
+		return "", fmt.Errorf("starting multipart upload: %w", err)
+	}
+	if err := uploader.UploadFrom(ctx, &seekReaderAt{rs: rs}, size); err != nil {
+		return "", err
+	}
+	return uploader.ETag(), nil
+}
+
+// WriteFromReader writes the contents of r to the specified key and returns
+// the ETag of the new object, without the caller having to know their size.
+// Keys are cleaned and opts are interpreted in the same way as for Write.
+//
+// If the size of the remaining contents of r can be determined, as for an
+// *os.File of a regular file, a *bytes.Reader or a *strings.Reader, then
+// contents smaller than the minimum part size are written with a single
+// PUT and larger ones with WriteFrom, reading r at its current offset with
+// ReadAt; the offset of r is not advanced in that case. Otherwise, r is
+// read sequentially, one part at a time, and its contents are uploaded as
+// they are read, which limits the object to MaxParts parts.
+func (b *Bucket) WriteFromReader(ctx context.Context, key string, r io.Reader, opts ...UploadOptions) (string, error) {
+	u, err := b.newUploader(key, 0, opts)
+	if err != nil {
+		return "", err
+	}
+	partSize := int64(u.MinPartSize())
+	if ra, size, ok := readerSize(r); ok {
+		if size < partSize {
+			buf := make([]byte, size)
+			if _, err := ra.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+				return "", err
+			}
+			return b.Write(ctx, key, buf, opts...)
+		}
+		info, err := b.WriteFromInfo(ctx, key, ra, size, opts...)
+		if err != nil {
+			return "", err
+		}
+		return info.ETag, nil
+	}
+
+	// the size is unknown, so read a first part
+	// to decide between a PUT and a multipart upload
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return b.Write(ctx, key, buf[:n], opts...)
+	case err != nil:
+		return "", err
+	}
+	if err := u.Start(ctx); err != nil {
+		return "", fmt.Errorf("starting multipart upload: %w", err)
 	}
+	if err := u.uploadReader(ctx, r, buf); err != nil {
+		u.Abort(context.WithoutCancel(ctx))
+		return "", err
+	}
+	return u.ETag(), nil
+}
+
+// readerSize returns r as an io.ReaderAt of its remaining
+// contents and their size, if that size can be determined.
+func readerSize(r io.Reader) (io.ReaderAt, int64, bool) {
+	switch r := r.(type) {
+	case interface {
+		io.ReaderAt
+		Len() int
+		Size() int64
+	}: // *bytes.Reader, *strings.Reader
+		size := int64(r.Len())
+		return io.NewSectionReader(r, r.Size()-size, size), size, true
+	case interface {
+		io.ReaderAt
+		io.Seeker
+		Stat() (fs.FileInfo, error)
+	}: // *os.File
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil, 0, false
+		}
+		off, err := r.Seek(0, io.SeekCurrent)
+		if err != nil || off > info.Size() {
+			return nil, 0, false
+		}
+		size := info.Size() - off
+		return io.NewSectionReader(r, off, size), size, true
+	}
+	return nil, 0, false
+}
+
+// seekReaderAt adapts an io.ReadSeeker to an io.ReaderAt
+// by seeking before every read.
+type seekReaderAt struct {
+	lock sync.Mutex
+	rs   io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(s.rs, p)
+}
 
+// ResumeUpload is like WriteFrom, but uploads the data to the multipart
+// upload with the given ID, which was initiated elsewhere (e.g. by another
+// process), rather than initiating a new one. The ID is validated before
+// any data is uploaded. The options that configure the new object must be
+// provided when the upload is initiated, so only the Tee and ChecksumSHA256
+// options are used. Every part of the data is uploaded, replacing the parts
+// with the same numbers, and parts numbered above those of the data are
+// left out of the object.
+func (b *Bucket) ResumeUpload(ctx context.Context, key, uploadID string, r io.ReaderAt, size int64, opts ...UploadOptions) error {
+	uploader, err := b.newUploader(key, size, opts)
+	if err != nil {
+		return err
+	}
+	if err := uploader.Resume(ctx, uploadID); err != nil {
+		return fmt.Errorf("resuming multipart upload: %w", err)
+	}
 	return uploader.UploadFrom(ctx, r, size)
 }
+
+// ListParts lists the parts that were uploaded so far to the
+// multipart upload of key with the given ID, in ascending order
+// of part number, e.g. to find out what is left to upload after
+// a crash before calling ResumeUpload. The returned error matches
+// fs.ErrNotExist if there is no such upload.
+func (b *Bucket) ListParts(ctx context.Context, key, uploadID string) ([]UploadedPart, error) {
+	key, err := b.cleanKey("s3 ListParts", key)
+	if err != nil {
+		return nil, err
+	}
+	u := &uploader{Key: b.key, Client: b.Client, Bucket: b.bkt, Object: key, UserAgent: b.UserAgent, Limiter: b.Limiter, Logger: b.Logger, stats: b.stats}
+	if err := u.init(); err != nil {
+		return nil, err
+	}
+	parts, err := u.listParts(ctx, "s3.ListParts", uploadID)
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) && e.StatusCode == http.StatusNotFound {
+			return nil, &fs.PathError{Op: "listparts", Path: key, Err: fmt.Errorf("%w: %w", fs.ErrNotExist, err)}
+		}
+		return nil, err
+	}
+	out := make([]UploadedPart, len(parts))
+	for i := range parts {
+		out[i] = UploadedPart{
+			PartNumber:     int(parts[i].Num),
+			Size:           parts[i].size,
+			ETag:           parts[i].ETag,
+			ChecksumSHA256: parts[i].Checksum,
+		}
+	}
+	return out, nil
+}
+
+// newUploader validates the arguments of a multipart
+// upload and returns an uploader for it.
+func (b *Bucket) newUploader(key string, size int64, opts []UploadOptions) (*uploader, error) {
+	key, err := b.cleanKey("s3 Upload", key)
+	if err != nil {
+		return nil, err
+	}
+	o, err := uploadOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	_, base := path.Split(key)
+	switch {
+	case !fs.ValidPath(key):
+		return nil, badpath("s3 Upload", key)
+	case base == ".":
+		return nil, badpath("s3 Upload", key)
+	case size < 0:
+		return nil, fmt.Errorf("size must be non-negative, got %d", size)
+	}
+
+	return &uploader{
+		Key:             b.key,
+		Client:          b.Client,
+		Bucket:          b.bkt,
+		Object:          key,
+		UserAgent:       b.UserAgent,
+		Limiter:         b.Limiter,
+		Logger:          b.Logger,
+		Options:         o,
+		MinPartOverride: b.MinPartOverride,
+		stats:           b.stats,
+	}, nil
+}
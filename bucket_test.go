@@ -18,15 +18,24 @@ package s3
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
 	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/kelindar/s3/aws"
@@ -257,6 +266,32 @@ func testWalkGlobRoot(t *testing.T, b *Bucket, prefix string) {
 	assert.True(t, found, "could not find %q in the bucket", name)
 }
 
+func TestBucket_ReadFile(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	b.Lazy = true
+	mockServer.PutObject("test/small.txt", []byte("just the bytes"))
+
+	var _ fs.ReadFileFS = b
+	data, err := fs.ReadFile(b, "test/small.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("just the bytes"), data)
+	assert.Len(t, mockServer.GetRequestsWithMethod("GET"), 1)
+	assert.False(t, mockServer.HasRequestWithMethod("HEAD"))
+
+	_, err = fs.ReadFile(b, "test/missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = fs.ReadFile(b, "../invalid")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
+
 func TestBucket_OpenRange(t *testing.T) {
 	bucket := "test-bucket"
 	mockServer := mock.New(bucket, "us-east-1")
@@ -327,6 +362,47 @@ func TestBucket_Remove(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestBucket_DeleteErrors(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+	mockServer.PutObject("test/protected.txt", []byte("protected"))
+
+	t.Run("permission", func(t *testing.T) {
+		mockServer.EnableErrorSimulation(mock.ErrorSimulation{PermissionErrors: true})
+		defer mockServer.DisableErrorSimulation()
+
+		err := b.Delete(ctx, "test/protected.txt")
+		assert.True(t, errors.Is(err, fs.ErrPermission))
+
+		var s3err *Error
+		if assert.True(t, errors.As(err, &s3err)) {
+			assert.Equal(t, "AccessDenied", s3err.Code)
+			assert.NotEmpty(t, s3err.RequestID)
+		}
+		assert.ErrorIs(t, b.DeleteRaw(ctx, "test/protected.txt"), fs.ErrPermission)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := b.Delete(ctx, "test/missing.txt")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err := b.Delete(ctx, "../invalid")
+		assert.True(t, errors.Is(err, fs.ErrInvalid))
+	})
+
+	_, exists := mockServer.GetObject("test/protected.txt")
+	assert.True(t, exists)
+}
+
 func TestBucket_Sub(t *testing.T) {
 	bucket := "test-bucket"
 	mockServer := mock.New(bucket, "us-east-1")
@@ -352,6 +428,106 @@ func TestBucket_Sub(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestBucket_OpenTrailingSlash(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	// an object and a prefix sharing the same name,
+	// and a sibling which shares a string prefix
+	for _, name := range []string{"foo", "foo/bar.txt", "foobar", "baz/qux.txt"} {
+		_, err := b.Write(ctx, name, []byte(name))
+		assert.NoError(t, err)
+	}
+
+	// objectFetches counts the GET and HEAD requests for an object
+	objectFetches := func(name string) (n int) {
+		for _, req := range mockServer.GetRequestLog() {
+			if (req.Method == "GET" || req.Method == "HEAD") && req.Path == "/"+bucket+"/"+name {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("object first", func(t *testing.T) {
+		f, err := b.Open("foo")
+		assert.NoError(t, err)
+		assert.IsType(t, &File{}, f)
+		info, err := f.Stat()
+		assert.NoError(t, err)
+		assert.False(t, info.IsDir())
+		assert.Equal(t, int64(3), info.Size())
+		f.Close()
+	})
+
+	t.Run("directory with slash", func(t *testing.T) {
+		before := objectFetches("foo")
+		f, err := b.Open("foo/")
+		assert.NoError(t, err)
+		assert.IsType(t, &Prefix{}, f)
+		info, err := f.Stat()
+		assert.NoError(t, err)
+		assert.True(t, info.IsDir())
+		assert.Equal(t, "foo", info.Name())
+
+		entries, err := f.(fs.ReadDirFile).ReadDir(-1)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "bar.txt", entries[0].Name())
+		assert.Equal(t, before, objectFetches("foo"), "Open with a trailing slash must not fetch the object")
+	})
+
+	t.Run("directory without object", func(t *testing.T) {
+		f, err := b.Open("baz")
+		assert.NoError(t, err)
+		assert.IsType(t, &Prefix{}, f)
+	})
+
+	t.Run("object with slash", func(t *testing.T) {
+		_, err := b.Open("foobar/")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+
+		_, err = b.Open("fo/")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = b.Open("fo")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("typed", func(t *testing.T) {
+		f, p, err := b.OpenTyped("foo")
+		assert.NoError(t, err)
+		assert.Nil(t, p)
+		if assert.NotNil(t, f) {
+			assert.Equal(t, "foo", f.Path())
+			f.Close()
+		}
+
+		f, p, err = b.OpenTyped("foo/")
+		assert.NoError(t, err)
+		assert.Nil(t, f)
+		if assert.NotNil(t, p) {
+			assert.Equal(t, "foo/", p.Path)
+		}
+
+		f, p, err = b.OpenTyped("baz")
+		assert.NoError(t, err)
+		assert.Nil(t, f)
+		assert.NotNil(t, p)
+
+		f, p, err = b.OpenTyped("fo")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		assert.Nil(t, f)
+		assert.Nil(t, p)
+	})
+}
+
 func TestBucket_DelayGet(t *testing.T) {
 	bucket := "test-bucket"
 	mockServer := mock.New(bucket, "us-east-1")
@@ -489,6 +665,73 @@ func TestBucket_WriteFrom(t *testing.T) {
 	assert.Equal(t, testData, content)
 }
 
+func TestBucket_WriteFromInfo(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	b.MinPartOverride = 1024
+
+	// 10 parts of 1KiB and a final part of 100 bytes
+	testData := make([]byte, 1024*10+100)
+	for i := range testData {
+		testData[i] = byte(i % 256)
+	}
+	assert.Equal(t, int64(1024), calculatePartSize(int64(len(testData)), 1024))
+
+	info, err := b.WriteFromInfo(context.Background(), "test/info.bin", bytes.NewReader(testData), int64(len(testData)))
+	assert.NoError(t, err)
+	assert.Equal(t, 11, info.Parts)
+	assert.Equal(t, int64(len(testData)), info.Size)
+
+	obj, ok := mockServer.GetObject("test/info.bin")
+	assert.True(t, ok)
+	assert.NotEmpty(t, info.ETag)
+	assert.Equal(t, obj.ETag, info.ETag)
+}
+
+func TestBucket_MinPartOverride(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	b.MinPartOverride = 1 << 20
+	ctx := context.Background()
+
+	testData := make([]byte, 3<<20)
+	for i := range testData {
+		testData[i] = byte(i % 256)
+	}
+	assert.NoError(t, b.WriteFrom(ctx, "test/small-parts.bin", bytes.NewReader(testData), int64(len(testData))))
+
+	content, found := mockServer.ObjectContent("test/small-parts.bin")
+	assert.True(t, found)
+	assert.Equal(t, testData, content)
+
+	attrs, err := b.Attributes(ctx, "test/small-parts.bin", []string{AttrObjectParts})
+	assert.NoError(t, err)
+	if assert.NotNil(t, attrs.ObjectParts) {
+		assert.Equal(t, 3, attrs.ObjectParts.PartsCount)
+		for _, part := range attrs.ObjectParts.Parts {
+			assert.Equal(t, int64(1<<20), part.Size)
+		}
+	}
+
+	u := &uploader{Key: key, Bucket: bucket, Object: "test/direct.bin", MinPartOverride: 1 << 20}
+	assert.NoError(t, u.Start(ctx))
+	assert.NoError(t, u.Upload(1, testData[:1<<20]))
+	assert.Error(t, u.Upload(2, testData[:1000]))
+	assert.NoError(t, u.Abort(ctx))
+}
+
 func TestBucketListContext(t *testing.T) {
 	mockServer := mock.New("test-bucket", "us-east-1")
 	defer mockServer.Close()
@@ -598,3 +841,1811 @@ func TestCancel(t *testing.T) {
 		assert.Contains(t, err.Error(), "context canceled")
 	})
 }
+
+func TestBucket_UserAgent(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	b.UserAgent = "my-agent/2.0"
+
+	_, err := b.Write(context.Background(), "ua/file.txt", []byte("hello"))
+	assert.NoError(t, err)
+
+	f, err := b.Open("ua/file.txt")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	_, err = b.ReadDir("ua")
+	assert.NoError(t, err)
+
+	logs := mockServer.GetRequestLog()
+	assert.Len(t, logs, 3)
+	for _, log := range logs {
+		assert.Equal(t, "my-agent/2.0", log.Headers["User-Agent"], "%s %s", log.Method, log.Path)
+	}
+
+	// default agent is used when none is configured
+	mockServer.Clear()
+	b.UserAgent = ""
+	_, err = b.Write(context.Background(), "ua/file.txt", []byte("hello"))
+	assert.NoError(t, err)
+	logs = mockServer.GetRequestLog()
+	assert.Len(t, logs, 1)
+	assert.Equal(t, DefaultUserAgent, logs[0].Headers["User-Agent"])
+}
+
+// intervalLimiter admits one request every interval.
+type intervalLimiter struct {
+	lock     sync.Mutex
+	interval time.Duration
+	next     time.Time
+	waits    atomic.Int32
+}
+
+func (l *intervalLimiter) Wait(ctx context.Context) error {
+	l.waits.Add(1)
+	l.lock.Lock()
+	now, at := time.Now(), l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.lock.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(at.Sub(now)):
+		return nil
+	}
+}
+
+func TestBucket_Limiter(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	const n, interval = 5, 20 * time.Millisecond
+	lim := &intervalLimiter{interval: interval}
+	b := NewBucket(key, bucket)
+	b.Limiter = lim
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		_, err := b.Write(ctx, fmt.Sprintf("limit/%d.txt", i), []byte("data"))
+		assert.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), (n-1)*interval)
+	assert.Equal(t, int32(n), lim.waits.Load())
+
+	t.Run("multipart", func(t *testing.T) {
+		lim.waits.Store(0)
+		data := make([]byte, MinPartSize+1000)
+		assert.NoError(t, b.WriteFrom(ctx, "limit/large.bin", bytes.NewReader(data), int64(len(data))))
+		assert.Equal(t, int32(4), lim.waits.Load()) // start, two parts and complete
+	})
+
+	t.Run("reads", func(t *testing.T) {
+		lim.waits.Store(0)
+		f, err := b.Open("limit/0.txt")
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+		_, err = fs.ReadDir(b, "limit")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), lim.waits.Load())
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		b := NewBucket(key, bucket)
+		b.Limiter = &intervalLimiter{interval: time.Hour, next: time.Now().Add(time.Hour)}
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := b.Write(ctx, "limit/cancel.txt", []byte("data"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+// captureHandler is a slog.Handler that records every log record.
+type captureHandler struct {
+	lock    sync.Mutex
+	records []slog.Record
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *captureHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.records = append(h.records, r.Clone())
+	return nil
+}
+
+// attrs returns the attributes of every record,
+// keyed by name and formatted as strings.
+func (h *captureHandler) attrs() []map[string]string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	out := make([]map[string]string, len(h.records))
+	for i, r := range h.records {
+		out[i] = make(map[string]string)
+		r.Attrs(func(a slog.Attr) bool {
+			out[i][a.Key] = a.Value.String()
+			return true
+		})
+	}
+	return out
+}
+
+func TestBucket_Logger(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	handler := new(captureHandler)
+	b := NewBucket(key, bucket)
+	b.Logger = slog.New(handler)
+	ctx := context.Background()
+
+	_, err := b.Write(ctx, "log/file.txt", []byte("hello"))
+	assert.NoError(t, err)
+	f, err := b.Open("log/file.txt")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	_, err = b.ReadDir("log")
+	assert.NoError(t, err)
+	_, err = b.ReadFile("log/missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	mockServer.EnableErrorSimulation(mock.ErrorSimulation{InternalErrors: true, ErrorRate: 1})
+	_, err = b.ReadFile("log/file.txt")
+	assert.Error(t, err)
+	mockServer.DisableErrorSimulation()
+
+	records := handler.attrs()
+	requests := mockServer.GetRequestLog()
+	assert.Len(t, records, 5)
+	assert.Len(t, requests, 6)
+	for i, want := range []struct{ method, status, retries string }{
+		{"PUT", "200", "0"},
+		{"GET", "200", "0"},
+		{"GET", "200", "0"},
+		{"GET", "404", "0"},
+		{"GET", "500", "1"},
+	} {
+		if i >= len(records) {
+			break
+		}
+		got := records[i]
+		assert.Equal(t, want.method, got["method"])
+		assert.Equal(t, want.status, got["status"])
+		assert.Equal(t, want.retries, got["retries"])
+		assert.Contains(t, got["url"], mockServer.URL())
+		assert.NotEmpty(t, got["duration"])
+	}
+	for _, r := range records {
+		for _, v := range r {
+			assert.NotContains(t, v, "fake-secret-key")
+			assert.NotContains(t, v, "fake-access-key")
+			assert.NotContains(t, v, "Signature")
+		}
+	}
+
+	t.Run("presigned", func(t *testing.T) {
+		signed, err := key.SignURL(mockServer.URL()+"/"+bucket+"/log/file.txt", time.Minute)
+		assert.NoError(t, err)
+		u, err := url.Parse(signed)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+
+		redacted := redactURL(u)
+		assert.NotContains(t, redacted, u.Query().Get("X-Amz-Signature"))
+		assert.NotContains(t, redacted, "fake-access-key")
+		assert.Contains(t, redacted, "X-Amz-Signature=REDACTED")
+		assert.Contains(t, redacted, "X-Amz-Expires=60")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		b.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		_, err := b.ReadFile("log/file.txt")
+		assert.NoError(t, err)
+		assert.Len(t, handler.attrs(), 5)
+	})
+}
+
+func TestBucket_StrictKeys(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	// by default, keys are cleaned and accepted
+	_, err := b.Write(ctx, "a//b", []byte("content"))
+	assert.NoError(t, err)
+	assert.True(t, mockServer.ObjectExists("a/b"))
+
+	// in strict mode, keys changed by cleaning are rejected
+	b.StrictKeys = true
+	_, err = b.Write(ctx, "c//d", []byte("content"))
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+	assert.False(t, mockServer.ObjectExists("c/d"))
+
+	err = b.WriteFrom(ctx, "c/./d", bytes.NewReader([]byte("content")), 7)
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+
+	// clean keys are still accepted
+	_, err = b.Write(ctx, "c/d", []byte("content"))
+	assert.NoError(t, err)
+	assert.True(t, mockServer.ObjectExists("c/d"))
+}
+
+func TestBucket_RawKeys(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	for _, name := range []string{"a/./b", "x//y"} {
+		contents := []byte("raw contents of " + name)
+		etag, err := b.WriteRaw(ctx, name, contents)
+		assert.NoError(t, err)
+		assert.True(t, mockServer.ObjectExists(name), name)
+
+		f, err := b.OpenRaw(name)
+		assert.NoError(t, err)
+		assert.Equal(t, etag, f.ETag)
+		got, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, contents, got)
+		assert.NoError(t, f.Close())
+
+		assert.NoError(t, b.DeleteRaw(ctx, name))
+		assert.False(t, mockServer.ObjectExists(name), name)
+	}
+
+	_, err := b.WriteRaw(ctx, "", nil)
+	assert.Error(t, err)
+	_, err = b.OpenRaw("")
+	assert.Error(t, err)
+}
+
+func TestBucket_DeleteIfMatch(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	t.Run("match", func(t *testing.T) {
+		etag := mockServer.PutObject("cond/match.txt", []byte("match"))
+		assert.NoError(t, b.DeleteIfMatch(ctx, "cond/match.txt", etag))
+		assert.False(t, mockServer.ObjectExists("cond/match.txt"))
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		mockServer.PutObject("cond/mismatch.txt", []byte("mismatch"))
+		err := b.DeleteIfMatch(ctx, "cond/mismatch.txt", `"stale"`)
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+		assert.True(t, mockServer.ObjectExists("cond/mismatch.txt"))
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		// emulate a backend that does not implement conditional deletes
+		fallback := NewBucket(key, bucket)
+		fallback.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodDelete && req.Header.Get("If-Match") != "" {
+				return &http.Response{
+					StatusCode: http.StatusNotImplemented,
+					Status:     "501 Not Implemented",
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    req,
+				}, nil
+			}
+			return DefaultClient.Transport.RoundTrip(req)
+		})}
+
+		etag := mockServer.PutObject("cond/fallback.txt", []byte("fallback"))
+		err := fallback.DeleteIfMatch(ctx, "cond/fallback.txt", `"stale"`)
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+		assert.True(t, mockServer.ObjectExists("cond/fallback.txt"))
+
+		assert.NoError(t, fallback.DeleteIfMatch(ctx, "cond/fallback.txt", etag))
+		assert.False(t, mockServer.ObjectExists("cond/fallback.txt"))
+	})
+}
+
+func TestBucket_HeadBucket(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	t.Run("exists", func(t *testing.T) {
+		b := NewBucket(key, bucket)
+		assert.NoError(t, b.HeadBucket(context.Background()))
+	})
+
+	t.Run("wrong bucket", func(t *testing.T) {
+		b := NewBucket(key, "other-bucket")
+		err := b.HeadBucket(context.Background())
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("permission", func(t *testing.T) {
+		mockServer.EnableErrorSimulation(mock.ErrorSimulation{PermissionErrors: true})
+		defer mockServer.DisableErrorSimulation()
+
+		b := NewBucket(key, bucket)
+		err := b.HeadBucket(context.Background())
+		assert.ErrorIs(t, err, fs.ErrPermission)
+	})
+}
+
+func TestBucket_ErrorMapper(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	mockServer.PutObject("dir/file.txt", []byte("hello"))
+
+	// the backend denies access to every request,
+	// as some do for missing keys
+	mockServer.EnableErrorSimulation(mock.ErrorSimulation{PermissionErrors: true})
+	defer mockServer.DisableErrorSimulation()
+
+	var codes []string
+	b := NewBucket(key, bucket)
+	b.ErrorMapper = func(status int, code string) error {
+		codes = append(codes, code)
+		if status == http.StatusForbidden {
+			return fs.ErrNotExist
+		}
+		return nil
+	}
+
+	t.Run("open", func(t *testing.T) {
+		// the GET is mapped to fs.ErrNotExist, so Open
+		// falls back to listing the directory "dir/missing.txt/"
+		codes = nil
+		_, err := b.Open("dir/missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		assert.Equal(t, []string{"AccessDenied", "AccessDenied"}, codes)
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		b.Lazy = true
+		defer func() { b.Lazy = false }()
+		_, err := fs.Stat(b, "dir/missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		_, err := fs.ReadDir(b, "dir")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		sub, err := fs.Sub(b, "dir")
+		assert.NoError(t, err)
+		_, err = sub.Open("missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		b := NewBucket(key, bucket)
+		_, err := b.Open("dir/missing.txt")
+		assert.ErrorIs(t, err, fs.ErrPermission)
+
+		// returning nil keeps the default mapping
+		b.ErrorMapper = func(int, string) error { return nil }
+		_, err = b.Open("dir/missing.txt")
+		assert.ErrorIs(t, err, fs.ErrPermission)
+		_, err = fs.ReadDir(b, "dir")
+		assert.ErrorIs(t, err, fs.ErrPermission)
+	})
+}
+
+func TestBucket_Ping(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	ctx := context.Background()
+
+	t.Run("ok", func(t *testing.T) {
+		assert.NoError(t, NewBucket(key, bucket).Ping(ctx))
+		reqs := mockServer.GetRequestsWithMethod("GET")
+		if assert.NotEmpty(t, reqs) {
+			assert.Contains(t, reqs[len(reqs)-1].Query, "max-keys=1")
+		}
+	})
+
+	t.Run("wrong bucket", func(t *testing.T) {
+		err := NewBucket(key, "other-bucket").Ping(ctx)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		assert.NotErrorIs(t, err, ErrUnreachable)
+	})
+
+	t.Run("permission", func(t *testing.T) {
+		mockServer.EnableErrorSimulation(mock.ErrorSimulation{SignatureErrors: true})
+		defer mockServer.DisableErrorSimulation()
+
+		err := NewBucket(key, bucket).Ping(ctx)
+		assert.ErrorIs(t, err, fs.ErrPermission)
+		var s3err *Error
+		if assert.ErrorAs(t, err, &s3err) {
+			assert.Equal(t, "SignatureDoesNotMatch", s3err.Code)
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		closed := mock.New(bucket, "us-east-1")
+		closed.Close()
+
+		k := *key
+		k.BaseURI = closed.URL()
+		err := NewBucket(&k, bucket).Ping(ctx)
+		assert.ErrorIs(t, err, ErrUnreachable)
+		assert.NotErrorIs(t, err, fs.ErrPermission)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		err := NewBucket(key, bucket).Ping(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, ErrUnreachable)
+	})
+}
+
+func TestBucket_ListParts(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	ctx := context.Background()
+	b := NewBucket(key, bucket)
+
+	t.Run("parts", func(t *testing.T) {
+		u := &uploader{Key: key, Bucket: bucket, Object: "parts/crashed.bin", MinPartOverride: 1024}
+		assert.NoError(t, u.Start(ctx))
+		assert.NoError(t, u.Upload(2, bytes.Repeat([]byte{2}, 1500)))
+		assert.NoError(t, u.Upload(1, bytes.Repeat([]byte{1}, 1024)))
+
+		parts, err := b.ListParts(ctx, "parts/crashed.bin", u.ID())
+		assert.NoError(t, err)
+		if assert.Len(t, parts, 2) {
+			assert.Equal(t, 1, parts[0].PartNumber)
+			assert.Equal(t, int64(1024), parts[0].Size)
+			assert.Equal(t, 2, parts[1].PartNumber)
+			assert.Equal(t, int64(1500), parts[1].Size)
+			assert.NotEmpty(t, parts[0].ETag)
+			assert.NotEqual(t, parts[0].ETag, parts[1].ETag)
+		}
+	})
+
+	t.Run("no parts", func(t *testing.T) {
+		id := mockServer.CreateMultipartUpload("parts/empty.bin")
+		parts, err := b.ListParts(ctx, "parts/empty.bin", id)
+		assert.NoError(t, err)
+		assert.Empty(t, parts)
+	})
+
+	t.Run("no upload", func(t *testing.T) {
+		_, err := b.ListParts(ctx, "parts/missing.bin", "no-such-upload")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		var s3err *Error
+		if assert.ErrorAs(t, err, &s3err) {
+			assert.Equal(t, "NoSuchUpload", s3err.Code)
+		}
+	})
+}
+
+func TestBucket_RemoveAll(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+
+	for i := 0; i < 1500; i++ {
+		mockServer.PutObject(fmt.Sprintf("cache/page/%04d.bin", i), []byte("x"))
+	}
+	mockServer.PopulateTestData(map[string][]byte{
+		"cache/a.txt":       []byte("a"),
+		"cache/b/c.txt":     []byte("c"),
+		"cache/b/d/e.txt":   []byte("e"),
+		"cache-other/f.txt": []byte("f"),
+		"keep.txt":          []byte("k"),
+	})
+
+	n, err := b.RemoveAll(context.Background(), "cache")
+	assert.NoError(t, err)
+	assert.Equal(t, 1503, n)
+	assert.Empty(t, mockServer.ListObjects("cache/"))
+	assert.ElementsMatch(t, []string{"cache-other/f.txt", "keep.txt"}, mockServer.ListObjects(""))
+
+	n, err = b.RemoveAll(context.Background(), "cache")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	_, err = b.RemoveAll(context.Background(), "../x")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestBucket_WriteInfo(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+
+	content := []byte("catalog entry")
+	before := time.Now().Add(-time.Second)
+	info, err := b.WriteInfo(context.Background(), "info/a.txt", content)
+	assert.NoError(t, err)
+
+	obj, ok := mockServer.GetObject("info/a.txt")
+	assert.True(t, ok)
+	assert.Equal(t, content, obj.Content)
+	assert.Equal(t, obj.ETag, info.ETag)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.False(t, info.LastModified.Before(before.Truncate(time.Second)))
+	assert.Empty(t, info.VersionID)
+
+	_, err = b.WriteInfo(context.Background(), "../a.txt", content)
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestBucket_Encryption(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+
+	opts := UploadOptions{
+		Encryption: "aws:kms",
+		KMSKeyID:   "alias/test",
+		KMSContext: map[string]string{"tenant": "acme"},
+		BucketKey:  true,
+	}
+	wantContext := base64.StdEncoding.EncodeToString([]byte(`{"tenant":"acme"}`))
+
+	t.Run("multipart", func(t *testing.T) {
+		data := make([]byte, MinPartSize+1000)
+		err := b.WriteFrom(context.Background(), "enc/large.bin", bytes.NewReader(data), int64(len(data)), opts)
+		assert.NoError(t, err)
+
+		var initiated bool
+		for _, req := range mockServer.GetRequestLog() {
+			if req.Method == "POST" && req.Query == "uploads=" {
+				initiated = true
+				assert.Equal(t, "aws:kms", req.Headers["X-Amz-Server-Side-Encryption"])
+				assert.Equal(t, wantContext, req.Headers["X-Amz-Server-Side-Encryption-Context"])
+				assert.Equal(t, "true", req.Headers["X-Amz-Server-Side-Encryption-Bucket-Key-Enabled"])
+			}
+		}
+		assert.True(t, initiated)
+
+		f, err := b.Open("enc/large.bin")
+		assert.NoError(t, err)
+		assert.True(t, f.(*File).BucketKey)
+		assert.NoError(t, f.Close())
+	})
+
+	t.Run("put", func(t *testing.T) {
+		info, err := b.WriteInfo(context.Background(), "enc/small.txt", []byte("hello"), opts)
+		assert.NoError(t, err)
+		assert.True(t, info.BucketKey)
+
+		info, err = b.WriteInfo(context.Background(), "enc/plain.txt", []byte("hello"))
+		assert.NoError(t, err)
+		assert.False(t, info.BucketKey)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := b.Write(context.Background(), "enc/bad.txt", []byte("x"), UploadOptions{BucketKey: true})
+		assert.Error(t, err)
+	})
+}
+
+func TestBucket_ContentHeaders(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+	opts := UploadOptions{
+		CacheControl:       "max-age=3600",
+		ContentDisposition: `attachment; filename="report.csv"`,
+	}
+
+	t.Run("put", func(t *testing.T) {
+		_, err := b.Write(ctx, "assets/report.csv", []byte("a,b,c"), opts)
+		assert.NoError(t, err)
+
+		f, err := b.Open("assets/report.csv")
+		assert.NoError(t, err)
+		assert.Equal(t, "max-age=3600", f.(*File).CacheControl)
+		assert.Equal(t, `attachment; filename="report.csv"`, f.(*File).ContentDisposition)
+		assert.NoError(t, f.Close())
+
+		b.Lazy = true
+		defer func() { b.Lazy = false }()
+		f, err = b.Open("assets/report.csv")
+		assert.NoError(t, err)
+		assert.Equal(t, "max-age=3600", f.(*File).CacheControl)
+		assert.NoError(t, f.Close())
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		data := make([]byte, MinPartSize+1000)
+		assert.NoError(t, b.WriteFrom(ctx, "assets/large.bin", bytes.NewReader(data), int64(len(data)), opts))
+
+		f, err := b.Open("assets/large.bin")
+		assert.NoError(t, err)
+		assert.Equal(t, "max-age=3600", f.(*File).CacheControl)
+		assert.Equal(t, `attachment; filename="report.csv"`, f.(*File).ContentDisposition)
+		assert.NoError(t, f.Close())
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		_, err := b.Write(ctx, "assets/plain.txt", []byte("plain"))
+		assert.NoError(t, err)
+
+		f, err := b.Open("assets/plain.txt")
+		assert.NoError(t, err)
+		assert.Empty(t, f.(*File).CacheControl)
+		assert.Empty(t, f.(*File).ContentDisposition)
+		assert.NoError(t, f.Close())
+	})
+}
+
+func TestBucket_ExtraHeaders(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+	opts := UploadOptions{
+		CacheControl: "max-age=60",
+		ExtraHeaders: http.Header{
+			"Content-Language":                {"de-DE"},
+			"X-Amz-Website-Redirect-Location": {"/new/page.html"},
+		},
+	}
+
+	// signed checks that the last request with the method
+	// and query signed the headers
+	signed := func(t *testing.T, method, query string, headers ...string) {
+		var auth string
+		for _, r := range mockServer.GetRequestsWithMethod(method) {
+			if r.Query == query {
+				auth = r.Headers["Authorization"]
+			}
+		}
+		for _, h := range headers {
+			assert.Contains(t, auth, h)
+		}
+	}
+
+	t.Run("put", func(t *testing.T) {
+		_, err := b.Write(ctx, "pages/old.html", []byte("<html/>"), opts)
+		assert.NoError(t, err)
+		signed(t, http.MethodPut, "", "content-language", "x-amz-website-redirect-location")
+
+		f, err := b.Open("pages/old.html")
+		assert.NoError(t, err)
+		defer f.Close()
+		h := f.(*File).Headers
+		assert.Equal(t, "de-DE", h.Get("Content-Language"))
+		assert.Equal(t, "/new/page.html", h.Get("X-Amz-Website-Redirect-Location"))
+		assert.Equal(t, "max-age=60", h.Get("Cache-Control"))
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		data := make([]byte, MinPartSize+1000)
+		assert.NoError(t, b.WriteFrom(ctx, "pages/large.bin", bytes.NewReader(data), int64(len(data)), opts))
+		signed(t, http.MethodPost, "uploads=", "content-language", "x-amz-website-redirect-location")
+
+		r, err := Stat(key, bucket, "pages/large.bin")
+		assert.NoError(t, err)
+		assert.Equal(t, "de-DE", r.Headers.Get("Content-Language"))
+		assert.Equal(t, "/new/page.html", r.Headers.Get("X-Amz-Website-Redirect-Location"))
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		_, err := b.Write(ctx, "pages/cached.html", []byte("<html/>"), UploadOptions{
+			CacheControl: "no-store",
+			ExtraHeaders: http.Header{"Cache-Control": {"max-age=60"}},
+		})
+		assert.NoError(t, err)
+
+		r, err := Stat(key, bucket, "pages/cached.html")
+		assert.NoError(t, err)
+		assert.Equal(t, "no-store", r.CacheControl)
+	})
+
+	t.Run("reserved", func(t *testing.T) {
+		_, err := b.Write(ctx, "pages/bad.html", []byte("<html/>"), UploadOptions{
+			ExtraHeaders: http.Header{"Authorization": {"none"}},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestBucket_Expiration(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("expires", func(t *testing.T) {
+		_, err := b.Write(ctx, "cache/item.bin", []byte("transient"), UploadOptions{Expires: expires})
+		assert.NoError(t, err)
+
+		req := mockServer.GetRequestsWithMethod("PUT")
+		assert.Equal(t, "Wed, 02 Jan 2030 03:04:05 GMT", req[len(req)-1].Headers["Expires"])
+
+		f, err := b.Open("cache/item.bin")
+		assert.NoError(t, err)
+		defer f.Close()
+		r := f.(*File).Reader
+		assert.True(t, expires.Equal(r.Expires))
+		if assert.NotNil(t, r.Expiration) {
+			assert.True(t, expires.Equal(r.Expiration.Date))
+			assert.Equal(t, mock.ExpirationRule, r.Expiration.RuleID)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		_, err := b.Write(ctx, "cache/plain.bin", []byte("kept"))
+		assert.NoError(t, err)
+
+		f, err := b.Open("cache/plain.bin")
+		assert.NoError(t, err)
+		defer f.Close()
+		assert.True(t, f.(*File).Expires.IsZero())
+		assert.Nil(t, f.(*File).Expiration)
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		e := parseExpiration(`expiry-date="Fri, 23 Dec 2012 00:00:00 GMT", rule-id="picture-deletion-rule"`)
+		if assert.NotNil(t, e) {
+			assert.Equal(t, time.Date(2012, 12, 23, 0, 0, 0, 0, time.UTC), e.Date)
+			assert.Equal(t, "picture-deletion-rule", e.RuleID)
+		}
+		assert.Nil(t, parseExpiration(""))
+		assert.Nil(t, parseExpiration(`rule-id="no-date"`))
+	})
+}
+
+func TestBucket_Warmup(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	var dials atomic.Int32
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: 3,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+
+	b := NewBucket(key, bucket)
+	b.Client = &http.Client{Transport: transport}
+
+	assert.NoError(t, b.Warmup(context.Background(), 10))
+	warm := dials.Load()
+	assert.True(t, warm >= 1 && warm <= 3, "unexpected dials %d", warm)
+
+	// subsequent requests reuse the warm connections
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, b.HeadBucket(context.Background()))
+	}
+	assert.Equal(t, warm, dials.Load())
+
+	// errors are reported
+	other := NewBucket(key, "other-bucket")
+	other.Client = b.Client
+	assert.ErrorIs(t, other.Warmup(context.Background(), 2), fs.ErrNotExist)
+}
+
+func TestBucket_RequestID(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	ctx := context.Background()
+
+	// assertRequestID checks that err exposes the request IDs of the failed response
+	assertRequestID := func(t *testing.T, err error, status int) {
+		var s3err *Error
+		if assert.ErrorAs(t, err, &s3err) {
+			assert.Equal(t, status, s3err.StatusCode)
+			assert.NotEmpty(t, s3err.RequestID)
+			assert.NotEmpty(t, s3err.HostID)
+			assert.Contains(t, err.Error(), s3err.RequestID)
+		}
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		err := NewBucket(key, "other-bucket").Delete(ctx, "a.txt")
+		assertRequestID(t, err, http.StatusNotFound)
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		data := make([]byte, MinPartSize+1)
+		err := NewBucket(key, "other-bucket").WriteFrom(ctx, "a.bin", bytes.NewReader(data), int64(len(data)))
+		assertRequestID(t, err, http.StatusNotFound)
+	})
+
+	t.Run("write and list", func(t *testing.T) {
+		mockServer.EnableErrorSimulation(mock.ErrorSimulation{InternalErrors: true})
+		defer mockServer.DisableErrorSimulation()
+
+		b := NewBucket(key, bucket)
+		_, err := b.Write(ctx, "a.txt", []byte("a"))
+		assertRequestID(t, err, http.StatusInternalServerError)
+
+		_, err = b.ReadDir(".")
+		assertRequestID(t, err, http.StatusInternalServerError)
+	})
+}
+
+func TestBucket_RenamePrefix(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	ctx := context.Background()
+
+	t.Run("tree", func(t *testing.T) {
+		mockServer.PopulateTestData(map[string][]byte{
+			"old/a.txt":     []byte("a"),
+			"old/b/c.txt":   []byte("c"),
+			"old/b/d/e.txt": []byte("e"),
+			"older/f.txt":   []byte("f"),
+		})
+		b := NewBucket(key, bucket)
+
+		n, err := b.RenamePrefix(ctx, "old", "new/place")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Empty(t, mockServer.ListObjects("old/"))
+		assert.ElementsMatch(t, []string{
+			"new/place/a.txt",
+			"new/place/b/c.txt",
+			"new/place/b/d/e.txt",
+		}, mockServer.ListObjects("new/"))
+		assert.True(t, mockServer.ObjectExists("older/f.txt"))
+
+		content, ok := mockServer.ObjectContent("new/place/b/d/e.txt")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("e"), content)
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		mockServer.Clear()
+		mockServer.PopulateTestData(map[string][]byte{
+			"src/ok.txt":  []byte("ok"),
+			"src/bad.txt": []byte("bad"),
+		})
+		b := NewBucket(key, bucket)
+		b.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("x-amz-copy-source") == "/"+bucket+"/src/bad.txt" {
+				return &http.Response{
+					StatusCode: http.StatusForbidden,
+					Status:     "403 Forbidden",
+					Body:       io.NopCloser(strings.NewReader("<Error><Code>AccessDenied</Code></Error>")),
+					Header:     make(http.Header),
+					Request:    req,
+				}, nil
+			}
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+
+		n, err := b.RenamePrefix(ctx, "src", "dst")
+		assert.Equal(t, 1, n)
+		var kerr *KeyError
+		if assert.ErrorAs(t, err, &kerr) {
+			assert.Len(t, kerr.Failed, 1)
+			assert.Contains(t, kerr.Failed, "src/bad.txt")
+		}
+		assert.Equal(t, []string{"src/bad.txt"}, mockServer.ListObjects("src/"))
+		assert.Equal(t, []string{"dst/ok.txt"}, mockServer.ListObjects("dst/"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		b := NewBucket(key, bucket)
+		_, err := b.RenamePrefix(ctx, "a", "a/b")
+		assert.Error(t, err)
+		_, err = b.RenamePrefix(ctx, ".", "b")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+}
+
+func TestBucket_Copy(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+
+	etag := mockServer.PutObject("a b+c.txt", []byte("hello"))
+	copied, err := b.Copy(context.Background(), "a b+c.txt", "copy/x.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, etag, copied)
+
+	content, ok := mockServer.ObjectContent("copy/x.txt")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("hello"), content)
+
+	_, err = b.Copy(context.Background(), "missing.txt", "copy/y.txt")
+	var s3err *Error
+	assert.ErrorAs(t, err, &s3err)
+	assert.Equal(t, "NoSuchKey", s3err.Code)
+}
+
+func TestBucket_CopyIfAbsent(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	etag := mockServer.PutObject("src.txt", []byte("hello"))
+
+	t.Run("absent", func(t *testing.T) {
+		copied, got, err := b.CopyIfAbsent(ctx, "src.txt", "dst/absent.txt")
+		assert.NoError(t, err)
+		assert.True(t, copied)
+		assert.Equal(t, etag, got)
+
+		content, ok := mockServer.ObjectContent("dst/absent.txt")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("hello"), content)
+
+		req := mockServer.GetRequestsWithMethod("PUT")
+		assert.Equal(t, "*", req[len(req)-1].Headers["If-None-Match"])
+	})
+
+	t.Run("present", func(t *testing.T) {
+		mockServer.PutObject("dst/present.txt", []byte("existing"))
+		copied, got, err := b.CopyIfAbsent(ctx, "src.txt", "dst/present.txt")
+		assert.NoError(t, err)
+		assert.False(t, copied)
+		assert.Empty(t, got)
+
+		content, ok := mockServer.ObjectContent("dst/present.txt")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("existing"), content)
+	})
+
+	t.Run("repeated", func(t *testing.T) {
+		copied, _, err := b.CopyIfAbsent(ctx, "src.txt", "dst/absent.txt")
+		assert.NoError(t, err)
+		assert.False(t, copied)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := b.CopyIfAbsent(ctx, "missing.txt", "dst/missing.txt")
+		var s3err *Error
+		assert.ErrorAs(t, err, &s3err)
+		assert.Equal(t, "NoSuchKey", s3err.Code)
+
+		_, _, err = b.CopyIfAbsent(ctx, "src.txt", ".")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+}
+
+func TestBucket_CopyOptions(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	mockServer.PutObjectWithMetadata("src.txt", []byte("hello"), map[string]string{"owner": "alice"})
+
+	t.Run("copy", func(t *testing.T) {
+		_, err := b.Copy(ctx, "src.txt", "copied.bin")
+		assert.NoError(t, err)
+		obj, ok := mockServer.GetObject("copied.bin")
+		assert.True(t, ok)
+		assert.Equal(t, "text/plain", obj.ContentType)
+		assert.Equal(t, map[string]string{"owner": "alice"}, obj.Metadata)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		_, err := b.Copy(ctx, "src.txt", "replaced.txt", CopyOptions{
+			MetadataDirective: "REPLACE",
+			ContentType:       "application/json",
+			Metadata:          map[string]string{"owner": "bob"},
+		})
+		assert.NoError(t, err)
+		obj, ok := mockServer.GetObject("replaced.txt")
+		assert.True(t, ok)
+		assert.Equal(t, "application/json", obj.ContentType)
+		assert.Equal(t, map[string]string{"owner": "bob"}, obj.Metadata)
+		assert.Equal(t, []byte("hello"), obj.Content)
+
+		req := mockServer.GetRequestsWithMethod("PUT")
+		assert.Equal(t, "REPLACE", req[len(req)-1].Headers["X-Amz-Metadata-Directive"])
+		signed := signedHeaders(req[len(req)-1])
+		assert.Contains(t, signed, "x-amz-metadata-directive")
+		assert.Contains(t, signed, "x-amz-meta-owner")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := b.Copy(ctx, "src.txt", "x.txt", CopyOptions{ContentType: "text/html"})
+		assert.Error(t, err)
+		_, err = b.Copy(ctx, "src.txt", "x.txt", CopyOptions{MetadataDirective: "MERGE"})
+		assert.Error(t, err)
+		assert.False(t, mockServer.ObjectExists("x.txt"))
+	})
+}
+
+func TestBucket_ObjectURL(t *testing.T) {
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-west-2", "s3")
+
+	t.Run("virtual-host", func(t *testing.T) {
+		u, err := NewBucket(key, "test-bucket").ObjectURL("dir/a b+c.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://test-bucket.s3.us-west-2.amazonaws.com/dir/a%20b%2Bc.txt", u)
+	})
+
+	t.Run("path-style", func(t *testing.T) {
+		u, err := NewBucket(key, "my.dotted.bucket").ObjectURL("dir/x.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://s3.us-west-2.amazonaws.com/my.dotted.bucket/dir/x.txt", u)
+	})
+
+	t.Run("base-uri", func(t *testing.T) {
+		custom := aws.DeriveKey("http://localhost:9000", "fake-access-key", "fake-secret-key", "us-west-2", "s3")
+		u, err := NewBucket(custom, "test-bucket").ObjectURL("dir/x.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost:9000/test-bucket/dir/x.txt", u)
+		assert.NotContains(t, u, "X-Amz-Signature")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewBucket(key, "test-bucket").ObjectURL("../x.txt")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+}
+
+func TestBucket_WriteFromReader(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	b.MinPartOverride = 1024
+	ctx := context.Background()
+
+	data := make([]byte, 5000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	// parts returns the number of parts of an object,
+	// or zero if it was written with a single PUT
+	parts := func(name string) int {
+		attrs, err := b.Attributes(ctx, name, []string{AttrObjectParts})
+		assert.NoError(t, err)
+		if attrs.ObjectParts == nil {
+			return 0
+		}
+		return attrs.ObjectParts.PartsCount
+	}
+	check := func(name string, want []byte, nparts int) {
+		content, found := mockServer.ObjectContent(name)
+		assert.True(t, found, name)
+		assert.Equal(t, want, content, name)
+		assert.Equal(t, nparts, parts(name), name)
+	}
+
+	t.Run("file", func(t *testing.T) {
+		f, err := os.Create(path.Join(t.TempDir(), "data.bin"))
+		assert.NoError(t, err)
+		defer f.Close()
+		_, err = f.Write(data)
+		assert.NoError(t, err)
+
+		// the contents are read from the current offset
+		_, err = f.Seek(100, io.SeekStart)
+		assert.NoError(t, err)
+		etag, err := b.WriteFromReader(ctx, "file.bin", f)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, etag)
+		check("file.bin", data[100:], 5)
+
+		off, err := f.Seek(0, io.SeekCurrent)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(100), off)
+	})
+
+	t.Run("bytes reader", func(t *testing.T) {
+		_, err := b.WriteFromReader(ctx, "small.bin", bytes.NewReader(data[:500]))
+		assert.NoError(t, err)
+		check("small.bin", data[:500], 0)
+
+		r := bytes.NewReader(data)
+		_, err = r.Seek(1000, io.SeekStart)
+		assert.NoError(t, err)
+		_, err = b.WriteFromReader(ctx, "large.bin", r)
+		assert.NoError(t, err)
+		check("large.bin", data[1000:], 4)
+
+		_, err = b.WriteFromReader(ctx, "string.bin", strings.NewReader("hello"))
+		assert.NoError(t, err)
+		check("string.bin", []byte("hello"), 0)
+	})
+
+	t.Run("unknown size", func(t *testing.T) {
+		for _, size := range []int{0, 500, 1024, 3000, 3072} {
+			name := fmt.Sprintf("stream-%d.bin", size)
+			var tee bytes.Buffer
+			r := struct{ io.Reader }{bytes.NewReader(data[:size])}
+			_, err := b.WriteFromReader(ctx, name, r, UploadOptions{Tee: &tee})
+			assert.NoError(t, err, name)
+			assert.Equal(t, data[:size], append([]byte{}, tee.Bytes()...), name)
+
+			// a stream of at least a part is uploaded in parts
+			nparts := 0
+			if size >= 1024 {
+				nparts = (size + 1023) / 1024
+			}
+			check(name, data[:size], nparts)
+		}
+		assert.Empty(t, mockServer.ListMultipartUploads())
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		r := io.MultiReader(bytes.NewReader(data[:2048]), iotest.ErrReader(io.ErrClosedPipe))
+		_, err := b.WriteFromReader(ctx, "broken.bin", r)
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+		assert.False(t, mockServer.ObjectExists("broken.bin"))
+		assert.Empty(t, mockServer.ListMultipartUploads())
+	})
+}
+
+func TestBucket_ReadDirAll(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+
+	// track the number of concurrent listings
+	var inflight, peak atomic.Int32
+	b.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		return DefaultClient.Transport.RoundTrip(req)
+	})}
+
+	mockServer.PutObject("p1/a.txt", []byte("a"))
+	mockServer.PutObject("p1/b.txt", []byte("b"))
+	mockServer.PutObject("p2/c.txt", []byte("c"))
+	mockServer.PutObject("p3/d/e.txt", []byte("e"))
+
+	names := func(entries []fs.DirEntry) []string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Name())
+		}
+		return out
+	}
+
+	t.Run("parallel", func(t *testing.T) {
+		peak.Store(0)
+		dirs, err := b.ReadDirAll(context.Background(), []string{"p1", "p2", "p3"}, 2)
+		assert.NoError(t, err)
+		assert.Len(t, dirs, 3)
+		assert.Equal(t, []string{"a.txt", "b.txt"}, names(dirs["p1"]))
+		assert.Equal(t, []string{"c.txt"}, names(dirs["p2"]))
+		assert.Equal(t, []string{"d"}, names(dirs["p3"]))
+		assert.LessOrEqual(t, peak.Load(), int32(2))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dirs, err := b.ReadDirAll(context.Background(), []string{"p1", "missing", "../bad"}, 0)
+		var kerr *KeyError
+		assert.ErrorAs(t, err, &kerr)
+		assert.Len(t, kerr.Failed, 2)
+		assert.ErrorIs(t, kerr.Failed["missing"], fs.ErrNotExist)
+		assert.ErrorIs(t, kerr.Failed["../bad"], fs.ErrInvalid)
+		assert.Equal(t, []string{"a.txt", "b.txt"}, names(dirs["p1"]))
+	})
+}
+
+func TestBucket_MkdirMarker(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	assert.NoError(t, b.MkdirMarker(ctx, "parent/empty"))
+	content, ok := mockServer.ObjectContent("parent/empty/")
+	assert.True(t, ok)
+	assert.Empty(t, content)
+
+	entries, err := b.ReadDir("parent")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "empty", entries[0].Name())
+		assert.True(t, entries[0].IsDir())
+	}
+
+	info, err := fs.Stat(b, "parent/empty")
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	entries, err = b.ReadDir("parent/empty")
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	for _, dir := range []string{".", "", "/abs", "a/../b", "a/", "a//b"} {
+		assert.ErrorIs(t, b.MkdirMarker(ctx, dir), fs.ErrInvalid, dir)
+	}
+}
+
+func TestBucket_DiskUsage(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	objects := map[string]int{
+		"data/a.txt":         10,
+		"data/b/c.txt":       200,
+		"data/b/d/e.txt":     3000,
+		"data/b/d/f/g.txt":   1,
+		"database/other.txt": 50,
+		"top.txt":            7,
+	}
+	for name, size := range objects {
+		_, err := b.Write(ctx, name, bytes.Repeat([]byte("x"), size))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, b.MkdirMarker(ctx, "data/marker"))
+
+	for _, tc := range []struct {
+		root  string
+		count int
+		bytes int64
+	}{
+		{".", 6, 3268},
+		{"data", 4, 3211},
+		{"data/b", 3, 3201},
+		{"data/b/d/f", 1, 1},
+		{"top.txt", 1, 7},
+		{"data/marker", 0, 0},
+	} {
+		count, bytes, err := fsutil.DiskUsage(b, tc.root)
+		assert.NoError(t, err, tc.root)
+		assert.Equal(t, tc.count, count, tc.root)
+		assert.Equal(t, tc.bytes, bytes, tc.root)
+	}
+
+	t.Run("flat listing", func(t *testing.T) {
+		before := len(mockServer.GetRequestsWithMethod("GET"))
+		count, _, err := fsutil.DiskUsage(b, "data")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, count)
+
+		lists := mockServer.GetRequestsWithMethod("GET")[before:]
+		if assert.Len(t, lists, 1) {
+			assert.NotContains(t, lists[0].Query, "delimiter")
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := fsutil.DiskUsage(b, "missing")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, _, err = b.DiskUsage("../escape")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+}
+
+func TestBucket_Touch(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	mockServer.PutObjectWithMetadata("doc.json", []byte(`{"a":1}`), map[string]string{"owner": "alice"})
+	before, _ := mockServer.GetObject("doc.json")
+	contentType, modified := before.ContentType, before.LastModified
+
+	t.Run("replace", func(t *testing.T) {
+		etag, err := b.Touch(ctx, "doc.json", map[string]string{"owner": "bob", "reviewed": "yes"})
+		assert.NoError(t, err)
+
+		obj, ok := mockServer.GetObject("doc.json")
+		assert.True(t, ok)
+		assert.Equal(t, etag, obj.ETag)
+		assert.Equal(t, map[string]string{"owner": "bob", "reviewed": "yes"}, obj.Metadata)
+		assert.Equal(t, contentType, obj.ContentType)
+		assert.False(t, obj.LastModified.Before(modified))
+
+		puts := mockServer.GetRequestsWithMethod("PUT")
+		assert.Subset(t, signedHeaders(puts[len(puts)-1]), []string{"x-amz-metadata-directive", "x-amz-meta-owner", "x-amz-meta-reviewed"})
+
+		data, err := b.ReadFile("doc.json")
+		assert.NoError(t, err)
+		assert.Equal(t, `{"a":1}`, string(data))
+
+		f, err := b.Open("doc.json")
+		assert.NoError(t, err)
+		defer f.Close()
+		assert.Equal(t, contentType, f.(*File).ContentType)
+	})
+
+	t.Run("self copy", func(t *testing.T) {
+		// a plain self-copy changes nothing and is rejected
+		_, err := b.Copy(ctx, "doc.json", "doc.json")
+		var serr *Error
+		if assert.ErrorAs(t, err, &serr) {
+			assert.Equal(t, "InvalidRequest", serr.Code)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		// a backend that ignores the metadata directive
+		// sees a no-op self-copy and rejects it
+		rb := NewBucket(key, bucket)
+		rb.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Del("x-amz-metadata-directive")
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+		_, err := rb.Touch(ctx, "doc.json", map[string]string{"owner": "carol"})
+		assert.ErrorContains(t, err, "onto itself was rejected")
+		var serr *Error
+		if assert.ErrorAs(t, err, &serr) {
+			assert.Equal(t, "InvalidRequest", serr.Code)
+		}
+		obj, _ := mockServer.GetObject("doc.json")
+		assert.Equal(t, "bob", obj.Metadata["owner"])
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		_, err := b.Write(ctx, "page.html.gz", []byte("gzipped"), UploadOptions{
+			CacheControl:       "max-age=60",
+			ContentDisposition: "inline",
+			Expires:            expires,
+			ExtraHeaders: http.Header{
+				"Content-Encoding": {"gzip"},
+				"Content-Language": {"en"},
+			},
+		})
+		assert.NoError(t, err)
+		written, _ := mockServer.GetObject("page.html.gz")
+		contentType := written.ContentType
+
+		_, err = b.Touch(ctx, "page.html.gz", map[string]string{"owner": "dave"})
+		assert.NoError(t, err)
+		obj, _ := mockServer.GetObject("page.html.gz")
+		assert.Equal(t, map[string]string{"owner": "dave"}, obj.Metadata)
+		assert.Equal(t, contentType, obj.ContentType)
+		assert.Equal(t, "max-age=60", obj.CacheControl)
+		assert.Equal(t, "inline", obj.Disposition)
+		assert.Equal(t, expires.Format(http.TimeFormat), obj.Expires)
+		assert.Equal(t, "gzip", obj.Headers.Get("Content-Encoding"))
+		assert.Equal(t, "en", obj.Headers.Get("Content-Language"))
+
+		puts := mockServer.GetRequestsWithMethod("PUT")
+		assert.Subset(t, signedHeaders(puts[len(puts)-1]), []string{
+			"cache-control", "content-disposition", "content-encoding", "content-language", "content-type", "expires",
+		})
+	})
+
+	t.Run("other invalid request", func(t *testing.T) {
+		// only a rejected self-copy is reported as such
+		rb := NewBucket(key, bucket)
+		rb.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodPut {
+				return http.DefaultTransport.RoundTrip(req)
+			}
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Status:     "400 Bad Request",
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(`<Error><Code>InvalidRequest</Code><Message>The storage class you specified is not valid</Message></Error>`)),
+				Request:    req,
+			}, nil
+		})}
+		_, err := rb.Touch(ctx, "doc.json", map[string]string{"owner": "erin"})
+		assert.Error(t, err)
+		assert.NotContains(t, err.Error(), "onto itself")
+	})
+
+	t.Run("context", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		before := len(mockServer.GetRequestLog())
+		_, err := b.Touch(canceled, "doc.json", nil)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Len(t, mockServer.GetRequestLog(), before)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := b.Touch(ctx, "missing.json", nil)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = b.Touch(ctx, "../escape", nil)
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+
+		strict := NewBucket(key, bucket)
+		strict.StrictKeys = true
+		_, err = strict.Touch(ctx, "./doc.json", nil)
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+}
+
+func TestBucket_TTFB(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	handler := new(captureHandler)
+	b := NewBucket(key, bucket)
+	b.Logger = slog.New(handler)
+	mockServer.PutObject("slow.bin", bytes.Repeat([]byte("x"), 4096))
+
+	const delay = 200 * time.Millisecond
+	mockServer.ThrottleBody(delay)
+	start := time.Now()
+	data, err := b.ReadFile("slow.bin")
+	total := time.Since(start)
+	assert.NoError(t, err)
+	assert.Len(t, data, 4096)
+
+	records := handler.attrs()
+	if assert.Len(t, records, 1) {
+		ttfb, err := time.ParseDuration(records[0]["ttfb"])
+		assert.NoError(t, err)
+		assert.Greater(t, ttfb, time.Duration(0))
+		assert.Less(t, ttfb, delay)
+		assert.GreaterOrEqual(t, total, delay)
+		assert.Less(t, ttfb, total)
+	}
+}
+
+func TestBucket_ReadDirObject(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+	mockServer.PutObject("some/file.txt", []byte("content"))
+
+	t.Run("bucket", func(t *testing.T) {
+		_, err := b.ReadDir("some/file.txt")
+		assert.ErrorIs(t, err, syscall.ENOTDIR)
+		assert.NotErrorIs(t, err, fs.ErrNotExist)
+		var perr *fs.PathError
+		if assert.ErrorAs(t, err, &perr) {
+			assert.Equal(t, "some/file.txt", perr.Path)
+		}
+
+		_, err = fs.ReadDir(b, "some/file.txt")
+		assert.ErrorIs(t, err, syscall.ENOTDIR)
+
+		_, err = b.ReadDir("some/missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+
+		entries, err := b.ReadDir("some")
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		for _, n := range []int{-1, 3} {
+			_, err := b.sub("some/file.txt/").ReadDir(n)
+			assert.ErrorIs(t, err, syscall.ENOTDIR, n)
+		}
+
+		entries, err := b.sub("some/missing/").ReadDir(-1)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		before := len(mockServer.GetRequestLog())
+		assert.NoError(t, b.sub("some/file.txt/").notDir(ctx))
+		assert.Len(t, mockServer.GetRequestLog(), before)
+	})
+}
+
+func TestBucket_ReadDirContext(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+
+	// enough objects for the listing to span several pages
+	for i := range 1500 {
+		mockServer.PutObject(fmt.Sprintf("dir/%04d.txt", i), []byte("x"))
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		entries, err := b.ReadDirContext(context.Background(), "dir")
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1500)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// cancel the context as soon as the first page has arrived,
+		// and count the listings that were sent with a live context
+		var requests atomic.Int32
+		c := *b
+		c.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Context().Err() == nil {
+				requests.Add(1)
+			}
+			res, err := DefaultClient.Transport.RoundTrip(req)
+			cancel()
+			return res, err
+		})}
+
+		entries, err := c.ReadDirContext(ctx, "dir")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, entries)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("cancelled before", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := b.ReadDirContext(ctx, "dir")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestBucket_ListPage(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+
+	mockServer.PutObject("mixed/a.txt", []byte("a"))
+	mockServer.PutObject("mixed/b.txt", []byte("bb"))
+	mockServer.PutObject("mixed/sub1/c.txt", []byte("c"))
+	mockServer.PutObject("mixed/sub2/d/e.txt", []byte("e"))
+	mockServer.PutObject("other/f.txt", []byte("f"))
+
+	t.Run("mixed", func(t *testing.T) {
+		list, err := b.ListPage(context.Background(), "mixed", "", 0)
+		assert.NoError(t, err)
+		assert.False(t, list.IsTruncated)
+		assert.Empty(t, list.NextToken)
+		assert.Equal(t, []string{"sub1", "sub2"}, list.Dirs)
+		if assert.Len(t, list.Files, 2) {
+			assert.Equal(t, "a.txt", list.Files[0].Name)
+			assert.Equal(t, int64(1), list.Files[0].Size)
+			assert.Equal(t, "b.txt", list.Files[1].Name)
+			assert.Equal(t, int64(2), list.Files[1].Size)
+			assert.NotEmpty(t, list.Files[1].ETag)
+			assert.False(t, list.Files[1].ModTime.IsZero())
+		}
+	})
+
+	t.Run("root", func(t *testing.T) {
+		list, err := b.ListPage(context.Background(), ".", "", 0)
+		assert.NoError(t, err)
+		assert.Empty(t, list.Files)
+		assert.Equal(t, []string{"mixed", "other"}, list.Dirs)
+	})
+
+	t.Run("paged", func(t *testing.T) {
+		var files, dirs []string
+		var token string
+		for pages := 0; ; pages++ {
+			list, err := b.ListPage(context.Background(), "mixed", token, 1)
+			assert.NoError(t, err)
+			for _, f := range list.Files {
+				files = append(files, f.Name)
+			}
+			dirs = append(dirs, list.Dirs...)
+			if !list.IsTruncated {
+				assert.Equal(t, 3, pages)
+				break
+			}
+			token = list.NextToken
+		}
+		assert.Equal(t, []string{"a.txt", "b.txt"}, files)
+		assert.Equal(t, []string{"sub1", "sub2"}, dirs)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		list, err := b.ListPage(context.Background(), "missing", "", 0)
+		assert.NoError(t, err)
+		assert.Empty(t, list.Files)
+		assert.Empty(t, list.Dirs)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := b.ListPage(context.Background(), "../x", "", 0)
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+}
+
+func TestBucket_ExpectContinue(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	b.MinPartOverride = 1024
+	ctx := context.Background()
+	opts := UploadOptions{ExpectContinue: true}
+	data := bytes.Repeat([]byte("expect"), 1000)
+
+	// expects returns the Expect headers of the PUT
+	// requests made since the log had n entries
+	expects := func(n int) []string {
+		var out []string
+		for _, r := range mockServer.GetRequestLog()[n:] {
+			if r.Method == http.MethodPut {
+				out = append(out, r.Headers["Expect"])
+			}
+		}
+		return out
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		tr := DefaultClient.Transport.(*http.Transport)
+		assert.Greater(t, tr.ExpectContinueTimeout, time.Duration(0))
+	})
+
+	t.Run("write", func(t *testing.T) {
+		n := len(mockServer.GetRequestLog())
+		_, err := b.Write(ctx, "expect/write.bin", data, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"100-continue"}, expects(n))
+
+		content, found := mockServer.ObjectContent("expect/write.bin")
+		assert.True(t, found)
+		assert.Equal(t, data, content)
+	})
+
+	t.Run("parts", func(t *testing.T) {
+		n := len(mockServer.GetRequestLog())
+		err := b.WriteFrom(ctx, "expect/parts.bin", bytes.NewReader(data), int64(len(data)), opts)
+		assert.NoError(t, err)
+		puts := expects(n)
+		assert.Greater(t, len(puts), 1)
+		for _, h := range puts {
+			assert.Equal(t, "100-continue", h)
+		}
+
+		content, found := mockServer.ObjectContent("expect/parts.bin")
+		assert.True(t, found)
+		assert.Equal(t, data, content)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		n := len(mockServer.GetRequestLog())
+		_, err := b.Write(ctx, "expect/plain.bin", data)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{""}, expects(n))
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// ErrBudgetExceeded is returned by reads of object
+// contents once the ReadBudget they share is exhausted.
+var ErrBudgetExceeded = errors.New("s3: read budget exceeded")
+
+// budgeted returns body, reading at most as many
+// bytes as remain in budget, if budget is not nil.
+func budgeted(budget *int64, body io.ReadCloser) io.ReadCloser {
+	if budget == nil {
+		return body
+	}
+	return &budgetReader{ReadCloser: body, budget: budget}
+}
+
+// budgetReader is a body whose reads are
+// taken out of a budget shared by many readers.
+type budgetReader struct {
+	io.ReadCloser
+	budget *int64
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return b.ReadCloser.Read(p)
+	}
+	// reserve the bytes up front so that concurrent
+	// reads never take more than the budget in total,
+	// and give back what was not read
+	want := reserve(b.budget, int64(len(p)))
+	if want == 0 {
+		return 0, ErrBudgetExceeded
+	}
+	n, err := b.ReadCloser.Read(p[:want])
+	if int64(n) < want {
+		atomic.AddInt64(b.budget, want-int64(n))
+	}
+	return n, err
+}
+
+// reserve takes up to n bytes out of budget,
+// returning how many were taken.
+func reserve(budget *int64, n int64) int64 {
+	for {
+		left := atomic.LoadInt64(budget)
+		if left <= 0 {
+			return 0
+		}
+		take := min(n, left)
+		if atomic.CompareAndSwapInt64(budget, left, left-take) {
+			return take
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucket_ReadBudget(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	mockServer.PopulateTestData(map[string][]byte{
+		"large.bin": data,
+		"dir/a.txt": []byte("hello"),
+	})
+
+	budget := int64(10000)
+	b := NewBucket(key, bucket)
+	b.ReadBudget = &budget
+
+	t.Run("open", func(t *testing.T) {
+		f, err := b.Open("large.bin")
+		assert.NoError(t, err)
+		defer f.Close()
+
+		got, err := io.ReadAll(f)
+		assert.ErrorIs(t, err, ErrBudgetExceeded)
+		assert.Equal(t, data[:10000], got)
+		assert.Equal(t, int64(0), atomic.LoadInt64(&budget))
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		_, err := b.ReadFile("dir/a.txt")
+		assert.ErrorIs(t, err, ErrBudgetExceeded)
+
+		f, err := fs.Sub(b, "dir")
+		assert.NoError(t, err)
+		_, err = fs.ReadFile(f, "a.txt")
+		assert.ErrorIs(t, err, ErrBudgetExceeded)
+	})
+
+	t.Run("write to", func(t *testing.T) {
+		atomic.StoreInt64(&budget, 100)
+		f, err := b.OpenRaw("large.bin")
+		assert.NoError(t, err)
+		defer f.Close()
+
+		var buf bytes.Buffer
+		n, err := f.WriteTo(&buf)
+		assert.ErrorIs(t, err, ErrBudgetExceeded)
+		assert.Equal(t, int64(100), n)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		atomic.StoreInt64(&budget, 1000)
+		f, err := b.OpenRaw("large.bin")
+		assert.NoError(t, err)
+		defer f.Close()
+
+		buf := make([]byte, 600)
+		_, err = f.ReadAt(buf, 0)
+		assert.NoError(t, err)
+		_, err = f.ReadAt(buf, 600)
+		assert.ErrorIs(t, err, ErrBudgetExceeded)
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		got, err := NewBucket(key, bucket).ReadFile("large.bin")
+		assert.NoError(t, err)
+		assert.Equal(t, data, got)
+	})
+}
+
+func TestReserve(t *testing.T) {
+	budget := int64(1000)
+
+	var wg sync.WaitGroup
+	var taken atomic.Int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for reserve(&budget, 7) > 0 {
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 142 reservations of 7 bytes and one of 6
+	assert.Equal(t, int64(143), taken.Load())
+	assert.Equal(t, int64(0), budget)
+	assert.Equal(t, int64(0), reserve(&budget, 1))
+}
+
+func TestBudgetReader(t *testing.T) {
+	budget := int64(5)
+	r := budgeted(&budget, io.NopCloser(bytes.NewReader([]byte("abc"))))
+
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), got)
+	assert.Equal(t, int64(2), budget, "unread bytes are given back")
+
+	body := io.NopCloser(bytes.NewReader(nil))
+	assert.Equal(t, body, budgeted(nil, body))
+}
@@ -0,0 +1,157 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+)
+
+// CachedBucket wraps a Bucket and memoizes the results
+// of ReadDir and Stat for a fixed time-to-live.
+//
+// Writes and deletes made through the CachedBucket
+// invalidate the cached results for the affected
+// paths; changes made by other clients are only
+// observed once the cached results expire.
+type CachedBucket struct {
+	bucket *Bucket
+	ttl    time.Duration
+	lock   sync.Mutex
+	dirs   map[string]cachedDir
+	stats  map[string]cachedStat
+}
+
+type cachedDir struct {
+	entries []fs.DirEntry
+	expires time.Time
+}
+
+type cachedStat struct {
+	info    fs.FileInfo
+	expires time.Time
+}
+
+// CachedFS returns an fs.FS that serves ReadDir and Stat
+// from a cache whose entries live for ttl. The returned
+// fs.FS is a *CachedBucket, which can also be used to
+// write to and delete from the bucket.
+func CachedFS(bucket *Bucket, ttl time.Duration) fs.FS {
+	return &CachedBucket{
+		bucket: bucket,
+		ttl:    ttl,
+		dirs:   make(map[string]cachedDir),
+		stats:  make(map[string]cachedStat),
+	}
+}
+
+// Open implements fs.FS.Open. Opens are not cached.
+func (c *CachedBucket) Open(name string) (fs.File, error) {
+	return c.bucket.Open(name)
+}
+
+// ReadDir implements fs.ReadDirFS
+func (c *CachedBucket) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = path.Clean(name)
+	c.lock.Lock()
+	d, ok := c.dirs[name]
+	c.lock.Unlock()
+	if ok && time.Now().Before(d.expires) {
+		return cloneEntries(d.entries), nil
+	}
+
+	entries, err := c.bucket.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	c.dirs[name] = cachedDir{entries: entries, expires: time.Now().Add(c.ttl)}
+	c.lock.Unlock()
+	return cloneEntries(entries), nil
+}
+
+// Stat implements fs.StatFS
+func (c *CachedBucket) Stat(name string) (fs.FileInfo, error) {
+	name = path.Clean(name)
+	c.lock.Lock()
+	s, ok := c.stats[name]
+	c.lock.Unlock()
+	if ok && time.Now().Before(s.expires) {
+		return s.info, nil
+	}
+
+	info, err := fs.Stat(c.bucket, name)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	c.stats[name] = cachedStat{info: info, expires: time.Now().Add(c.ttl)}
+	c.lock.Unlock()
+	return info, nil
+}
+
+// Write performs Bucket.Write and invalidates
+// the cached results affected by the write.
+func (c *CachedBucket) Write(ctx context.Context, key string, contents []byte, opts ...UploadOptions) (string, error) {
+	etag, err := c.bucket.Write(ctx, key, contents, opts...)
+	c.invalidate(key)
+	return etag, err
+}
+
+// Delete performs Bucket.Delete and invalidates
+// the cached results affected by the delete.
+func (c *CachedBucket) Delete(ctx context.Context, fullpath string) error {
+	err := c.bucket.Delete(ctx, fullpath)
+	c.invalidate(fullpath)
+	return err
+}
+
+// invalidate drops the cached results for key
+// and for each of the directories containing it.
+func (c *CachedBucket) invalidate(key string) {
+	key = path.Clean(key)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.stats, key)
+	delete(c.dirs, key)
+	for dir := key; dir != "." && dir != "/"; {
+		dir = path.Dir(dir)
+		delete(c.stats, dir)
+		delete(c.dirs, dir)
+	}
+}
+
+// cloneEntries returns a copy of entries so that
+// callers reading from the returned files do not
+// share state with the cached entries.
+func cloneEntries(entries []fs.DirEntry) []fs.DirEntry {
+	out := make([]fs.DirEntry, len(entries))
+	for i, e := range entries {
+		switch e := e.(type) {
+		case *File:
+			f := *e
+			out[i] = &f
+		case *Prefix:
+			p := *e
+			out[i] = &p
+		default:
+			out[i] = e
+		}
+	}
+	return out
+}
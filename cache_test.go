@@ -0,0 +1,114 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedFS(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	mockServer.PopulateTestData(map[string][]byte{
+		"dir/a.txt":     []byte("a"),
+		"dir/b.txt":     []byte("b"),
+		"dir/sub/c.txt": []byte("c"),
+	})
+
+	// lists counts the LIST requests made so far
+	lists := func() int {
+		n := 0
+		for _, req := range mockServer.GetRequestLog() {
+			if req.Method == "GET" && strings.Contains(req.Query, "list-type=2") {
+				n++
+			}
+		}
+		return n
+	}
+
+	const ttl = 200 * time.Millisecond
+	cfs := CachedFS(NewBucket(key, bucket), ttl)
+
+	entries, err := fs.ReadDir(cfs, "dir")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	listed := lists()
+
+	// served from the cache
+	entries, err = fs.ReadDir(cfs, "dir")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, listed, lists())
+
+	info, err := fs.Stat(cfs, "dir/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), info.Size())
+	requests := len(mockServer.GetRequestLog())
+	_, err = fs.Stat(cfs, "dir/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, requests, len(mockServer.GetRequestLog()))
+
+	// writes through the cache invalidate it
+	_, err = cfs.(*CachedBucket).Write(context.Background(), "dir/a.txt", []byte("aa"))
+	assert.NoError(t, err)
+	info, err = fs.Stat(cfs, "dir/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), info.Size())
+
+	_, err = cfs.(*CachedBucket).Write(context.Background(), "dir/d.txt", []byte("d"))
+	assert.NoError(t, err)
+	entries, err = fs.ReadDir(cfs, "dir")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+	assert.Equal(t, listed+1, lists())
+
+	// re-lists after expiry
+	mockServer.PutObject("dir/e.txt", []byte("e"))
+	entries, err = fs.ReadDir(cfs, "dir")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+	time.Sleep(ttl)
+	entries, err = fs.ReadDir(cfs, "dir")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 5)
+	assert.Equal(t, listed+2, lists())
+
+	// concurrent use is safe
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := fs.ReadDir(cfs, "dir")
+			assert.NoError(t, err)
+			_, err = fs.Stat(cfs, "dir/b.txt")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+}
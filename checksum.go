@@ -0,0 +1,158 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// CompositeSHA256 computes the composite SHA256 checksum that S3
+// reports for an object of the given size uploaded with WriteFrom,
+// reading the contents of the object from r. The checksum is the
+// SHA256 digest of the concatenated SHA256 digests of the parts,
+// followed by the number of parts, e.g. "Cq9...e0=-3".
+//
+// The result can be used as UploadOptions.ChecksumSHA256 to verify
+// the uploaded object end-to-end.
+func (b *Bucket) CompositeSHA256(r io.Reader, size int64) (string, error) {
+	u := uploader{MinPartOverride: b.MinPartOverride}
+	return compositeSHA256(r, size, calculatePartSize(size, int64(u.MinPartSize())))
+}
+
+// compositeSHA256 computes the composite SHA256 checksum
+// of size bytes read from r in parts of partSize bytes.
+func compositeSHA256(r io.Reader, size, partSize int64) (string, error) {
+	outer := sha256.New()
+	parts := SplitRanges(size, partSize)
+	for _, part := range parts {
+		inner := sha256.New()
+		if _, err := io.CopyN(inner, r, part.Length); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		outer.Write(inner.Sum(nil))
+	}
+	return base64.StdEncoding.EncodeToString(outer.Sum(nil)) + "-" + strconv.Itoa(len(parts)), nil
+}
+
+// partSHA256 returns the base64-encoded
+// SHA256 checksum of a single part.
+func partSHA256(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// objectChecksum returns the header carrying the checksum of
+// contents with the given algorithm, and the base64-encoded
+// checksum itself. The algorithm must be "CRC32C" or "SHA256".
+func objectChecksum(algorithm string, contents []byte) (header, sum string) {
+	if algorithm == "SHA256" {
+		return "x-amz-checksum-sha256", partSHA256(contents)
+	}
+	crc := crc32.Checksum(contents, crc32.MakeTable(crc32.Castagnoli))
+	return "x-amz-checksum-crc32c", base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint32(nil, crc))
+}
+
+// maxPutSize is the largest object
+// that can be written with a single PUT.
+const maxPutSize = 5 << 30
+
+// WriteStreamChecksummed performs a PutObject operation at the object key
+// 'key' with size bytes read from r and returns the ETag of the new object.
+// Unlike Write, the contents are streamed rather than held in memory, and
+// unlike WriteFrom, they are read only once: their CRC32C is computed along
+// the way and sent as a trailing x-amz-checksum-crc32c header (see
+// aws.SigningKey.SignV4Trailer), so that S3 rejects the object with a
+// BadDigest error if it was corrupted in transit. If S3 reports a different
+// checksum back, the error matches ErrChecksumMismatch.
+//
+// The key is cleaned and validated as with Write, and size must not exceed
+// the 5GiB limit of a single PUT. Since r cannot be rewound, the request is
+// not retried. The ChecksumAlgorithm and ChecksumSHA256 options are ignored.
+func (b *Bucket) WriteStreamChecksummed(ctx context.Context, key string, r io.Reader, size int64, opts ...UploadOptions) (string, error) {
+	key, err := b.cleanKey("s3 PUT", key)
+	if err != nil {
+		return "", err
+	}
+	if _, base := path.Split(key); !fs.ValidPath(key) || base == "." {
+		return "", badpath("s3 PUT", key)
+	}
+	if size < 0 || size > maxPutSize {
+		return "", fmt.Errorf("s3.WriteStreamChecksummed: invalid size %d", size)
+	}
+	o, err := uploadOptions(opts)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uri(b.key, b.bkt, key), nil)
+	if err != nil {
+		return "", err
+	}
+	if o.Tee != nil {
+		r = io.TeeReader(r, o.Tee)
+	}
+	o.apply(req)
+	setUserAgent(req, b.UserAgent)
+	h := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	b.key.SignV4Trailer(req, r, size, "x-amz-checksum-crc32c", h, o.signed()...)
+	o.expect(req)
+	res, err := flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return "", responseError("s3 PUT", res)
+	}
+	want := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	if got := res.Header.Get("x-amz-checksum-crc32c"); got != "" && got != want {
+		return "", fmt.Errorf("s3.WriteStreamChecksummed: %w: got %q, want %q", ErrChecksumMismatch, got, want)
+	}
+	return res.Header.Get("ETag"), nil
+}
+
+// verifyChecksum reads back the checksum of the completed
+// object with a HEAD request and compares it against the
+// expected composite SHA256 checksum in u.Options.
+func (u *uploader) verifyChecksum(ctx context.Context) error {
+	req := u.req(ctx, http.MethodHead, u.Object, "")
+	req.Header.Set("x-amz-checksum-mode", "ENABLED")
+	u.Key.SignV4(req, nil, "x-amz-checksum-mode")
+	res, err := flakyDo(u.Client, u.Limiter, u.Logger, u.stats, req)
+	if err != nil {
+		return fmt.Errorf("s3.Uploader.Close: verifying checksum: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return responseError("s3.Uploader.Close", res)
+	}
+	if got := res.Header.Get("x-amz-checksum-sha256"); got != u.Options.ChecksumSHA256 {
+		return fmt.Errorf("s3.Uploader.Close: %w: got %q, want %q", ErrChecksumMismatch, got, u.Options.ChecksumSHA256)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+// signedHeaders returns the names of the headers
+// signed by the Authorization header of req.
+func signedHeaders(req mock.RequestLog) []string {
+	_, signed, ok := strings.Cut(req.Headers["Authorization"], "SignedHeaders=")
+	if !ok {
+		return nil
+	}
+	signed, _, _ = strings.Cut(signed, ",")
+	return strings.Split(signed, ";")
+}
+
+func TestCompositeSHA256(t *testing.T) {
+	data := []byte("hello, world")
+
+	// two parts of 8 and 4 bytes
+	a, b := sha256.Sum256(data[:8]), sha256.Sum256(data[8:])
+	want := sha256.Sum256(append(a[:], b[:]...))
+	got, err := compositeSHA256(bytes.NewReader(data), int64(len(data)), 8)
+	assert.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(want[:])+"-2", got)
+
+	_, err = compositeSHA256(bytes.NewReader(data[:5]), int64(len(data)), 8)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestUploadChecksum(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	b.MinPartOverride = 1024
+	ctx := context.Background()
+
+	data := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(data)
+	sum, err := b.CompositeSHA256(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[A-Za-z0-9+/]{43}=-5$`, sum)
+
+	t.Run("match", func(t *testing.T) {
+		err := b.WriteFrom(ctx, "checksum/match.bin", bytes.NewReader(data), int64(len(data)), UploadOptions{ChecksumSHA256: sum})
+		assert.NoError(t, err)
+
+		content, ok := mockServer.ObjectContent("checksum/match.bin")
+		assert.True(t, ok)
+		assert.Equal(t, data, content)
+
+		for _, req := range mockServer.GetRequestsWithMethod("PUT") {
+			assert.NotEmpty(t, req.Headers["X-Amz-Checksum-Sha256"], "part %s", req.Query)
+			assert.Contains(t, signedHeaders(req), "x-amz-checksum-sha256")
+		}
+		for _, req := range mockServer.GetRequestsWithMethod("POST") {
+			if req.Query == "uploads=" {
+				assert.Equal(t, "SHA256", req.Headers["X-Amz-Checksum-Algorithm"])
+				assert.Contains(t, signedHeaders(req), "x-amz-checksum-algorithm")
+			}
+		}
+		heads := mockServer.GetRequestsWithMethod("HEAD")
+		if assert.Len(t, heads, 1) {
+			assert.Equal(t, "ENABLED", heads[0].Headers["X-Amz-Checksum-Mode"])
+			assert.Contains(t, signedHeaders(heads[0]), "x-amz-checksum-mode")
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		other := bytes.Clone(data)
+		other[len(other)-1] ^= 0xff
+		wrong, err := b.CompositeSHA256(bytes.NewReader(other), int64(len(other)))
+		assert.NoError(t, err)
+		assert.NotEqual(t, sum, wrong)
+
+		err = b.WriteFrom(ctx, "checksum/mismatch.bin", bytes.NewReader(data), int64(len(data)), UploadOptions{ChecksumSHA256: wrong})
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+		assert.Contains(t, err.Error(), sum)
+	})
+
+	t.Run("no checksum", func(t *testing.T) {
+		mockServer.Clear()
+		err := b.WriteFrom(ctx, "checksum/none.bin", bytes.NewReader(data), int64(len(data)))
+		assert.NoError(t, err)
+		assert.False(t, mockServer.HasRequestWithMethod("HEAD"))
+		for _, req := range mockServer.GetRequestsWithMethod("PUT") {
+			assert.Empty(t, req.Headers["X-Amz-Checksum-Sha256"])
+		}
+	})
+}
+
+func TestWriteChecksum(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+	data := []byte("the quick brown fox jumps over the lazy dog")
+
+	for _, tc := range []struct {
+		algorithm, header, sum string
+	}{
+		{"CRC32C", "X-Amz-Checksum-Crc32c", "PBj01g=="},
+		{"SHA256", "X-Amz-Checksum-Sha256", "Bcbgjx2f2voDFH/Lj4LxJMdtL3Dj2Ynciq2159dFC+w="},
+	} {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			_, err := b.Write(ctx, "ok.txt", data, UploadOptions{ChecksumAlgorithm: tc.algorithm})
+			assert.NoError(t, err)
+			content, ok := mockServer.ObjectContent("ok.txt")
+			assert.True(t, ok)
+			assert.Equal(t, data, content)
+
+			puts := mockServer.GetRequestsWithMethod("PUT")
+			last := puts[len(puts)-1]
+			assert.Equal(t, tc.algorithm, last.Headers["X-Amz-Sdk-Checksum-Algorithm"])
+			assert.Equal(t, tc.sum, last.Headers[tc.header])
+			assert.Subset(t, signedHeaders(last), []string{"x-amz-sdk-checksum-algorithm", strings.ToLower(tc.header)})
+
+			// a body corrupted in transit is rejected
+			tampered := NewBucket(key, bucket)
+			tampered.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				body[0] ^= 0xff
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				return http.DefaultTransport.RoundTrip(req)
+			})}
+			_, err = tampered.WriteInfo(ctx, "bad.txt", data, UploadOptions{ChecksumAlgorithm: tc.algorithm})
+			var serr *Error
+			if assert.ErrorAs(t, err, &serr) {
+				assert.Equal(t, "BadDigest", serr.Code)
+			}
+			assert.False(t, mockServer.ObjectExists("bad.txt"))
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := b.Write(ctx, "x.txt", data, UploadOptions{ChecksumAlgorithm: "MD5"})
+		assert.Error(t, err)
+		assert.False(t, mockServer.ObjectExists("x.txt"))
+	})
+}
+
+func TestWriteStreamChecksummed(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	data := make([]byte, 200*1024+17)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	t.Run("ok", func(t *testing.T) {
+		var tee bytes.Buffer
+		etag, err := b.WriteStreamChecksummed(ctx, "stream.bin", bytes.NewBuffer(data), int64(len(data)), UploadOptions{
+			Tee:          &tee,
+			ExtraHeaders: http.Header{"Content-Encoding": {"gzip"}},
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, etag)
+		assert.Equal(t, data, tee.Bytes())
+
+		content, ok := mockServer.ObjectContent("stream.bin")
+		assert.True(t, ok)
+		assert.Equal(t, data, content)
+
+		puts := mockServer.GetRequestsWithMethod("PUT")
+		last := puts[len(puts)-1]
+		assert.Equal(t, aws.StreamingTrailer, last.Headers["X-Amz-Content-Sha256"])
+		assert.Equal(t, "x-amz-checksum-crc32c", last.Headers["X-Amz-Trailer"])
+
+		// the aws-chunked encoding is not stored with the object
+		obj, ok := mockServer.GetObject("stream.bin")
+		if assert.True(t, ok) {
+			assert.Equal(t, "gzip", obj.Headers.Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		tampered := NewBucket(key, bucket)
+		tampered.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			body[bytes.Index(body, []byte("\r\n"))+10] ^= 0xff
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+		_, err := tampered.WriteStreamChecksummed(ctx, "bad.bin", bytes.NewReader(data), int64(len(data)))
+		var serr *Error
+		if assert.ErrorAs(t, err, &serr) {
+			assert.Equal(t, "BadDigest", serr.Code)
+		}
+		assert.False(t, mockServer.ObjectExists("bad.bin"))
+	})
+
+	t.Run("short", func(t *testing.T) {
+		_, err := b.WriteStreamChecksummed(ctx, "short.bin", bytes.NewReader(data[:10]), 100)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.False(t, mockServer.ObjectExists("short.bin"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := b.WriteStreamChecksummed(ctx, "neg.bin", bytes.NewReader(nil), -1)
+		assert.Error(t, err)
+		_, err = b.WriteStreamChecksummed(ctx, ".", bytes.NewReader(nil), 0)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClientConfig configures the HTTP client built by NewClient.
+type ClientConfig struct {
+	// HTTP2, if set, lets the client negotiate HTTP/2 with endpoints
+	// that support it, multiplexing concurrent requests to a host over
+	// a single connection. This is mostly worthwhile on high-latency
+	// links with many small requests, where opening and warming up
+	// HTTP/1.1 connections dominates. AWS S3 itself only speaks
+	// HTTP/1.1, so HTTP2 only matters for S3-compatible backends and
+	// proxies; for large transfers, several HTTP/1.1 connections
+	// usually achieve a higher throughput than a single one.
+	HTTP2 bool
+}
+
+// NewClient returns an HTTP client that is configured like
+// DefaultClient, with the changes described by cfg. The result
+// can be used as Bucket.Client.
+func NewClient(cfg ClientConfig) *http.Client {
+	t := DefaultClient.Transport.(*http.Transport).Clone()
+	if cfg.HTTP2 {
+		// a custom DialContext disables HTTP/2 unless it is forced;
+		// since every request to a host shares the same connection,
+		// ping it when idle so a dead connection is detected early
+		t.ForceAttemptHTTP2 = true
+		t.HTTP2 = &http.HTTP2Config{
+			SendPingTimeout: 30 * time.Second,
+			PingTimeout:     10 * time.Second,
+		}
+	}
+	return &http.Client{Transport: t}
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClient(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		tr := NewClient(ClientConfig{}).Transport.(*http.Transport)
+		assert.False(t, tr.ForceAttemptHTTP2)
+		assert.True(t, tr.DisableCompression)
+		assert.NotSame(t, DefaultClient.Transport, tr)
+	})
+
+	t.Run("mock", func(t *testing.T) {
+		bucket := "test-bucket"
+		mockServer := mock.New(bucket, "us-east-1")
+		defer mockServer.Close()
+
+		key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+		key.BaseURI = mockServer.URL()
+
+		b := NewBucket(key, bucket)
+		b.Client = NewClient(ClientConfig{HTTP2: true})
+		assert.True(t, b.Client.Transport.(*http.Transport).ForceAttemptHTTP2)
+
+		_, err := b.Write(context.Background(), "h2.txt", []byte("hello"))
+		assert.NoError(t, err)
+		data, err := b.ReadFile("h2.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+	})
+
+	t.Run("negotiate", func(t *testing.T) {
+		var proto atomic.Int32
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proto.Store(int32(r.ProtoMajor))
+		}))
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+		defer srv.Close()
+
+		cl := NewClient(ClientConfig{HTTP2: true})
+		tr := cl.Transport.(*http.Transport)
+		tr.TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+		res, err := cl.Get(srv.URL)
+		assert.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, 2, res.ProtoMajor)
+		assert.Equal(t, int32(2), proto.Load())
+	})
+}
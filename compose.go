@@ -25,7 +25,7 @@ func (b *Bucket) Compose(ctx context.Context, key string, parts []CopyPart) (str
 		return "", fmt.Errorf("s3 Compose: invalid part count %d", len(parts))
 	}
 
-	u := &uploader{Key: b.key, Client: b.Client, Bucket: b.bkt, Object: key}
+	u := &uploader{Key: b.key, Client: b.Client, Bucket: b.bkt, Object: key, UserAgent: b.UserAgent, Limiter: b.Limiter, Logger: b.Logger, MinPartOverride: b.MinPartOverride, stats: b.stats}
 	if err := u.Start(ctx); err != nil {
 		return "", fmt.Errorf("s3 Compose: %w", err)
 	}
@@ -37,11 +37,12 @@ func (b *Bucket) Compose(ctx context.Context, key string, parts []CopyPart) (str
 	}()
 
 	for i, part := range parts {
-		if part.SourceKey = path.Clean(part.SourceKey); !fs.ValidPath(part.SourceKey) || part.ETag == "" || part.Offset < 0 || part.Size < MinPartSize {
+		if part.SourceKey = path.Clean(part.SourceKey); !fs.ValidPath(part.SourceKey) || part.ETag == "" || part.Offset < 0 || part.Size < int64(u.MinPartSize()) {
 			return "", fmt.Errorf("s3 Compose: invalid part %d", i+1)
 		}
-		source := &Reader{Key: b.key, Client: b.Client, Bucket: b.bkt, Path: part.SourceKey, ETag: part.ETag, Size: part.Offset + part.Size}
-		if err := u.CopyFrom(ctx, int64(i+1), source, part.Offset, part.Offset+part.Size); err != nil {
+		source := b.reader()
+		source.Path, source.ETag, source.Size = part.SourceKey, part.ETag, part.Offset+part.Size
+		if err := u.CopyFrom(ctx, int64(i+1), &source, part.Offset, part.Offset+part.Size); err != nil {
 			return "", fmt.Errorf("s3 Compose: part %d: %w", i+1, err)
 		}
 	}
@@ -51,3 +52,120 @@ func (b *Bucket) Compose(ctx context.Context, key string, parts []CopyPart) (str
 	complete = true
 	return u.ETag(), nil
 }
+
+// AssemblyPart is a part of an object built by Assemble: either
+// new contents to upload, or a byte range of an existing object
+// to copy on the server side.
+type AssemblyPart struct {
+	Data []byte // Data is the contents of the part, if it is not copied.
+
+	// SourceKey, if not empty, is the object whose bytes [Start, End)
+	// are copied into the part. If Start and End are both zero, the
+	// whole object is copied. Data must be empty when copying.
+	SourceKey  string
+	Start, End int64
+}
+
+// Assemble creates the object at key by concatenating parts in order
+// with a multipart upload, uploading the parts that carry Data and
+// copying the others from existing objects without downloading them,
+// and returns the ETag of the new object.
+//
+// As with any multipart upload, every part but the last must be at
+// least MinPartSize bytes long, unless MinPartOverride is set. Each
+// source object is checked once with a HEAD request, and is copied
+// only if it has not changed since.
+func (b *Bucket) Assemble(ctx context.Context, key string, parts []AssemblyPart) (string, error) {
+	key = path.Clean(key)
+	switch {
+	case !fs.ValidPath(key) || key == ".":
+		return "", badpath("s3 Assemble", key)
+	case len(parts) == 0 || len(parts) > MaxParts:
+		return "", fmt.Errorf("s3 Assemble: invalid part count %d", len(parts))
+	}
+
+	u := &uploader{Key: b.key, Client: b.Client, Bucket: b.bkt, Object: key, UserAgent: b.UserAgent, Limiter: b.Limiter, Logger: b.Logger, MinPartOverride: b.MinPartOverride, stats: b.stats}
+	sources := make(map[string]*Reader)
+	for i := range parts {
+		part := &parts[i]
+		if part.SourceKey == "" {
+			if len(part.Data) == 0 {
+				return "", fmt.Errorf("s3 Assemble: part %d is empty", i+1)
+			}
+			continue
+		}
+		src := path.Clean(part.SourceKey)
+		switch {
+		case len(part.Data) > 0:
+			return "", fmt.Errorf("s3 Assemble: part %d has both data and a source", i+1)
+		case !fs.ValidPath(src) || src == ".":
+			return "", badpath("s3 Assemble", part.SourceKey)
+		case sources[src] != nil:
+			continue
+		}
+		r := b.reader()
+		body, err := r.open(b.key, b.bkt, src, false)
+		if body != nil {
+			body.Close()
+		}
+		if err != nil {
+			return "", fmt.Errorf("s3 Assemble: part %d: %w", i+1, err)
+		}
+		r.Client = b.Client
+		sources[src] = &r
+	}
+
+	if err := u.Start(ctx); err != nil {
+		return "", fmt.Errorf("s3 Assemble: %w", err)
+	}
+	complete := false
+	defer func() {
+		if !complete {
+			_ = u.Abort(context.WithoutCancel(ctx))
+		}
+	}()
+
+	for i, part := range parts {
+		num, last := int64(i+1), i == len(parts)-1
+		switch {
+		case part.SourceKey == "" && last:
+			// the last part may be smaller than MinPartSize
+			if err := u.upload(ctx, num, part.Data); err != nil {
+				return "", fmt.Errorf("s3 Assemble: part %d: %w", num, err)
+			}
+		case part.SourceKey == "":
+			if err := u.uploadWithContext(ctx, num, part.Data); err != nil {
+				return "", fmt.Errorf("s3 Assemble: part %d: %w", num, err)
+			}
+		default:
+			source := sources[path.Clean(part.SourceKey)]
+			if err := assembleCopy(ctx, u, num, source, part.Start, part.End, last); err != nil {
+				return "", fmt.Errorf("s3 Assemble: part %d: %w", num, err)
+			}
+		}
+	}
+	if err := u.Close(ctx, nil); err != nil {
+		return "", fmt.Errorf("s3 Assemble: %w", err)
+	}
+	complete = true
+	return u.ETag(), nil
+}
+
+// assembleCopy copies bytes [start, end) of source into the part num
+// of u. Unlike u.CopyFrom, it lets the last part be smaller than
+// the minimum part size, in which case the copy is synchronous.
+func assembleCopy(ctx context.Context, u *uploader, num int64, source *Reader, start, end int64, last bool) error {
+	size := source.Size
+	if start != 0 || end != 0 {
+		if start < 0 || end <= start || end > source.Size {
+			return fmt.Errorf("invalid range [%d, %d) of %s with size %d", start, end, source.Path, source.Size)
+		}
+		size = end - start
+	}
+	if !last || size >= int64(u.MinPartSize()) {
+		return u.CopyFrom(ctx, num, source, start, end)
+	}
+	u.bg.Add(1)
+	u.copy(ctx, num, source, start, end)
+	return nil
+}
@@ -1,7 +1,10 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"io/fs"
+	"strings"
 	"testing"
 
 	"github.com/kelindar/s3/aws"
@@ -55,3 +58,78 @@ func TestCompose(t *testing.T) {
 		require.Empty(t, server.ListMultipartUploads())
 	})
 }
+
+func TestAssemble(t *testing.T) {
+	server := mock.New("test-bucket", "us-east-1")
+	defer server.Close()
+	key := aws.DeriveKey("", "test", "test", "us-east-1", "s3")
+	key.BaseURI = server.URL()
+	bucket := NewBucket(key, "test-bucket")
+	ctx := context.Background()
+
+	source := make([]byte, 2*MinPartSize)
+	for i := range source {
+		source[i] = byte('a' + i%26)
+	}
+	_, err := bucket.Write(ctx, "source.log", source)
+	require.NoError(t, err)
+
+	t.Run("copy and put", func(t *testing.T) {
+		tail := []byte("new bytes at the end")
+		etag, err := bucket.Assemble(ctx, "out.log", []AssemblyPart{
+			{SourceKey: "source.log", Start: 100, End: 100 + MinPartSize},
+			{Data: tail},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, etag)
+		obj, ok := server.GetObject("out.log")
+		require.True(t, ok)
+		require.Equal(t, append(bytes.Clone(source[100:100+MinPartSize]), tail...), obj.Content)
+
+		// the first part was copied rather than uploaded
+		var copied, uploaded int
+		for _, req := range server.GetRequestsWithMethod("PUT") {
+			if !strings.Contains(req.Query, "partNumber") {
+				continue
+			}
+			if req.Headers["X-Amz-Copy-Source"] != "" {
+				copied++
+				require.Equal(t, "bytes=100-5242979", req.Headers["X-Amz-Copy-Source-Range"])
+			} else {
+				uploaded++
+			}
+		}
+		require.Equal(t, 1, copied)
+		require.Equal(t, 1, uploaded)
+	})
+
+	t.Run("put and copy", func(t *testing.T) {
+		head := make([]byte, MinPartSize)
+		_, err := bucket.Assemble(ctx, "mixed.log", []AssemblyPart{
+			{Data: head},
+			{SourceKey: "source.log", Start: 10, End: 20},
+		})
+		require.NoError(t, err)
+		obj, ok := server.GetObject("mixed.log")
+		require.True(t, ok)
+		require.Equal(t, append(head, source[10:20]...), obj.Content)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, parts := range [][]AssemblyPart{
+			nil,
+			{{}},
+			{{Data: []byte("x"), SourceKey: "source.log"}},
+			{{SourceKey: "source.log", Start: 10, End: 5}},
+			{{SourceKey: "source.log", End: 3 * MinPartSize}},
+			{{Data: []byte("too small")}, {Data: []byte("x")}},
+		} {
+			_, err := bucket.Assemble(ctx, "bad.log", parts)
+			require.Error(t, err)
+		}
+		_, err := bucket.Assemble(ctx, "bad.log", []AssemblyPart{{SourceKey: "missing.log"}})
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		require.False(t, server.ObjectExists("bad.log"))
+		require.Empty(t, server.ListMultipartUploads())
+	})
+}
@@ -17,8 +17,10 @@ package s3
 
 import (
 	"context"
+	"crypto/md5"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"path"
@@ -28,12 +30,24 @@ import (
 	"github.com/kelindar/s3/fsutil"
 )
 
+// DefaultSeekWindow is the SeekWindow used
+// by a File whose SeekWindow is zero.
+const DefaultSeekWindow = 1 << 20
+
 // File implements fs.File
 type File struct {
 	Reader                 // Reader is a reader that points to the associated s3 object.
 	ctx    context.Context // from parent bucket
 	body   io.ReadCloser   // actual body; populated lazily
 	pos    int64           // current read offset
+	sum    hash.Hash       // running digest when Verify is set
+
+	// SeekWindow is the largest forward seek, in bytes, that skips
+	// over the contents of the body being read rather than closing
+	// it, which saves a request on the next Read. If it is zero,
+	// DefaultSeekWindow is used; if it is negative, every seek that
+	// changes the position closes the body.
+	SeekWindow int64 `xml:"-"`
 }
 
 // Name implements fs.FileInfo.Name
@@ -54,6 +68,20 @@ func (f *File) Mode() fs.FileMode { return 0644 }
 // Open implements fsutil.Opener
 func (f *File) Open() (fs.File, error) { return f, nil }
 
+// AsReader returns the Reader of f if it is a *File,
+// e.g. one passed to the callback of fsutil.WalkGlob,
+// so that parts of the object can be read with
+// RangeReader or ReadAt without reading the file.
+// The Reader is shared with f, which must not be
+// used concurrently with it; use Clone otherwise.
+func AsReader(f fs.File) (*Reader, bool) {
+	file, ok := f.(*File)
+	if !ok || file == nil {
+		return nil, false
+	}
+	return &file.Reader, true
+}
+
 // Read implements fs.File.Read
 //
 // Note: Read is not safe to call from
@@ -67,12 +95,21 @@ func (f *File) Open() (fs.File, error) { return f, nil }
 // another offset set via Seek).
 // If you need to read a sub-range of the
 // object, consider using f.Reader.RangeReader
+//
+// If the body of the object ends before f.Size bytes
+// have been read, Read returns an error matching
+// io.ErrUnexpectedEOF rather than io.EOF.
+//
+// If f.Verify is set and the object is read
+// sequentially from the beginning, Read returns
+// an error matching ErrChecksumMismatch at the
+// end of the object if its contents do not match
+// the ETag.
 func (f *File) Read(p []byte) (int, error) {
 	if f.body != nil {
 		n, err := f.body.Read(p)
-		f.pos += int64(n)
 		if n > 0 || errors.Is(err, io.EOF) {
-			return n, err
+			return f.advance(p[:n], err)
 		}
 		// fall through here and re-try the request;
 		// occasionally S3 will send us an RST for not
@@ -92,8 +129,29 @@ func (f *File) Read(p []byte) (int, error) {
 	}
 
 	n, err := f.body.Read(p)
-	f.pos += int64(n)
-	return n, err
+	return f.advance(p[:n], err)
+}
+
+// advance moves the read offset past the bytes
+// in p, updating and checking the running digest
+// if verification is enabled.
+func (f *File) advance(p []byte, err error) (int, error) {
+	if f.Verify && f.pos == 0 && f.sum == nil {
+		f.sum = md5.New()
+	}
+	if f.sum != nil {
+		f.sum.Write(p)
+	}
+	f.pos += int64(len(p))
+	if errors.Is(err, io.EOF) && f.pos < f.Size() {
+		return len(p), f.Reader.shortRead(f.pos)
+	}
+	if f.sum != nil && errors.Is(err, io.EOF) && f.pos == f.Size() {
+		if verr := f.Reader.verify(f.sum.Sum(nil)); verr != nil {
+			return len(p), verr
+		}
+	}
+	return len(p), err
 }
 
 // Info implements fs.DirEntry.Info
@@ -116,6 +174,7 @@ func (f *File) Close() error {
 	err := f.body.Close()
 	f.body = nil
 	f.pos = 0
+	f.sum = nil
 	return err
 }
 
@@ -123,6 +182,12 @@ func (f *File) Close() error {
 //
 // Seek rejects offsets that are beyond
 // the size of the underlying object.
+//
+// A forward seek of at most f.SeekWindow bytes
+// skips over the contents of the body being read.
+// Any other seek that changes the position closes
+// the body, so that the next Read makes a new
+// request starting at the new position.
 func (f *File) Seek(offset int64, whence int) (int64, error) {
 	var newpos int64
 	switch whence {
@@ -138,16 +203,40 @@ func (f *File) Seek(offset int64, whence int) (int64, error) {
 	if newpos < 0 || newpos > f.Reader.Size {
 		return f.pos, fmt.Errorf("invalid seek offset %d", newpos)
 	}
+	if f.body != nil && newpos > f.pos && newpos-f.pos <= f.seekWindow() {
+		// skip forward within the current body,
+		// keeping the running digest up to date
+		var w io.Writer = io.Discard
+		if f.sum != nil {
+			w = f.sum
+		}
+		n, err := io.CopyN(w, f.body, newpos-f.pos)
+		f.pos += n
+		if err == nil {
+			return f.pos, nil
+		}
+	}
 	// current data is invalid
 	// if the position has changed
 	if newpos != f.pos && f.body != nil {
 		f.body.Close()
 		f.body = nil
 	}
+	if newpos != f.pos {
+		f.sum = nil
+	}
 	f.pos = newpos
 	return f.pos, nil
 }
 
+// seekWindow returns the effective SeekWindow of f.
+func (f *File) seekWindow() int64 {
+	if f.SeekWindow == 0 {
+		return DefaultSeekWindow
+	}
+	return f.SeekWindow
+}
+
 func (f *File) Size() int64 {
 	return f.Reader.Size
 }
@@ -15,13 +15,18 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"io"
 	"io/fs"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/fsutil"
 	"github.com/kelindar/s3/mock"
 	"github.com/stretchr/testify/assert"
 )
@@ -173,6 +178,78 @@ func TestFile(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("seek window", func(t *testing.T) {
+		bucket := "test-bucket"
+		mockServer := mock.New(bucket, "us-east-1")
+		defer mockServer.Close()
+
+		key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+		key.BaseURI = mockServer.URL()
+
+		content := bytes.Repeat([]byte("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"), 10)
+		objectKey := "test/seek-window.txt"
+		mockServer.PutObject(objectKey, content)
+		gets := func() int { return len(mockServer.GetRequestsWithMethod("GET")) }
+
+		// read sets up the body, seeks by skip and reads again,
+		// returning the number of GET requests for the reads
+		read := func(t *testing.T, file *File, skip int64) int {
+			before := gets()
+			buf := make([]byte, 10)
+			_, err := io.ReadFull(file, buf)
+			assert.NoError(t, err)
+			pos, err := file.Seek(skip, io.SeekCurrent)
+			assert.NoError(t, err)
+			_, err = io.ReadFull(file, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, content[pos:pos+10], buf)
+			return gets() - before
+		}
+
+		t.Run("forward", func(t *testing.T) {
+			file, err := Open(key, bucket, objectKey, false)
+			assert.NoError(t, err)
+			defer file.Close()
+			assert.Equal(t, 1, read(t, file, 100))
+		})
+
+		t.Run("beyond window", func(t *testing.T) {
+			file, err := Open(key, bucket, objectKey, false)
+			assert.NoError(t, err)
+			defer file.Close()
+			file.SeekWindow = 50
+			assert.Equal(t, 2, read(t, file, 100))
+		})
+
+		t.Run("disabled", func(t *testing.T) {
+			file, err := Open(key, bucket, objectKey, false)
+			assert.NoError(t, err)
+			defer file.Close()
+			file.SeekWindow = -1
+			assert.Equal(t, 2, read(t, file, 1))
+		})
+
+		t.Run("backward", func(t *testing.T) {
+			file, err := Open(key, bucket, objectKey, false)
+			assert.NoError(t, err)
+			defer file.Close()
+			assert.Equal(t, 2, read(t, file, -5))
+		})
+
+		t.Run("verify", func(t *testing.T) {
+			file, err := Open(key, bucket, objectKey, false)
+			assert.NoError(t, err)
+			defer file.Close()
+			file.Verify = true
+
+			// the skipped bytes are still part of the digest
+			assert.Equal(t, 1, read(t, file, 100))
+			rest, err := io.ReadAll(file)
+			assert.NoError(t, err)
+			assert.Equal(t, content[120:], rest)
+		})
+	})
+
 	t.Run("empty", func(t *testing.T) {
 		bucket := "test-bucket"
 		mockServer := mock.New(bucket, "us-east-1")
@@ -239,7 +316,7 @@ func TestFile(t *testing.T) {
 
 		content := []byte("ModTime test")
 		objectKey := "test/modtime.txt"
-		beforePut := time.Now()
+		beforePut := time.Now().Truncate(time.Second) // Last-Modified has second precision
 		mockServer.PutObject(objectKey, content)
 		afterPut := time.Now()
 
@@ -363,4 +440,129 @@ func TestFile(t *testing.T) {
 			assert.Equal(t, 10, n)
 		}
 	})
+
+	t.Run("verify", func(t *testing.T) {
+		bucket := "test-bucket"
+		mockServer := mock.New(bucket, "us-east-1")
+		defer mockServer.Close()
+
+		key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+		key.BaseURI = mockServer.URL()
+
+		content := []byte("Verified read test content")
+		objectKey := "test/verify.txt"
+		mockServer.PutObject(objectKey, content)
+
+		file, err := Open(key, bucket, objectKey, false)
+		assert.NoError(t, err)
+		defer file.Close()
+		file.Verify = true
+
+		readContent, err := io.ReadAll(file)
+		assert.NoError(t, err)
+		assert.Equal(t, content, readContent)
+
+		var buf bytes.Buffer
+		_, err = file.Reader.WriteTo(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, content, buf.Bytes())
+
+		// a digest that does not match the ETag is reported
+		err = file.Reader.verify(make([]byte, md5.Size))
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+		// multipart ETags cannot be verified
+		other := Reader{ETag: `"0123-2"`}
+		assert.NoError(t, other.verify(nil))
+	})
+	t.Run("as reader", func(t *testing.T) {
+		bucket := "test-bucket"
+		mockServer := mock.New(bucket, "us-east-1")
+		defer mockServer.Close()
+
+		key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+		key.BaseURI = mockServer.URL()
+		mockServer.PutObject("tree/a/one.log", []byte("first log file"))
+		mockServer.PutObject("tree/a/two.txt", []byte("not a log file"))
+		mockServer.PutObject("tree/b/three.log", []byte("third log file"))
+
+		b := NewBucket(key, bucket)
+		heads := make(map[string]string)
+		err := fsutil.WalkGlob(b, "", "tree/*/*.log", func(p string, f fs.File, err error) error {
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r, ok := AsReader(f)
+			assert.True(t, ok)
+			assert.Equal(t, p, r.Path)
+
+			rc, err := r.RangeReader(0, 5)
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			head, err := io.ReadAll(rc)
+			heads[p] = string(head)
+			return err
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"tree/a/one.log":   "first",
+			"tree/b/three.log": "third",
+		}, heads)
+
+		_, ok := AsReader(nil)
+		assert.False(t, ok)
+		_, ok = AsReader((*File)(nil))
+		assert.False(t, ok)
+	})
+	t.Run("verify encrypted", func(t *testing.T) {
+		bucket := "test-bucket"
+		mockServer := mock.New(bucket, "us-east-1")
+		defer mockServer.Close()
+
+		key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+		key.BaseURI = mockServer.URL()
+		b := NewBucket(key, bucket)
+		ctx := context.Background()
+		content := []byte("encrypted contents whose ETag is not their MD5")
+
+		ssec := make(http.Header)
+		ssec.Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
+		for name, opts := range map[string]UploadOptions{
+			"enc/kms.txt":  {Encryption: "aws:kms"},
+			"enc/dsse.txt": {Encryption: "aws:kms:dsse"},
+			"enc/ssec.txt": {ExtraHeaders: ssec},
+		} {
+			etag, err := b.Write(ctx, name, content, opts)
+			assert.NoError(t, err)
+			sum := md5.Sum(content)
+			assert.NotEqual(t, `"`+hex.EncodeToString(sum[:])+`"`, etag, name)
+
+			f, err := b.Open(name)
+			assert.NoError(t, err)
+			file := f.(*File)
+			assert.True(t, file.Encryption != "" || file.SSECustomerAlgorithm != "", name)
+			file.Verify = true
+			data, err := io.ReadAll(file)
+			assert.NoError(t, err, name)
+			assert.Equal(t, content, data)
+			assert.NoError(t, file.Close())
+		}
+
+		// the encryption of listed files is only known once read
+		entries, err := fs.ReadDir(b, "enc")
+		assert.NoError(t, err)
+		assert.Len(t, entries, 3)
+		for _, entry := range entries {
+			file := entry.(*File)
+			assert.Empty(t, file.Encryption)
+			file.Verify = true
+			var buf bytes.Buffer
+			_, err := file.Reader.WriteTo(&buf)
+			assert.NoError(t, err, entry.Name())
+			assert.Equal(t, content, buf.Bytes())
+		}
+	})
 }
@@ -22,6 +22,7 @@ import (
 	"io/fs"
 	"path"
 	"strconv"
+	"strings"
 	"unicode/utf8"
 )
 
@@ -622,6 +623,50 @@ func match(pattern, name string) bool {
 }
 
 func stat(f fs.FS, name string) (DirEntry, error) {
+	if vf, ok := f.(VisitDirFS); ok && name != "." {
+		return statVisit(vf, name)
+	}
 	info, err := fs.Stat(f, name)
 	return fs.FileInfoToDirEntry(info), err
 }
+
+// statVisit finds name by visiting its parent directory
+// rather than by opening it. Object stores can have a file
+// and a directory with the same name, e.g. the key "x" and
+// the keys under "x/", in which case the directory is
+// returned so that the walk still descends into it.
+func statVisit(f VisitDirFS, name string) (DirEntry, error) {
+	var found DirEntry
+	base := path.Base(name)
+	err := VisitDir(f, path.Dir(name), "", escapeMeta(base), func(d DirEntry) error {
+		if d.Name() != base {
+			return nil
+		}
+		found = d
+		if d.IsDir() {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, patherr("stat", name, fs.ErrNotExist)
+	}
+	return found, nil
+}
+
+// escapeMeta escapes the characters of name
+// that have a special meaning in a pattern.
+func escapeMeta(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '*', '?', '\\', '[':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
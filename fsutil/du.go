@@ -0,0 +1,62 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package fsutil
+
+import (
+	"io/fs"
+)
+
+// DiskUsageFS can be implemented by a file
+// system that provides an optimized
+// implementation of DiskUsage.
+type DiskUsageFS interface {
+	fs.FS
+	DiskUsage(root string) (count int, bytes int64, err error)
+}
+
+// DiskUsage returns the number of files in the
+// tree rooted at root and the sum of their
+// sizes. Directories are not counted.
+//
+// If f implements DiskUsageFS, f.DiskUsage is
+// called directly, which allows, for example,
+// an object store to use a single recursive
+// listing. Otherwise, this walks the tree with
+// fs.WalkDir and sums Info().Size() of each
+// file.
+func DiskUsage(f fs.FS, root string) (count int, bytes int64, err error) {
+	if !fs.ValidPath(root) {
+		return 0, 0, patherr("diskusage", root, fs.ErrInvalid)
+	}
+	if f, ok := f.(DiskUsageFS); ok {
+		return f.DiskUsage(root)
+	}
+	err = fs.WalkDir(f, root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		count++
+		bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, bytes, nil
+}
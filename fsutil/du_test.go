@@ -0,0 +1,78 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package fsutil
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type duFS struct {
+	fstest.MapFS
+	root string
+}
+
+func (d *duFS) DiskUsage(root string) (int, int64, error) {
+	d.root = root
+	return 42, 4200, nil
+}
+
+func TestDiskUsage(t *testing.T) {
+	dir := fstest.MapFS{
+		"a.txt":       {Data: []byte("hello")},
+		"b/c.txt":     {Data: []byte("world!")},
+		"b/d/e.txt":   {Data: []byte("!")},
+		"b/empty":     {Mode: fs.ModeDir},
+		"other/f.txt": {Data: []byte("ignored")},
+	}
+
+	t.Run("walk", func(t *testing.T) {
+		count, bytes, err := DiskUsage(dir, "b")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, int64(7), bytes)
+
+		count, bytes, err = DiskUsage(dir, ".")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, count)
+		assert.Equal(t, int64(19), bytes)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		count, bytes, err := DiskUsage(dir, "a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, int64(5), bytes)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := DiskUsage(dir, "missing")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, _, err = DiskUsage(dir, "/abs")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+
+	t.Run("DiskUsageFS", func(t *testing.T) {
+		du := &duFS{MapFS: dir}
+		count, bytes, err := DiskUsage(du, "b")
+		assert.NoError(t, err)
+		assert.Equal(t, 42, count)
+		assert.Equal(t, int64(4200), bytes)
+		assert.Equal(t, "b", du.root)
+	})
+}
@@ -16,6 +16,7 @@
 package fsutil
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 )
@@ -44,26 +45,46 @@ type WalkGlobFn func(name string, file fs.File, err error) error
 // fs.File, it will be passed to walk directly.
 // Otherwise, fs.Open will be used.
 func WalkGlob(f fs.FS, seek, pattern string, walk WalkGlobFn) error {
+	return WalkGlobContext(context.Background(), f, seek, pattern, walk)
+}
+
+// WalkGlobContext is like WalkGlob, but it checks
+// ctx before visiting each directory and opening
+// each file, and stops walking with ctx.Err()
+// once ctx is canceled.
+func WalkGlobContext(ctx context.Context, f fs.FS, seek, pattern string, walk WalkGlobFn) error {
 	if pattern == "" {
 		return fmt.Errorf("fsutil.WalkGlob: pattern is required")
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	pre := MetaPrefix(pattern)
 	outer := func(p string, d DirEntry, err error) error {
+		if cerr := ctx.Err(); cerr != nil {
+			return cerr
+		}
 		if err != nil {
 			return walk(p, nil, err)
 		}
 		if d.IsDir() {
 			return nil
 		}
-		if f, ok := d.(fs.File); ok {
-			return walk(p, f, nil)
-		}
-		file, err := f.Open(p)
+		file, err := open(f, p, d)
 		return walk(p, file, err)
 	}
 	return WalkDir(f, pre, seek, pattern, outer)
 }
 
+// open returns d if it implements fs.File
+// and otherwise opens p in f.
+func open(f fs.FS, p string, d DirEntry) (fs.File, error) {
+	if file, ok := d.(fs.File); ok {
+		return file, nil
+	}
+	return f.Open(p)
+}
+
 // MetaPrefix finds the longest directory path for
 // which we can begin searching for a glob pattern.
 func MetaPrefix(pattern string) string {
@@ -118,3 +139,119 @@ func OpenGlob(f fs.FS, pattern string) ([]NamedFile, error) {
 	err := WalkGlob(f, "", pattern, walk)
 	return out, err
 }
+
+// OpenedFile is a file delivered by OpenGlobStream.
+// If the file could not be opened, or walking the
+// tree failed, then File is nil and Err is set.
+type OpenedFile struct {
+	File NamedFile
+	Path string
+	Err  error
+}
+
+// OpenGlobStream is equivalent to OpenGlobStreamContext
+// with a context that is canceled by the returned stop
+// function. A consumer that does not drain the channel
+// must call stop, which ends the walk and closes every
+// file that was opened but not received before it returns.
+// Calling stop after draining the channel is harmless.
+func OpenGlobStream(f fs.FS, pattern string, parallel int) (<-chan OpenedFile, func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := OpenGlobStreamContext(ctx, f, pattern, parallel)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	stop := func() {
+		cancel()
+		for res := range out {
+			discard(res)
+		}
+	}
+	return out, stop, nil
+}
+
+// OpenGlobStreamContext walks f for files matching
+// pattern and opens up to parallel of them concurrently,
+// delivering them on the returned channel in the order
+// in which WalkGlob would visit them. The channel is
+// closed once walking completes.
+//
+// The consumer is responsible for closing every file
+// it receives. If ctx is canceled, walking stops and
+// any files that were opened but not yet delivered
+// are closed before the channel is closed.
+func OpenGlobStreamContext(ctx context.Context, f fs.FS, pattern string, parallel int) (<-chan OpenedFile, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("fsutil.OpenGlobStream: pattern is required")
+	}
+	if err := validpat(pattern); err != nil {
+		return nil, patherr("openglob", pattern, err)
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	// each matched file gets a result channel queued
+	// in pending, which bounds the number of files
+	// being opened and preserves the walk order
+	pending := make(chan chan OpenedFile, parallel)
+	enqueue := func(fn func() OpenedFile) error {
+		r := make(chan OpenedFile, 1)
+		select {
+		case pending <- r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		go func() { r <- fn() }()
+		return nil
+	}
+	go func() {
+		defer close(pending)
+		err := WalkDir(f, MetaPrefix(pattern), "", pattern, func(p string, d DirEntry, err error) error {
+			if err != nil {
+				return enqueue(func() OpenedFile { return OpenedFile{Path: p, Err: err} })
+			}
+			if d.IsDir() {
+				return nil
+			}
+			return enqueue(func() OpenedFile {
+				file, err := open(f, p, d)
+				if err != nil {
+					return OpenedFile{Path: p, Err: err}
+				}
+				return OpenedFile{File: Named(file, p), Path: p}
+			})
+		})
+		if err != nil && ctx.Err() == nil {
+			enqueue(func() OpenedFile { return OpenedFile{Err: err} })
+		}
+	}()
+
+	out := make(chan OpenedFile)
+	go func() {
+		defer close(out)
+		for r := range pending {
+			res := <-r
+			select {
+			case out <- res:
+				continue
+			case <-ctx.Done():
+			}
+			// the consumer is gone; close everything
+			// that has been or is about to be opened
+			discard(res)
+			for r := range pending {
+				discard(<-r)
+			}
+			return
+		}
+	}()
+	return out, nil
+}
+
+func discard(res OpenedFile) {
+	if res.File != nil {
+		res.File.Close()
+	}
+}
@@ -16,12 +16,18 @@
 package fsutil
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -101,6 +107,141 @@ func TestOpenGlob(t *testing.T) {
 	}
 }
 
+// countingFS tracks how many files
+// are opened and closed.
+type countingFS struct {
+	fs.FS
+	opened, closed atomic.Int32
+}
+
+type countingFile struct {
+	fs.File
+	fs *countingFS
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	c.opened.Add(1)
+	return &countingFile{File: f, fs: c}, nil
+}
+
+func (c *countingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(c.FS, name)
+}
+
+func (c *countingFile) Close() error {
+	c.fs.closed.Add(1)
+	return c.File.Close()
+}
+
+func TestOpenGlobStream(t *testing.T) {
+	tmp := t.TempDir()
+	var want []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("d%d/f%02d", i%3, i)
+		assert.NoError(t, os.MkdirAll(filepath.Join(tmp, filepath.Dir(name)), 0750))
+		assert.NoError(t, os.WriteFile(filepath.Join(tmp, name), []byte(name), 0640))
+		want = append(want, name)
+	}
+
+	t.Run("all", func(t *testing.T) {
+		cfs := &countingFS{FS: os.DirFS(tmp)}
+		files, stop, err := OpenGlobStream(cfs, "d*/f*", 4)
+		assert.NoError(t, err)
+		defer stop()
+
+		var got []string
+		for f := range files {
+			if !assert.NoError(t, f.Err) {
+				continue
+			}
+			body, err := io.ReadAll(f.File)
+			assert.NoError(t, err)
+			assert.Equal(t, f.Path, string(body))
+			assert.Equal(t, f.Path, f.File.Path())
+			assert.NoError(t, f.File.Close())
+			got = append(got, f.Path)
+		}
+		assert.ElementsMatch(t, want, got)
+		assert.True(t, slices.IsSortedFunc(got, pathcmp), "files delivered out of order: %v", got)
+		assert.Equal(t, cfs.opened.Load(), cfs.closed.Load())
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		cfs := &countingFS{FS: os.DirFS(tmp)}
+		ctx, cancel := context.WithCancel(context.Background())
+		files, err := OpenGlobStreamContext(ctx, cfs, "d*/f*", 4)
+		assert.NoError(t, err)
+
+		first := <-files
+		assert.NoError(t, first.Err)
+		assert.NoError(t, first.File.Close())
+		cancel()
+		for f := range files {
+			// anything delivered after cancel
+			// is still owned by the consumer
+			f.File.Close()
+		}
+		assert.Equal(t, cfs.opened.Load(), cfs.closed.Load())
+	})
+
+	t.Run("abandon", func(t *testing.T) {
+		cfs := &countingFS{FS: os.DirFS(tmp)}
+		ctx, cancel := context.WithCancel(context.Background())
+		files, err := OpenGlobStreamContext(ctx, cfs, "d*/f*", 4)
+		assert.NoError(t, err)
+
+		first := <-files
+		assert.NoError(t, first.Err)
+		assert.NoError(t, first.File.Close())
+
+		// stop receiving altogether once files have been
+		// opened ahead of the consumer; they must be closed
+		assert.Eventually(t, func() bool {
+			return cfs.opened.Load() > 1
+		}, 5*time.Second, time.Millisecond)
+		cancel()
+		assert.Eventually(t, func() bool {
+			return cfs.opened.Load() == cfs.closed.Load()
+		}, 5*time.Second, time.Millisecond)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		cfs := &countingFS{FS: os.DirFS(tmp)}
+		files, stop, err := OpenGlobStream(cfs, "d*/f*", 4)
+		assert.NoError(t, err)
+
+		first := <-files
+		assert.NoError(t, first.Err)
+		assert.NoError(t, first.File.Close())
+
+		// stopping closes every file opened
+		// ahead of the consumer before returning
+		stop()
+		_, ok := <-files
+		assert.False(t, ok)
+		assert.Equal(t, cfs.opened.Load(), cfs.closed.Load())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := OpenGlobStream(os.DirFS(tmp), "", 1)
+		assert.Error(t, err)
+		_, _, err = OpenGlobStream(os.DirFS(tmp), "[", 1)
+		assert.Error(t, err)
+
+		files, stop, err := OpenGlobStream(os.DirFS(tmp), "missing/*", 1)
+		assert.NoError(t, err)
+		defer stop()
+		for f := range files {
+			assert.Error(t, f.Err)
+			assert.Nil(t, f.File)
+		}
+	})
+}
+
 // test that WalkGlob doesn't do too many
 // unnecessary call as it walks a file tree.
 func TestWalkGlobOps(t *testing.T) {
@@ -179,3 +320,66 @@ func (f *traceFS) VisitDir(name, seek, pattern string, fn VisitDirFn) error {
 func (f *traceFS) logf(fm string, args ...any) {
 	f.ops = append(f.ops, fmt.Sprintf(fm, args...))
 }
+
+func TestWalkGlobContext(t *testing.T) {
+	// a deep tree of 4 levels with 2 files in each leaf
+	tree := fstest.MapFS{}
+	for _, a := range "abcd" {
+		for _, b := range "efgh" {
+			for _, c := range "ijkl" {
+				for _, d := range "mn" {
+					tree[fmt.Sprintf("%c/%c/%c/%c", a, b, c, d)] = &fstest.MapFile{Data: []byte("x")}
+				}
+			}
+		}
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		var n int
+		err := WalkGlobContext(context.Background(), tree, "", "*/*/*/*", func(p string, f fs.File, err error) error {
+			if err != nil {
+				return err
+			}
+			n++
+			return f.Close()
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 4*4*4*2, n)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tfs := &traceFS{fs: tree}
+		var got []string
+		err := WalkGlobContext(ctx, tfs, "", "*/*/*/*", func(p string, f fs.File, err error) error {
+			if err != nil {
+				return err
+			}
+			got = append(got, p)
+			if len(got) == 3 {
+				cancel()
+			}
+			return f.Close()
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, []string{"a/e/i/m", "a/e/i/n", "a/e/j/m"}, got)
+
+		// nothing is opened or visited once canceled
+		assert.Equal(t, "open(a/e/j/m)", tfs.ops[len(tfs.ops)-1])
+	})
+
+	t.Run("canceled before", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		tfs := &traceFS{fs: tree}
+		err := WalkGlobContext(ctx, tfs, "", "*/*/*/*", func(p string, f fs.File, err error) error {
+			t.Fatal("unexpected visit")
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, tfs.ops)
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrGzipUnsized is returned by Reader.GzipIndex for a gzip
+// member that does not record its compressed size.
+var ErrGzipUnsized = errors.New("gzip member does not record its size")
+
+// GzipMember is the location of a single
+// member of a multi-member gzip object.
+type GzipMember struct {
+	Offset int64 // Offset is the offset of the member within the object.
+	Size   int64 // Size is the compressed size of the member, including its header and trailer.
+}
+
+const (
+	gzipFlagExtra  = 1 << 2 // FLG.FEXTRA
+	gzipHeaderSize = 12     // fixed header, including XLEN
+	gzipPeekSize   = 64     // bytes read for each member header
+	gzipTrailer    = 8      // CRC32 and ISIZE
+)
+
+// GzipIndex returns the location of every member of a multi-member
+// gzip object by reading only the header of each member with a range
+// request, so that a single member can later be read and decompressed
+// with r.RangeReader(m.Offset, m.Size) and gzip.NewReader.
+//
+// The size of a gzip member is only known once it has been decompressed,
+// unless the member records it in its header. GzipIndex therefore expects
+// every member to carry a "BC" extra subfield holding its size, as written
+// by bgzip and other tools producing seekable (BGZF) gzip files, and fails
+// with an error matching ErrGzipUnsized otherwise. Objects that are not
+// gzip-compressed fail with an error matching gzip.ErrHeader.
+//
+// If the ETag of r is not yet known, GzipIndex first performs a HEAD
+// to determine the size of the object.
+func (r *Reader) GzipIndex(ctx context.Context) ([]GzipMember, error) {
+	if _, err := r.Stat(); err != nil {
+		return nil, err
+	}
+	var members []GzipMember
+	for off := int64(0); off < r.Size; {
+		size, err := r.gzipMemberSize(ctx, off)
+		if err != nil {
+			return nil, fmt.Errorf("s3: indexing %s at offset %d: %w", r.Path, off, err)
+		}
+		members = append(members, GzipMember{Offset: off, Size: size})
+		off += size
+	}
+	return members, nil
+}
+
+// gzipMemberSize reads the header of the gzip
+// member at off and returns the size it records.
+func (r *Reader) gzipMemberSize(ctx context.Context, off int64) (int64, error) {
+	hdr, err := r.readRange(ctx, off, min(gzipPeekSize, r.Size-off))
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case len(hdr) < gzipHeaderSize || hdr[0] != 0x1f || hdr[1] != 0x8b || hdr[2] != 8:
+		return 0, gzip.ErrHeader
+	case hdr[3]&gzipFlagExtra == 0:
+		return 0, ErrGzipUnsized
+	}
+
+	// the extra field rarely exceeds the bytes
+	// read so far, but it may be up to 64KiB long
+	end := gzipHeaderSize + int64(binary.LittleEndian.Uint16(hdr[10:]))
+	if end > r.Size-off {
+		return 0, gzip.ErrHeader
+	}
+	if end > int64(len(hdr)) {
+		if hdr, err = r.readRange(ctx, off, end); err != nil {
+			return 0, err
+		}
+	}
+
+	// look for the BGZF subfield holding the size of the member minus one
+	for extra := hdr[gzipHeaderSize:end]; len(extra) >= 4; {
+		n := 4 + int(binary.LittleEndian.Uint16(extra[2:]))
+		if n > len(extra) {
+			return 0, gzip.ErrHeader
+		}
+		if extra[0] == 'B' && extra[1] == 'C' && n == 6 {
+			size := int64(binary.LittleEndian.Uint16(extra[4:])) + 1
+			if size < end+gzipTrailer || size > r.Size-off {
+				return 0, gzip.ErrHeader
+			}
+			return size, nil
+		}
+		extra = extra[n:]
+	}
+	return 0, ErrGzipUnsized
+}
+
+// readRange reads the width bytes of the object starting at off.
+func (r *Reader) readRange(ctx context.Context, off, width int64) ([]byte, error) {
+	rd, err := r.rangeReader(ctx, off, width)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	buf := make([]byte, width)
+	if _, err := io.ReadFull(rd, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+// bgzfMember compresses data into a gzip member
+// recording its size in a BGZF "BC" subfield.
+func bgzfMember(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Extra = []byte{'B', 'C', 2, 0, 0, 0}
+	_, err := zw.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	// the subfield follows the fixed header and XLEN
+	member := buf.Bytes()
+	binary.LittleEndian.PutUint16(member[16:], uint16(len(member)-1))
+	return member
+}
+
+func TestReader_GzipIndex(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	ctx := context.Background()
+
+	chunks := [][]byte{
+		[]byte("first member"),
+		bytes.Repeat([]byte("second member "), 500),
+		[]byte("third member"),
+	}
+	var object []byte
+	var want []GzipMember
+	for _, c := range chunks {
+		m := bgzfMember(t, c)
+		want = append(want, GzipMember{Offset: int64(len(object)), Size: int64(len(m))})
+		object = append(object, m...)
+	}
+	mockServer.PutObject("data.gz", object)
+
+	t.Run("index", func(t *testing.T) {
+		r := &Reader{Key: key, Bucket: bucket, Path: "data.gz"}
+		index, err := r.GzipIndex(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, want, index)
+
+		// only the member headers are read
+		gets := mockServer.GetRequestsWithMethod("GET")
+		assert.Len(t, gets, len(chunks))
+		for _, req := range gets {
+			assert.NotEmpty(t, req.Headers["Range"])
+		}
+
+		// a single member can be decompressed on its own
+		rd, err := r.RangeReader(index[1].Offset, index[1].Size)
+		assert.NoError(t, err)
+		defer rd.Close()
+		zr, err := gzip.NewReader(rd)
+		assert.NoError(t, err)
+		zr.Multistream(false)
+		data, err := io.ReadAll(zr)
+		assert.NoError(t, err)
+		assert.Equal(t, chunks[1], data)
+	})
+
+	t.Run("unsized", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte("plain gzip"))
+		zw.Close()
+		mockServer.PutObject("plain.gz", buf.Bytes())
+
+		r := &Reader{Key: key, Bucket: bucket, Path: "plain.gz"}
+		_, err := r.GzipIndex(ctx)
+		assert.ErrorIs(t, err, ErrGzipUnsized)
+	})
+
+	t.Run("not gzip", func(t *testing.T) {
+		mockServer.PutObject("plain.txt", []byte("this is not compressed at all"))
+
+		r := &Reader{Key: key, Bucket: bucket, Path: "plain.txt"}
+		_, err := r.GzipIndex(ctx)
+		assert.ErrorIs(t, err, gzip.ErrHeader)
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"iter"
+)
+
+// linesBufferSize is the size of the buffer used by
+// Reader.Lines; longer lines are read in several steps.
+const linesBufferSize = 64 << 10
+
+// Lines streams the object a line at a time with a single ranged
+// GET, without loading it all in memory. Lines are split on "\n",
+// and the line terminator, along with a "\r" preceding it, is
+// stripped. The last line is yielded even if it is not terminated.
+//
+// The yielded slice is only valid until the next iteration, as its
+// memory is reused. If reading fails, the error is yielded once with
+// a nil line and the iteration stops; a body ending before the size
+// of the object yields an error matching io.ErrUnexpectedEOF.
+//
+// If the ETag of r is not yet known, Lines first performs a HEAD to
+// determine the size of the object.
+func (r *Reader) Lines(ctx context.Context) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		if _, err := r.Stat(); err != nil {
+			yield(nil, err)
+			return
+		}
+		if r.Size == 0 {
+			return
+		}
+		body, err := r.rangeReader(ctx, 0, r.Size)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer body.Close()
+
+		var line []byte
+		var read int64
+		br := bufio.NewReaderSize(body, linesBufferSize)
+		for {
+			chunk, err := br.ReadSlice('\n')
+			read += int64(len(chunk))
+			switch {
+			case errors.Is(err, bufio.ErrBufferFull):
+				// the line spans several buffers
+				line = append(line, chunk...)
+				continue
+			case err == io.EOF && read < r.Size:
+				yield(nil, r.shortRead(read))
+				return
+			case err != nil && err != io.EOF:
+				yield(nil, err)
+				return
+			}
+			// chunk belongs to br, so only
+			// line may be reused across lines
+			if len(line) > 0 {
+				line = append(line, chunk...)
+				chunk = line
+			}
+			if err == io.EOF && len(chunk) == 0 {
+				return
+			}
+			if !yield(trimEOL(chunk), nil) || err == io.EOF {
+				return
+			}
+			line = line[:0]
+		}
+	}
+}
+
+// trimEOL strips a trailing "\n" or "\r\n" from line.
+func trimEOL(line []byte) []byte {
+	line, _ = bytes.CutSuffix(line, []byte("\n"))
+	line, _ = bytes.CutSuffix(line, []byte("\r"))
+	return line
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader_Lines(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	ctx := context.Background()
+
+	// collect reads every line, copying it out of the
+	// reused buffer, until the first error
+	collect := func(r *Reader) (lines []string, err error) {
+		for line, err := range r.Lines(ctx) {
+			if err != nil {
+				return lines, err
+			}
+			lines = append(lines, string(line))
+		}
+		return lines, nil
+	}
+
+	t.Run("lines", func(t *testing.T) {
+		long := strings.Repeat("0123456789", 3*linesBufferSize/10)
+		want := []string{"first", "", "windows", long, "after long", long + "!", "unterminated"}
+		mockServer.PutObject("log.txt", []byte("first\n\nwindows\r\n"+long+"\nafter long\n"+long+"!\nunterminated"))
+
+		lines, err := collect(&Reader{Key: key, Bucket: bucket, Path: "log.txt"})
+		assert.NoError(t, err)
+		assert.Equal(t, want, lines)
+		assert.Len(t, mockServer.GetRequestsWithMethod("GET"), 1)
+	})
+
+	t.Run("terminated", func(t *testing.T) {
+		mockServer.PutObject("done.txt", []byte("a\nb\n"))
+		lines, err := collect(&Reader{Key: key, Bucket: bucket, Path: "done.txt"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, lines)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		mockServer.PutObject("empty.txt", nil)
+		lines, err := collect(&Reader{Key: key, Bucket: bucket, Path: "empty.txt"})
+		assert.NoError(t, err)
+		assert.Empty(t, lines)
+	})
+
+	t.Run("break", func(t *testing.T) {
+		mockServer.PutObject("many.txt", []byte("1\n2\n3\n4\n"))
+		var lines []string
+		for line, err := range (&Reader{Key: key, Bucket: bucket, Path: "many.txt"}).Lines(ctx) {
+			assert.NoError(t, err)
+			lines = append(lines, string(line))
+			if len(lines) == 2 {
+				break
+			}
+		}
+		assert.Equal(t, []string{"1", "2"}, lines)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := collect(&Reader{Key: key, Bucket: bucket, Path: "missing.txt"})
+		assert.Error(t, err)
+	})
+
+	t.Run("short body", func(t *testing.T) {
+		// a server that ends the body early
+		// after promising more bytes
+		r := &Reader{Key: key, Bucket: bucket, Path: "short.txt", ETag: "\"x\"", Size: 100}
+		r.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    http.StatusPartialContent,
+				Header:        http.Header{"Content-Length": {strconv.Itoa(100)}},
+				Body:          io.NopCloser(strings.NewReader("one\ntwo\n")),
+				ContentLength: 100,
+				Request:       req,
+			}, nil
+		})}
+		lines, err := collect(r)
+		assert.Equal(t, []string{"one", "two"}, lines)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
@@ -0,0 +1,248 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNoContents is returned when reading a file of
+// the fs.FS returned by FromListing, which only knows
+// the names, sizes and modification times of objects.
+var ErrNoContents = errors.New("s3: listing has no object contents")
+
+// FromListing returns a read-only fs.FS of the objects described
+// by one or more ListObjectsV2 (ListBucketResult) XML documents, such
+// as pages captured from a real bucket, so that code walking a bucket
+// can be tested without a server. The directory hierarchy is derived
+// from the keys, the directory markers and the common prefixes of the
+// listings, with "/" as the separator.
+//
+// Files report the size, modification time and ETag of the objects
+// (through the *ListedFile returned by Stat), but reading them fails
+// with ErrNoContents. Keys that are not valid fs.FS paths are skipped,
+// and a key that is also a prefix of other keys is shown as a directory.
+func FromListing(listings ...[]byte) (fs.FS, error) {
+	l := &listingFS{
+		files: make(map[string]*ListedFile),
+		dirs:  map[string][]fs.DirEntry{".": nil},
+	}
+	for i, doc := range listings {
+		var ret listResponse
+		if err := xml.Unmarshal(doc, &ret); err != nil {
+			return nil, fmt.Errorf("s3.FromListing: listing %d: %w", i, err)
+		}
+		if err := ret.decode(); err != nil {
+			return nil, fmt.Errorf("s3.FromListing: listing %d: %w", i, err)
+		}
+		for j := range ret.Contents {
+			f := &ret.Contents[j]
+			if key, ok := strings.CutSuffix(f.Path(), "/"); ok {
+				l.mkdir(key)
+				continue
+			}
+			if ignoreKey(f.Path(), false) || !fs.ValidPath(f.Path()) {
+				continue
+			}
+			l.files[f.Path()] = &ListedFile{
+				name:    path.Base(f.Path()),
+				size:    f.Reader.Size,
+				modTime: f.Reader.LastModified,
+				ETag:    f.ETag,
+			}
+			l.mkdir(path.Dir(f.Path()))
+		}
+		for j := range ret.CommonPrefixes {
+			l.mkdir(strings.TrimSuffix(ret.CommonPrefixes[j].Path, "/"))
+		}
+	}
+
+	// directories take precedence over files of the same name
+	for name := range l.dirs {
+		delete(l.files, name)
+	}
+	for name, f := range l.files {
+		dir := path.Dir(name)
+		l.dirs[dir] = append(l.dirs[dir], f)
+	}
+	for _, entries := range l.dirs {
+		slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+	}
+	return l, nil
+}
+
+// listingFS is the fs.FS returned by FromListing.
+type listingFS struct {
+	files map[string]*ListedFile   // files by path
+	dirs  map[string][]fs.DirEntry // sorted entries of each directory by path
+}
+
+// mkdir adds the directory name and its parents.
+func (l *listingFS) mkdir(name string) {
+	if name == "" || !fs.ValidPath(name) {
+		return
+	}
+	if _, ok := l.dirs[name]; ok {
+		return
+	}
+	l.dirs[name] = nil
+	for name != "." {
+		parent := path.Dir(name)
+		_, ok := l.dirs[parent]
+		l.dirs[parent] = append(l.dirs[parent], &listedDir{name: path.Base(name)})
+		if ok {
+			return
+		}
+		name = parent
+	}
+}
+
+// Open implements fs.FS.Open
+func (l *listingFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if f, ok := l.files[name]; ok {
+		return &listedReader{ListedFile: f}, nil
+	}
+	if entries, ok := l.dirs[name]; ok {
+		return &listedDirReader{listedDir: listedDir{name: path.Base(name)}, entries: slices.Clone(entries)}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements fs.ReadDirFS.ReadDir
+func (l *listingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, ok := l.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return slices.Clone(entries), nil
+}
+
+// Stat implements fs.StatFS.Stat
+func (l *listingFS) Stat(name string) (fs.FileInfo, error) {
+	f, err := l.Open(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: errors.Unwrap(err)}
+	}
+	return f.Stat()
+}
+
+// ListedFile describes an object of the fs.FS returned by
+// FromListing. It implements fs.FileInfo and fs.DirEntry.
+type ListedFile struct {
+	name    string
+	size    int64
+	modTime time.Time
+
+	// ETag is the ETag of the object in the listing.
+	ETag string
+}
+
+// Name implements fs.FileInfo.Name
+func (f *ListedFile) Name() string { return f.name }
+
+// Size implements fs.FileInfo.Size
+func (f *ListedFile) Size() int64 { return f.size }
+
+// Mode implements fs.FileInfo.Mode
+func (f *ListedFile) Mode() fs.FileMode { return 0644 }
+
+// ModTime implements fs.FileInfo.ModTime
+func (f *ListedFile) ModTime() time.Time { return f.modTime }
+
+// IsDir implements fs.FileInfo.IsDir
+func (f *ListedFile) IsDir() bool { return false }
+
+// Sys implements fs.FileInfo.Sys
+func (f *ListedFile) Sys() any { return nil }
+
+// Type implements fs.DirEntry.Type
+func (f *ListedFile) Type() fs.FileMode { return 0 }
+
+// Info implements fs.DirEntry.Info
+func (f *ListedFile) Info() (fs.FileInfo, error) { return f, nil }
+
+// listedReader is an open ListedFile.
+type listedReader struct {
+	*ListedFile
+}
+
+func (r *listedReader) Stat() (fs.FileInfo, error) { return r.ListedFile, nil }
+func (r *listedReader) Close() error               { return nil }
+
+func (r *listedReader) Read([]byte) (int, error) {
+	if r.size == 0 {
+		return 0, io.EOF
+	}
+	return 0, &fs.PathError{Op: "read", Path: r.name, Err: ErrNoContents}
+}
+
+// listedDir is a directory of the fs.FS returned by FromListing.
+type listedDir struct {
+	name string
+}
+
+func (d *listedDir) Name() string               { return d.name }
+func (d *listedDir) Size() int64                { return 0 }
+func (d *listedDir) Mode() fs.FileMode          { return fs.ModeDir | 0755 }
+func (d *listedDir) ModTime() time.Time         { return time.Time{} }
+func (d *listedDir) IsDir() bool                { return true }
+func (d *listedDir) Sys() any                   { return nil }
+func (d *listedDir) Type() fs.FileMode          { return fs.ModeDir }
+func (d *listedDir) Info() (fs.FileInfo, error) { return d, nil }
+
+// listedDirReader is an open listedDir.
+type listedDirReader struct {
+	listedDir
+	entries []fs.DirEntry
+}
+
+func (d *listedDirReader) Stat() (fs.FileInfo, error) { return &d.listedDir, nil }
+func (d *listedDirReader) Close() error               { return nil }
+
+func (d *listedDirReader) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile.ReadDir
+func (d *listedDirReader) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		out := d.entries
+		d.entries = nil
+		return out, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	out := d.entries[:n]
+	d.entries = d.entries[n:]
+	return out, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kelindar/s3/fsutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleListing = `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>example-bucket</Name>
+  <KeyCount>5</KeyCount>
+  <MaxKeys>1000</MaxKeys>
+  <IsTruncated>true</IsTruncated>
+  <Contents>
+    <Key>logs/2024/01/app.log</Key>
+    <LastModified>2024-01-02T03:04:05.000Z</LastModified>
+    <ETag>&quot;0a1b2c&quot;</ETag>
+    <Size>1024</Size>
+    <StorageClass>STANDARD</StorageClass>
+  </Contents>
+  <Contents>
+    <Key>logs/2024/01/db.log</Key>
+    <LastModified>2024-01-02T03:04:05.000Z</LastModified>
+    <ETag>&quot;3d4e5f&quot;</ETag>
+    <Size>2048</Size>
+  </Contents>
+  <Contents>
+    <Key>logs/2024/02/</Key>
+    <Size>0</Size>
+  </Contents>
+  <Contents>
+    <Key>readme.txt</Key>
+    <LastModified>2024-01-01T00:00:00.000Z</LastModified>
+    <ETag>&quot;abcdef&quot;</ETag>
+    <Size>0</Size>
+  </Contents>
+</ListBucketResult>`
+
+const sampleListingPage2 = `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>example-bucket</Name>
+  <EncodingType>url</EncodingType>
+  <IsTruncated>false</IsTruncated>
+  <Contents>
+    <Key>logs/2024/03/web%20server.log</Key>
+    <LastModified>2024-03-02T03:04:05.000Z</LastModified>
+    <Size>10</Size>
+  </Contents>
+  <CommonPrefixes>
+    <Prefix>archive/</Prefix>
+  </CommonPrefixes>
+</ListBucketResult>`
+
+func TestFromListing(t *testing.T) {
+	fsys, err := FromListing([]byte(sampleListing), []byte(sampleListingPage2))
+	assert.NoError(t, err)
+
+	t.Run("fstest", func(t *testing.T) {
+		// fstest reads every file, so only use empty objects
+		empty, err := FromListing([]byte(`<ListBucketResult>
+  <Contents><Key>a/b/empty.txt</Key><Size>0</Size></Contents>
+  <Contents><Key>a/c/</Key><Size>0</Size></Contents>
+  <Contents><Key>root.txt</Key><Size>0</Size></Contents>
+  <CommonPrefixes><Prefix>d/</Prefix></CommonPrefixes>
+</ListBucketResult>`))
+		assert.NoError(t, err)
+		assert.NoError(t, fstest.TestFS(empty, "a/b/empty.txt", "a/c", "root.txt", "d"))
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		var got []string
+		err := fsutil.WalkGlob(fsys, "", "logs/*/*/*.log", func(name string, f fs.File, err error) error {
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			got = append(got, name)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"logs/2024/01/app.log",
+			"logs/2024/01/db.log",
+			"logs/2024/03/web server.log",
+		}, got)
+	})
+
+	t.Run("dirs", func(t *testing.T) {
+		entries, err := fs.ReadDir(fsys, "logs/2024")
+		assert.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			assert.True(t, e.IsDir())
+			names = append(names, e.Name())
+		}
+		assert.Equal(t, []string{"01", "02", "03"}, names)
+
+		entries, err = fs.ReadDir(fsys, ".")
+		assert.NoError(t, err)
+		assert.Len(t, entries, 3)
+
+		_, err = fs.ReadDir(fsys, "missing")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		info, err := fs.Stat(fsys, "logs/2024/01/db.log")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2048), info.Size())
+		assert.Equal(t, 2024, info.ModTime().Year())
+		assert.Equal(t, `"3d4e5f"`, info.(*ListedFile).ETag)
+	})
+
+	t.Run("read", func(t *testing.T) {
+		f, err := fsys.Open("logs/2024/01/app.log")
+		assert.NoError(t, err)
+		defer f.Close()
+		_, err = io.ReadAll(f)
+		assert.ErrorIs(t, err, ErrNoContents)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := FromListing([]byte("<ListBucketResult><Contents>"))
+		assert.Error(t, err)
+	})
+}
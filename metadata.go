@@ -0,0 +1,98 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidMetadata is returned when the user metadata
+// (x-amz-meta-*) of an object would be rejected by S3.
+var ErrInvalidMetadata = errors.New("invalid user metadata")
+
+// metaPrefix is the prefix of the
+// headers carrying user metadata.
+const metaPrefix = "x-amz-meta-"
+
+// MaxMetadataSize is the maximum total size of the user metadata
+// of an object, counted by S3 as the bytes of the names (without
+// the x-amz-meta- prefix) and of the values of every entry.
+const MaxMetadataSize = 2 << 10
+
+// validMetadata checks the user metadata of an object, given
+// the names without the x-amz-meta- prefix, before it is sent,
+// as S3 otherwise rejects it with an opaque 400 response.
+//
+// Names must be valid header names, and values must be
+// printable US-ASCII, since S3 only stores other characters
+// if they are encoded as described in RFC 2047, which can be
+// done with mime.QEncoding.
+func validMetadata(meta map[string]string) error {
+	size := 0
+	for name, value := range meta {
+		if !validHeaderName(name) {
+			return fmt.Errorf("%w: invalid name %q", ErrInvalidMetadata, name)
+		}
+		for i := 0; i < len(value); i++ {
+			if c := value[i]; (c < ' ' && c != '\t') || c > '~' {
+				return fmt.Errorf("%w: value of %q has the disallowed byte %#02x at offset %d; encode it with mime.QEncoding", ErrInvalidMetadata, name, c, i)
+			}
+		}
+		size += len(name) + len(value)
+	}
+	if size > MaxMetadataSize {
+		return fmt.Errorf("%w: %d bytes exceed the limit of %d bytes", ErrInvalidMetadata, size, MaxMetadataSize)
+	}
+	return nil
+}
+
+// headerMetadata returns the user metadata
+// set by the x-amz-meta-* headers of h.
+func headerMetadata(h http.Header) map[string]string {
+	var meta map[string]string
+	for name, values := range h {
+		if len(name) <= len(metaPrefix) || !strings.EqualFold(name[:len(metaPrefix)], metaPrefix) {
+			continue
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		// multiple values are sent as a single one
+		meta[strings.ToLower(name[len(metaPrefix):])] = strings.Join(values, ",")
+	}
+	return meta
+}
+
+// validHeaderName reports whether name is a non-empty
+// token, as defined by RFC 9110, which can be sent as
+// the name of a header.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
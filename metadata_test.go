@@ -0,0 +1,98 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadata(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+	meta := func(kv ...string) UploadOptions {
+		h := make(http.Header)
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return UploadOptions{ExtraHeaders: h}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		// the limit does not count the x-amz-meta- prefix
+		value := strings.Repeat("v", MaxMetadataSize-len("name"))
+		_, err := b.Write(ctx, "meta/valid.txt", []byte("ok"), meta("X-Amz-Meta-Name", value, "Content-Language", "en"))
+		assert.NoError(t, err)
+
+		puts := mockServer.GetRequestsWithMethod("PUT")
+		assert.Equal(t, value, puts[len(puts)-1].Headers["X-Amz-Meta-Name"])
+	})
+
+	t.Run("encoded", func(t *testing.T) {
+		value := mime.QEncoding.Encode("utf-8", "café")
+		_, err := b.Write(ctx, "meta/encoded.txt", []byte("ok"), meta("X-Amz-Meta-Name", value))
+		assert.NoError(t, err)
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		before := len(mockServer.GetRequestLog())
+		value := strings.Repeat("v", MaxMetadataSize)
+		_, err := b.Write(ctx, "meta/big.txt", []byte("big"), meta("X-Amz-Meta-A", value[:1024], "X-Amz-Meta-B", value[:1024]))
+		assert.ErrorIs(t, err, ErrInvalidMetadata)
+		assert.ErrorContains(t, err, "2050 bytes")
+
+		err = b.WriteFrom(ctx, "meta/big.txt", bytes.NewReader(make([]byte, 10)), 10, meta("X-Amz-Meta-A", value))
+		assert.ErrorIs(t, err, ErrInvalidMetadata)
+		assert.Len(t, mockServer.GetRequestLog(), before)
+		assert.False(t, mockServer.ObjectExists("meta/big.txt"))
+	})
+
+	t.Run("control characters", func(t *testing.T) {
+		for _, value := range []string{"line\nbreak", "nul\x00", "bell\a", "del\x7f", "café"} {
+			_, err := b.Write(ctx, "meta/ctl.txt", []byte("ctl"), meta("X-Amz-Meta-Name", value))
+			assert.ErrorIs(t, err, ErrInvalidMetadata, "%q", value)
+		}
+		assert.False(t, mockServer.ObjectExists("meta/ctl.txt"))
+
+		_, err := b.Write(ctx, "meta/tab.txt", []byte("tab"), meta("X-Amz-Meta-Name", "a\tb"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		_, err := b.Copy(ctx, "meta/valid.txt", "meta/copy.txt", CopyOptions{
+			MetadataDirective: "REPLACE",
+			Metadata:          map[string]string{"bad name": "value"},
+		})
+		assert.ErrorIs(t, err, ErrInvalidMetadata)
+
+		_, err = b.Touch(ctx, "meta/valid.txt", map[string]string{"name": "line\r\nbreak"})
+		assert.ErrorIs(t, err, ErrInvalidMetadata)
+	})
+}
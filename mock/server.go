@@ -15,18 +15,25 @@
 package mock
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/xml"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"math/rand"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"net/url"
 	"path"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -36,9 +43,10 @@ import (
 )
 
 var (
-	mockRand       = rand.New(rand.NewSource(time.Now().UnixNano()))
-	mockRandMu     sync.Mutex
-	uploadSequence atomic.Uint64
+	mockRand        = rand.New(rand.NewSource(time.Now().UnixNano()))
+	mockRandMu      sync.Mutex
+	uploadSequence  atomic.Uint64
+	requestSequence atomic.Uint64
 )
 
 // Server provides a comprehensive mock implementation of the AWS S3 API
@@ -54,6 +62,12 @@ type Server struct {
 	requests []RequestLog
 	errors   *ErrorSimulation
 	baseURL  string
+	noRange  bool
+	noSelect bool
+	noListV2 bool          // reject ListObjectsV2 requests
+	delay    time.Duration // pause before the body of a GET
+	throttle int           // number of part uploads still to throttle
+	complete time.Duration // pause before completing a multipart upload
 }
 
 // Object represents an S3 object stored in the mock server
@@ -63,16 +77,58 @@ type Object struct {
 	LastModified time.Time
 	ContentType  string
 	Metadata     map[string]string
+	ACL          string            // canned ACL, if any
+	Grants       []byte            // access control policy set via PutObjectAcl, if any
+	Encryption   map[string]string // server-side encryption headers, if any
+	Parts        []*PartInfo       // parts of a multipart upload, if any
+	CacheControl string            // Cache-Control header set on upload, if any
+	Disposition  string            // Content-Disposition header set on upload, if any
+	Expires      string            // Expires header set on upload, if any
+	Headers      http.Header       // other standard headers set on upload, e.g. Content-Language
+	Checksum     string            // composite SHA256 checksum of a multipart upload, if any
+	Retention    string            // object lock retention mode, if any
+	RetainUntil  time.Time         // time at which the retention expires
+	LegalHold    bool              // whether the object is under a legal hold
+	StorageClass string            // storage class, if not STANDARD
+}
+
+// storageClass returns the storage class of the object
+func (o *Object) storageClass() string {
+	if o.StorageClass == "" {
+		return "STANDARD"
+	}
+	return o.StorageClass
+}
+
+// locked returns whether the object lock prevents the object from
+// being deleted. Governance retention is lifted if bypass is set.
+func (o *Object) locked(bypass bool) bool {
+	switch {
+	case o.LegalHold:
+		return true
+	case o.Retention == "" || !time.Now().Before(o.RetainUntil):
+		return false
+	case o.Retention == "GOVERNANCE" && bypass:
+		return false
+	}
+	return true
 }
 
 // Multipart tracks the state of a multipart upload
 type Multipart struct {
-	ID       string
-	Bucket   string
-	Key      string
-	Parts    map[int]*PartInfo
-	Created  time.Time
-	Metadata map[string]string
+	ID           string
+	Bucket       string
+	Key          string
+	Parts        map[int]*PartInfo
+	Created      time.Time
+	Metadata     map[string]string
+	ACL          string
+	Encryption   map[string]string
+	CacheControl string
+	Disposition  string
+	Expires      string
+	Headers      http.Header // other standard headers, e.g. Content-Language
+	Algorithm    string      // x-amz-checksum-algorithm, if any
 }
 
 // PartInfo represents a single part in a multipart upload
@@ -81,6 +137,7 @@ type PartInfo struct {
 	ETag       string
 	Size       int64
 	Content    []byte
+	Checksum   string // base64 SHA256 checksum of the part
 }
 
 // RequestLog captures details about requests made to the mock server
@@ -98,7 +155,9 @@ type ErrorSimulation struct {
 	NetworkErrors    bool
 	NotFoundErrors   bool
 	PermissionErrors bool
+	SignatureErrors  bool // respond with 403 SignatureDoesNotMatch to every request
 	InternalErrors   bool
+	SlowDownErrors   bool    // respond with 503 SlowDown to every request
 	ErrorRate        float64 // 0.0 to 1.0
 }
 
@@ -233,6 +292,83 @@ func (m *Server) DisableErrorSimulation() {
 	m.errors = &ErrorSimulation{}
 }
 
+// IgnoreRange makes the server ignore Range headers and always
+// respond with the full object, as some S3-compatible backends do
+func (m *Server) IgnoreRange(ignore bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.noRange = ignore
+}
+
+// DisableSelect makes the server reject S3 Select requests
+// as not implemented, as some S3-compatible backends do
+func (m *Server) DisableSelect(disable bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.noSelect = disable
+}
+
+// DisableListV2 makes the server reject ListObjectsV2 requests as
+// not implemented and only serve the original ListObjects (V1),
+// as some older S3-compatible backends do
+func (m *Server) DisableListV2(disable bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.noListV2 = disable
+}
+
+// ThrottleParts makes the server respond with a 503 SlowDown
+// to the next n part uploads, as S3 does under heavy load
+func (m *Server) ThrottleParts(n int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.throttle = n
+}
+
+// throttlePart reports whether the part upload r should be
+// throttled, counting it against the remaining throttled parts
+func (m *Server) throttlePart(r *http.Request) bool {
+	if r.Method != http.MethodPut || !r.URL.Query().Has("partNumber") {
+		return false
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.throttle <= 0 {
+		return false
+	}
+	m.throttle--
+	return true
+}
+
+// ThrottleBody makes the server pause for d between sending the
+// headers of a GET response and its body, as a slow link would
+func (m *Server) ThrottleBody(d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.delay = d
+}
+
+// DelayComplete makes the server wait for d before responding
+// to a CompleteMultipartUpload, as S3 does while it assembles
+// a large object from its parts
+func (m *Server) DelayComplete(d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.complete = d
+}
+
+// writeBody writes the body of a GET response, once the
+// headers have been flushed and the throttle has elapsed
+func writeBody(w http.ResponseWriter, body []byte, delay time.Duration) {
+	if delay > 0 {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		time.Sleep(delay)
+	}
+	w.Write(body)
+}
+
 // ServeHTTP handles HTTP requests to the mock S3 server
 func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Log the request
@@ -243,22 +379,34 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.writeErrorResponse(w, "InternalError", "Simulated error", http.StatusInternalServerError)
 		return
 	}
-
-	// Parse the request path
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	switch {
-	case len(pathParts) < 1:
-		m.writeErrorResponse(w, "InvalidRequest", "Invalid request path", http.StatusBadRequest)
+	if m.simulatePermissionError() {
+		m.writeErrorResponse(w, "AccessDenied", "Access Denied", http.StatusForbidden)
 		return
-	case pathParts[0] != m.bucket:
-		m.writeErrorResponse(w, "NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound)
+	}
+	if m.simulateSignatureError() {
+		m.writeErrorResponse(w, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden)
+		return
+	}
+	if m.simulateSlowDown() || m.throttlePart(r) {
+		m.writeErrorResponse(w, "SlowDown", "Please reduce your request rate.", http.StatusServiceUnavailable)
 		return
 	}
 
-	// Extract key
-	var key string
-	if len(pathParts) > 1 {
-		key = strings.Join(pathParts[1:], "/")
+	// Parse the request path; as with S3, a trailing
+	// slash is part of the key rather than stripped
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != m.bucket {
+		m.writeErrorResponse(w, "NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound)
+		return
+	}
+	// as with S3, the location of a bucket can be
+	// requested with a signature for any region
+	if region := signingRegion(r); region != "" && region != m.region && !r.URL.Query().Has("location") {
+		w.Header().Set("x-amz-bucket-region", m.region)
+		m.writeRegionError(w, "AuthorizationHeaderMalformed", fmt.Sprintf(
+			"The authorization header is malformed; the region '%s' is wrong; expecting '%s'", region, m.region),
+			http.StatusBadRequest, m.region)
+		return
 	}
 
 	// Route based on method and query parameters
@@ -266,19 +414,55 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		if key == "" {
+		if key == "" && query.Has("location") {
+			// Get bucket location
+			m.handleGetBucketLocation(w, r)
+		} else if key == "" {
 			// List objects
 			m.handleListObjects(w, r, query)
+		} else if query.Has("acl") {
+			// Get object ACL
+			m.handleGetObjectACL(w, r, key)
+		} else if query.Has("attributes") {
+			// Get object attributes
+			m.handleGetObjectAttributes(w, r, key)
+		} else if query.Has("uploadId") {
+			// List parts of a multipart upload
+			m.handleListParts(w, r, key, query)
+		} else if query.Has("retention") {
+			// Get object retention
+			m.handleGetObjectRetention(w, r, key)
+		} else if query.Has("legal-hold") {
+			// Get object legal hold
+			m.handleGetObjectLegalHold(w, r, key)
 		} else {
 			// Get object
 			m.handleGetObject(w, r, key)
 		}
 	case http.MethodHead:
-		m.handleHeadObject(w, r, key)
+		if key == "" {
+			// Head bucket
+			m.handleHeadBucket(w, r)
+		} else {
+			// Head object
+			m.handleHeadObject(w, r, key)
+		}
 	case http.MethodPut:
 		if query.Has("partNumber") && query.Has("uploadId") {
 			// Upload part
 			m.handleUploadPart(w, r, key, query)
+		} else if query.Has("acl") {
+			// Put object ACL
+			m.handlePutObjectACL(w, r, key)
+		} else if query.Has("retention") {
+			// Put object retention
+			m.handlePutObjectRetention(w, r, key)
+		} else if query.Has("legal-hold") {
+			// Put object legal hold
+			m.handlePutObjectLegalHold(w, r, key)
+		} else if r.Header.Get("x-amz-copy-source") != "" {
+			// Copy object
+			m.handleCopyObject(w, r, key)
 		} else {
 			// Put object
 			m.handlePutObject(w, r, key)
@@ -290,6 +474,9 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else if query.Has("uploadId") {
 			// Complete multipart upload
 			m.handleCompleteMultipartUpload(w, r, key, query)
+		} else if key == "" && query.Has("delete") {
+			// Delete multiple objects
+			m.handleDeleteObjects(w, r)
 		} else if query.Has("select") {
 			// S3 Select
 			m.handleS3Select(w, r, key)
@@ -354,18 +541,70 @@ func (m *Server) shouldSimulateError() bool {
 	return m.errors.NetworkErrors || m.errors.InternalErrors
 }
 
+// simulatePermissionError determines if access should be denied
+func (m *Server) simulatePermissionError() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.errors.PermissionErrors
+}
+
+// signingRegion returns the region of the credential scope
+// of a signed request, or of a presigned URL
+func signingRegion(r *http.Request) string {
+	credential := r.URL.Query().Get("X-Amz-Credential")
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		_, credential, _ = strings.Cut(auth, "Credential=")
+		credential, _, _ = strings.Cut(credential, ",")
+	}
+	// <key>/<date>/<region>/<service>/aws4_request
+	if parts := strings.Split(credential, "/"); len(parts) == 5 {
+		return parts[2]
+	}
+	return ""
+}
+
+// simulateSignatureError determines if the request signature should be rejected
+func (m *Server) simulateSignatureError() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.errors.SignatureErrors
+}
+
+// simulateSlowDown determines if the request should be throttled
+func (m *Server) simulateSlowDown() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.errors.SlowDownErrors
+}
+
 // writeErrorResponse writes an AWS-compatible error response
 func (m *Server) writeErrorResponse(w http.ResponseWriter, code, message string, statusCode int) {
+	m.writeRegionError(w, code, message, statusCode, "")
+}
+
+// writeRegionError writes an AWS-compatible error response,
+// along with the region of the bucket if it is not empty
+func (m *Server) writeRegionError(w http.ResponseWriter, code, message string, statusCode int, region string) {
+	requestID := fmt.Sprintf("%016X", requestSequence.Add(1))
+	hostID := "mock/" + requestID
 	errorResponse := struct {
-		XMLName xml.Name `xml:"Error"`
-		Code    string   `xml:"Code"`
-		Message string   `xml:"Message"`
+		XMLName   xml.Name `xml:"Error"`
+		Code      string   `xml:"Code"`
+		Message   string   `xml:"Message"`
+		Region    string   `xml:"Region,omitempty"`
+		RequestID string   `xml:"RequestId"`
+		HostID    string   `xml:"HostId"`
 	}{
-		Code:    code,
-		Message: message,
+		Code:      code,
+		Message:   message,
+		Region:    region,
+		RequestID: requestID,
+		HostID:    hostID,
 	}
 
 	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("x-amz-request-id", requestID)
+	w.Header().Set("x-amz-id-2", hostID)
 	w.WriteHeader(statusCode)
 
 	xml.NewEncoder(w).Encode(errorResponse)
@@ -449,7 +688,12 @@ func parseRange(rangeHeader string, contentLength int64) (start, end int64, err
 		}
 	}
 
-	if start < 0 || end >= contentLength || start > end {
+	// as S3 does, a range that extends past the
+	// end of the object is served up to its end
+	if end >= contentLength {
+		end = contentLength - 1
+	}
+	if start < 0 || start > end {
 		return 0, 0, fmt.Errorf("range not satisfiable")
 	}
 
@@ -465,6 +709,7 @@ func generateUploadID() string {
 func (m *Server) handleGetObject(w http.ResponseWriter, r *http.Request, key string) {
 	m.mutex.RLock()
 	obj, exists := m.objects[key]
+	noRange, delay := m.noRange, m.delay
 	m.mutex.RUnlock()
 
 	if !exists {
@@ -476,9 +721,16 @@ func (m *Server) handleGetObject(w http.ResponseWriter, r *http.Request, key str
 		return
 	}
 
+	if n := r.URL.Query().Get("partNumber"); n != "" {
+		m.writePart(w, obj, n, delay)
+		return
+	}
+
 	// Handle range requests
 	rangeHeader := r.Header.Get("Range")
-	if rangeHeader != "" {
+	if strings.Contains(rangeHeader, ",") && !noRange {
+		m.writeMultiRange(w, obj, rangeHeader)
+	} else if rangeHeader != "" && !noRange {
 		start, end, err := parseRange(rangeHeader, int64(len(obj.Content)))
 		if err != nil {
 			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
@@ -490,19 +742,89 @@ func (m *Server) handleGetObject(w http.ResponseWriter, r *http.Request, key str
 		w.Header().Set("Content-Type", obj.ContentType)
 		w.Header().Set("ETag", obj.ETag)
 		w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+		writeEncryption(w, obj.Encryption)
+		writeContentHeaders(w, obj)
 		w.WriteHeader(http.StatusPartialContent)
-		w.Write(obj.Content[start : end+1])
+		writeBody(w, obj.Content[start:end+1], delay)
 	} else {
 		// Full object
 		w.Header().Set("Content-Length", strconv.Itoa(len(obj.Content)))
 		w.Header().Set("Content-Type", obj.ContentType)
 		w.Header().Set("ETag", obj.ETag)
 		w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+		writeEncryption(w, obj.Encryption)
+		writeContentHeaders(w, obj)
 		w.WriteHeader(http.StatusOK)
-		w.Write(obj.Content)
+		writeBody(w, obj.Content, delay)
 	}
 }
 
+// writePart writes a single part of a multipart object, as
+// requested with the partNumber parameter. An object that was
+// not uploaded in parts consists of a single part.
+func (m *Server) writePart(w http.ResponseWriter, obj *Object, number string, delay time.Duration) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		m.writeErrorResponse(w, "InvalidArgument", "Part number must be an integer between 1 and 10000", http.StatusBadRequest)
+		return
+	}
+
+	start, content := int64(0), obj.Content
+	if len(obj.Parts) > 0 {
+		if n > len(obj.Parts) {
+			m.writeErrorResponse(w, "InvalidPartNumber", "The requested partnumber is not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		for _, p := range obj.Parts[:n-1] {
+			start += p.Size
+		}
+		content = obj.Parts[n-1].Content
+		w.Header().Set("x-amz-mp-parts-count", strconv.Itoa(len(obj.Parts)))
+	} else if n != 1 {
+		m.writeErrorResponse(w, "InvalidPartNumber", "The requested partnumber is not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+int64(len(content))-1, len(obj.Content)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("ETag", obj.ETag)
+	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	writeEncryption(w, obj.Encryption)
+	writeContentHeaders(w, obj)
+	w.WriteHeader(http.StatusPartialContent)
+	writeBody(w, content, delay)
+}
+
+// writeMultiRange writes a multipart/byteranges response
+// with each of the ranges of a multi-range request
+func (m *Server) writeMultiRange(w http.ResponseWriter, obj *Object, rangeHeader string) {
+	type byteRange struct{ start, end int64 }
+	var ranges []byteRange
+	for _, spec := range strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), ",") {
+		start, end, err := parseRange("bytes="+strings.TrimSpace(spec), int64(len(obj.Content)))
+		if err != nil {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		ranges = append(ranges, byteRange{start, end})
+	}
+
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.Header().Set("ETag", obj.ETag)
+	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusPartialContent)
+	for _, rg := range ranges {
+		part, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {obj.ContentType},
+			"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", rg.start, rg.end, len(obj.Content))},
+		})
+		part.Write(obj.Content[rg.start : rg.end+1])
+	}
+	mw.Close()
+}
+
 // handleHeadObject handles HEAD requests for objects
 func (m *Server) handleHeadObject(w http.ResponseWriter, r *http.Request, key string) {
 	m.mutex.RLock()
@@ -518,6 +840,11 @@ func (m *Server) handleHeadObject(w http.ResponseWriter, r *http.Request, key st
 	w.Header().Set("Content-Type", obj.ContentType)
 	w.Header().Set("ETag", obj.ETag)
 	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	if obj.Checksum != "" && r.Header.Get("x-amz-checksum-mode") == "ENABLED" {
+		w.Header().Set("x-amz-checksum-sha256", obj.Checksum)
+	}
+	writeEncryption(w, obj.Encryption)
+	writeContentHeaders(w, obj)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -529,14 +856,623 @@ func (m *Server) handlePutObject(w http.ResponseWriter, r *http.Request, key str
 		return
 	}
 
+	if alg, ok := checkDigest(r.Header, content); !ok {
+		m.writeErrorResponse(w, "BadDigest", "The "+alg+" you specified did not match the calculated checksum.", http.StatusBadRequest)
+		return
+	}
+	if strings.HasPrefix(r.Header.Get("x-amz-content-sha256"), "STREAMING-") {
+		var trailer http.Header
+		if content, trailer, err = decodeChunked(r.Header, content); err != nil {
+			m.writeErrorResponse(w, "IncompleteBody", err.Error(), http.StatusBadRequest)
+			return
+		}
+		if alg, ok := checkDigest(trailer, content); !ok {
+			m.writeErrorResponse(w, "BadDigest", "The "+alg+" you specified did not match the calculated checksum.", http.StatusBadRequest)
+			return
+		}
+		for name, values := range trailer {
+			w.Header()[name] = values
+		}
+	}
+
 	etag := m.PutObject(key, content)
+	m.setACL(key, r.Header.Get("x-amz-acl"))
+	enc := encryptionHeaders(r.Header)
+	m.setEncryption(key, enc)
+	etag = m.encryptedETag(key, etag, enc)
+	m.setContentHeaders(key, r.Header.Get("Cache-Control"), r.Header.Get("Content-Disposition"), r.Header.Get("Expires"))
+	m.setHeaders(key, storedHeaders(r.Header))
 
 	w.Header().Set("ETag", etag)
+	writeEncryption(w, enc)
+	w.WriteHeader(http.StatusOK)
+}
+
+// decodeChunked decodes the aws-chunked body of a streaming
+// upload, returning the contents and the trailing headers; the
+// signatures of the chunks and of the trailer are not checked
+func decodeChunked(h http.Header, body []byte) ([]byte, http.Header, error) {
+	r := bufio.NewReader(bytes.NewReader(body))
+	var content []byte
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading chunk header: %w", err)
+		}
+		size, _, _ := strings.Cut(strings.TrimSuffix(line, "\r\n"), ";")
+		n, err := strconv.ParseInt(size, 16, 64)
+		if err != nil || n < 0 {
+			return nil, nil, fmt.Errorf("invalid chunk size %q", size)
+		}
+		if n == 0 {
+			break
+		}
+		chunk := make([]byte, n+2)
+		if _, err := io.ReadFull(r, chunk); err != nil || string(chunk[n:]) != "\r\n" {
+			return nil, nil, fmt.Errorf("invalid chunk of %d bytes", n)
+		}
+		content = append(content, chunk[:n]...)
+	}
+
+	trailer := make(http.Header)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading trailer: %w", err)
+		}
+		if line = strings.TrimSuffix(line, "\r\n"); line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid trailer %q", line)
+		}
+		trailer.Set(name, value)
+	}
+	for _, name := range strings.Split(h.Get("x-amz-trailer"), ",") {
+		if name = strings.TrimSpace(name); name != "" && trailer.Get(name) == "" {
+			return nil, nil, fmt.Errorf("missing trailer %s", name)
+		}
+	}
+	if want := h.Get("x-amz-decoded-content-length"); want != strconv.Itoa(len(content)) {
+		return nil, nil, fmt.Errorf("decoded %d bytes, expected %s", len(content), want)
+	}
+	return content, trailer, nil
+}
+
+// checkDigest verifies the x-amz-checksum-crc32c and x-amz-checksum-sha256
+// headers of a request against its body, returning the algorithm of the
+// first checksum that does not match
+func checkDigest(h http.Header, content []byte) (string, bool) {
+	if want := h.Get("x-amz-checksum-crc32c"); want != "" {
+		crc := crc32.Checksum(content, crc32.MakeTable(crc32.Castagnoli))
+		if want != base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint32(nil, crc)) {
+			return "CRC32C", false
+		}
+	}
+	if want := h.Get("x-amz-checksum-sha256"); want != "" {
+		sum := sha256.Sum256(content)
+		if want != base64.StdEncoding.EncodeToString(sum[:]) {
+			return "SHA256", false
+		}
+	}
+	return "", true
+}
+
+// handleCopyObject handles PUT requests that copy an existing object
+func (m *Server) handleCopyObject(w http.ResponseWriter, r *http.Request, key string) {
+	source, err := url.PathUnescape(r.Header.Get("x-amz-copy-source"))
+	if err != nil || !strings.HasPrefix(source, "/") {
+		m.writeErrorResponse(w, "InvalidArgument", "Invalid copy source format", http.StatusBadRequest)
+		return
+	}
+	parts := strings.SplitN(source[1:], "/", 2)
+	switch {
+	case len(parts) != 2:
+		m.writeErrorResponse(w, "InvalidArgument", "Invalid copy source format", http.StatusBadRequest)
+		return
+	case parts[0] != m.bucket:
+		// For simplicity, we only support copying from the same bucket in the mock
+		m.writeErrorResponse(w, "NoSuchBucket", "Source bucket not found", http.StatusNotFound)
+		return
+	}
+
+	m.mutex.RLock()
+	sourceObj, exists := m.objects[parts[1]]
+	_, destExists := m.objects[key]
+	var content []byte
+	var metadata map[string]string
+	var sourceETag, contentType string
+	var cacheControl, disposition, expires string
+	var headers http.Header
+	if exists {
+		content, metadata, sourceETag = sourceObj.Content, sourceObj.Metadata, sourceObj.ETag
+		contentType = sourceObj.ContentType
+		cacheControl, disposition, expires = sourceObj.CacheControl, sourceObj.Disposition, sourceObj.Expires
+		headers = sourceObj.Headers.Clone()
+	}
+	m.mutex.RUnlock()
+
+	switch directive := r.Header.Get("x-amz-metadata-directive"); directive {
+	case "", "COPY":
+	case "REPLACE":
+		// as with S3, every header that is not
+		// sent again is dropped from the copy
+		metadata = userMetadata(r.Header)
+		contentType = r.Header.Get("Content-Type")
+		cacheControl, disposition, expires = r.Header.Get("Cache-Control"), r.Header.Get("Content-Disposition"), r.Header.Get("Expires")
+		headers = storedHeaders(r.Header)
+	default:
+		m.writeErrorResponse(w, "InvalidArgument", "Unknown metadata directive", http.StatusBadRequest)
+		return
+	}
+
+	ifMatch := r.Header.Get("x-amz-copy-source-if-match")
+	switch {
+	case !exists:
+		m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+		return
+	case parts[1] == key && r.Header.Get("x-amz-metadata-directive") != "REPLACE":
+		m.writeErrorResponse(w, "InvalidRequest", "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.", http.StatusBadRequest)
+		return
+	case ifMatch != "" && ifMatch != sourceETag:
+		m.writeErrorResponse(w, "PreconditionFailed", "Copy source if-match condition failed", http.StatusPreconditionFailed)
+		return
+	case r.Header.Get("If-None-Match") == "*" && destExists:
+		m.writeErrorResponse(w, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold", http.StatusPreconditionFailed)
+		return
+	}
+
+	etag := m.PutObjectWithMetadata(key, bytes.Clone(content), metadata)
+	m.setContentHeaders(key, cacheControl, disposition, expires)
+	m.setHeaders(key, headers)
+	m.mutex.Lock()
+	obj := m.objects[key]
+	if contentType != "" {
+		obj.ContentType = contentType
+	}
+	m.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string   `xml:"ETag"`
+		LastModified string   `xml:"LastModified"`
+	}{ETag: etag, LastModified: obj.LastModified.Format(time.RFC3339)})
+}
+
+// userMetadata returns the user metadata (x-amz-meta-*) headers of a request
+func userMetadata(h http.Header) map[string]string {
+	meta := make(map[string]string)
+	for k := range h {
+		if name, ok := strings.CutPrefix(k, "X-Amz-Meta-"); ok {
+			meta[strings.ToLower(name)] = h.Get(k)
+		}
+	}
+	return meta
+}
+
+// encryptionHeaders returns the server-side encryption headers of a request
+func encryptionHeaders(h http.Header) map[string]string {
+	var enc map[string]string
+	for name := range h {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-server-side-encryption") {
+			if enc == nil {
+				enc = make(map[string]string)
+			}
+			enc[strings.ToLower(name)] = h.Get(name)
+		}
+	}
+	return enc
+}
+
+// encryptedETag replaces the ETag of an object encrypted with SSE-KMS, DSSE-KMS or SSE-C,
+// which S3 does not derive from the MD5 digest of the contents, and returns the new ETag
+func (m *Server) encryptedETag(key, etag string, enc map[string]string) string {
+	if !strings.HasPrefix(enc["x-amz-server-side-encryption"], "aws:kms") && enc["x-amz-server-side-encryption-customer-algorithm"] == "" {
+		return etag
+	}
+	hash := md5.Sum([]byte(key + etag))
+	etag = fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:]))
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if obj, ok := m.objects[key]; ok {
+		obj.ETag = etag
+	}
+	return etag
+}
+
+// writeEncryption echoes the server-side encryption headers of an object
+func writeEncryption(w http.ResponseWriter, enc map[string]string) {
+	for name, value := range enc {
+		w.Header().Set(name, value)
+	}
+}
+
+// setEncryption sets the server-side encryption headers of an existing object
+func (m *Server) setEncryption(key string, enc map[string]string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if obj, ok := m.objects[key]; ok {
+		obj.Encryption = enc
+	}
+}
+
+// writeContentHeaders echoes the Cache-Control, Content-Disposition and Expires of an object.
+// Objects with an Expires header also report a synthetic x-amz-expiration, as if a lifecycle
+// rule expired them at that time.
+func writeContentHeaders(w http.ResponseWriter, obj *Object) {
+	if obj.CacheControl != "" {
+		w.Header().Set("Cache-Control", obj.CacheControl)
+	}
+	if obj.Disposition != "" {
+		w.Header().Set("Content-Disposition", obj.Disposition)
+	}
+	if obj.Expires != "" {
+		w.Header().Set("Expires", obj.Expires)
+		w.Header().Set("x-amz-expiration", fmt.Sprintf(`expiry-date="%s", rule-id="%s"`, obj.Expires, ExpirationRule))
+	}
+	if obj.StorageClass != "" {
+		w.Header().Set("x-amz-storage-class", obj.StorageClass)
+	}
+	for name, values := range obj.Headers {
+		w.Header()[name] = values
+	}
+}
+
+// storedHeaders returns the standard headers of an upload
+// request, without a dedicated field, that are stored with
+// the object and returned on GET and HEAD
+func storedHeaders(h http.Header) http.Header {
+	out := make(http.Header)
+	for _, name := range []string{"Content-Encoding", "Content-Language", "X-Amz-Website-Redirect-Location"} {
+		if v := h.Values(name); len(v) > 0 {
+			out[name] = v
+		}
+	}
+	// as with S3, the aws-chunked encoding of a
+	// streaming upload is not stored with the object
+	if enc := out.Get("Content-Encoding"); enc != "" {
+		codings := slices.DeleteFunc(strings.Split(enc, ","), func(c string) bool {
+			return strings.TrimSpace(c) == "aws-chunked"
+		})
+		if len(codings) == 0 {
+			out.Del("Content-Encoding")
+		} else {
+			out.Set("Content-Encoding", strings.Join(codings, ","))
+		}
+	}
+	return out
+}
+
+// setHeaders sets the other standard headers of an existing object
+func (m *Server) setHeaders(key string, h http.Header) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if obj, ok := m.objects[key]; ok {
+		obj.Headers = h
+	}
+}
+
+// ExpirationRule is the lifecycle rule ID reported in the
+// x-amz-expiration header of objects with an Expires header.
+const ExpirationRule = "mock-expiration"
+
+// setContentHeaders sets the Cache-Control, Content-Disposition and Expires of an existing object
+func (m *Server) setContentHeaders(key, cacheControl, disposition, expires string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if obj, ok := m.objects[key]; ok {
+		obj.CacheControl = cacheControl
+		obj.Disposition = disposition
+		obj.Expires = expires
+	}
+}
+
+// setACL sets the canned ACL of an existing object
+func (m *Server) setACL(key, acl string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if obj, ok := m.objects[key]; ok {
+		obj.ACL = acl
+		obj.Grants = nil
+	}
+}
+
+// AccessControlPolicy represents the XML response for GetObjectAcl
+type AccessControlPolicy struct {
+	XMLName xml.Name `xml:"AccessControlPolicy"`
+	Owner   Grantee  `xml:"Owner"`
+	Grants  []Grant  `xml:"AccessControlList>Grant"`
+}
+
+// Grant represents a single grant in an access control policy
+type Grant struct {
+	Grantee    Grantee `xml:"Grantee"`
+	Permission string  `xml:"Permission"`
+}
+
+// Grantee represents the recipient of a grant
+type Grantee struct {
+	Type string `xml:"type,attr,omitempty"`
+	ID   string `xml:"ID,omitempty"`
+	URI  string `xml:"URI,omitempty"`
+}
+
+// cannedPolicy expands a canned ACL into its grants
+func cannedPolicy(acl string) AccessControlPolicy {
+	owner := Grantee{Type: "CanonicalUser", ID: "mock-owner"}
+	allUsers := Grantee{Type: "Group", URI: "http://acs.amazonaws.com/groups/global/AllUsers"}
+	authUsers := Grantee{Type: "Group", URI: "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"}
+
+	policy := AccessControlPolicy{
+		Owner:  Grantee{ID: owner.ID},
+		Grants: []Grant{{Grantee: owner, Permission: "FULL_CONTROL"}},
+	}
+	switch acl {
+	case "public-read":
+		policy.Grants = append(policy.Grants, Grant{Grantee: allUsers, Permission: "READ"})
+	case "public-read-write":
+		policy.Grants = append(policy.Grants,
+			Grant{Grantee: allUsers, Permission: "READ"},
+			Grant{Grantee: allUsers, Permission: "WRITE"})
+	case "authenticated-read":
+		policy.Grants = append(policy.Grants, Grant{Grantee: authUsers, Permission: "READ"})
+	}
+	return policy
+}
+
+// handleGetObjectACL handles GET requests for object ACLs
+func (m *Server) handleGetObjectACL(w http.ResponseWriter, r *http.Request, key string) {
+	m.mutex.RLock()
+	obj, exists := m.objects[key]
+	var acl string
+	var grants []byte
+	if exists {
+		acl, grants = obj.ACL, obj.Grants
+	}
+	m.mutex.RUnlock()
+
+	if !exists {
+		m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if grants != nil {
+		w.Write(grants)
+		return
+	}
+	xml.NewEncoder(w).Encode(cannedPolicy(acl))
+}
+
+// handleGetObjectAttributes handles GET requests for object attributes
+func (m *Server) handleGetObjectAttributes(w http.ResponseWriter, r *http.Request, key string) {
+	type part struct {
+		PartNumber int   `xml:"PartNumber"`
+		Size       int64 `xml:"Size"`
+	}
+	type objectParts struct {
+		PartsCount int    `xml:"PartsCount"`
+		Parts      []part `xml:"Part"`
+	}
+	response := struct {
+		XMLName      xml.Name     `xml:"GetObjectAttributesResponse"`
+		ETag         string       `xml:"ETag,omitempty"`
+		ObjectParts  *objectParts `xml:"ObjectParts,omitempty"`
+		StorageClass string       `xml:"StorageClass,omitempty"`
+		ObjectSize   int64        `xml:"ObjectSize,omitempty"`
+	}{}
+
+	m.mutex.RLock()
+	obj, exists := m.objects[key]
+	if exists {
+		for _, attr := range strings.Split(r.Header.Get("x-amz-object-attributes"), ",") {
+			switch strings.TrimSpace(attr) {
+			case "ETag":
+				// S3 returns the ETag of the object without quotes here
+				response.ETag = strings.Trim(obj.ETag, `"`)
+			case "StorageClass":
+				response.StorageClass = obj.storageClass()
+			case "ObjectSize":
+				response.ObjectSize = int64(len(obj.Content))
+			case "ObjectParts":
+				if len(obj.Parts) > 0 {
+					response.ObjectParts = &objectParts{PartsCount: len(obj.Parts)}
+					for _, p := range obj.Parts {
+						response.ObjectParts.Parts = append(response.ObjectParts.Parts, part{PartNumber: p.PartNumber, Size: p.Size})
+					}
+				}
+			}
+		}
+	}
+	m.mutex.RUnlock()
+
+	if !exists {
+		m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(response)
+}
+
+// handlePutObjectACL handles PUT requests for object ACLs
+func (m *Server) handlePutObjectACL(w http.ResponseWriter, r *http.Request, key string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		m.writeErrorResponse(w, "InvalidRequest", "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > 0 {
+		var policy AccessControlPolicy
+		if err := xml.Unmarshal(body, &policy); err != nil {
+			m.writeErrorResponse(w, "MalformedACLError", "The XML you provided was not well-formed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	m.mutex.Lock()
+	obj, exists := m.objects[key]
+	if exists {
+		obj.ACL = r.Header.Get("x-amz-acl")
+		obj.Grants = nil
+		if len(body) > 0 {
+			obj.Grants = body
+		}
+	}
+	m.mutex.Unlock()
+
+	if !exists {
+		m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// objectRetention is the XML body of object retention requests
+type objectRetention struct {
+	XMLName         xml.Name  `xml:"Retention"`
+	Mode            string    `xml:"Mode"`
+	RetainUntilDate time.Time `xml:"RetainUntilDate"`
+}
+
+// objectLegalHold is the XML body of object legal hold requests
+type objectLegalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Status  string   `xml:"Status"`
+}
+
+// handleGetObjectRetention handles GET requests for object retention
+func (m *Server) handleGetObjectRetention(w http.ResponseWriter, r *http.Request, key string) {
+	m.mutex.RLock()
+	obj, exists := m.objects[key]
+	var response objectRetention
+	if exists {
+		response.Mode, response.RetainUntilDate = obj.Retention, obj.RetainUntil
+	}
+	m.mutex.RUnlock()
+
+	switch {
+	case !exists:
+		m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+		return
+	case response.Mode == "":
+		m.writeErrorResponse(w, "NoSuchObjectLockConfiguration", "The specified object does not have a ObjectLock configuration", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(response)
+}
+
+// handlePutObjectRetention handles PUT requests for object retention
+func (m *Server) handlePutObjectRetention(w http.ResponseWriter, r *http.Request, key string) {
+	var request objectRetention
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		m.writeErrorResponse(w, "MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest)
+		return
+	}
+	if request.Mode != "GOVERNANCE" && request.Mode != "COMPLIANCE" {
+		m.writeErrorResponse(w, "MalformedXML", "The retention mode is not valid", http.StatusBadRequest)
+		return
+	}
+
+	m.mutex.Lock()
+	obj, exists := m.objects[key]
+	denied := false
+	if exists {
+		// an active retention may only be shortened or weakened
+		// when governance mode is bypassed
+		bypass := r.Header.Get("x-amz-bypass-governance-retention") == "true"
+		shorter := request.RetainUntilDate.Before(obj.RetainUntil) || request.Mode != obj.Retention
+		denied = shorter && obj.Retention != "" && time.Now().Before(obj.RetainUntil) &&
+			(obj.Retention == "COMPLIANCE" || !bypass)
+		if !denied {
+			obj.Retention, obj.RetainUntil = request.Mode, request.RetainUntilDate
+		}
+	}
+	m.mutex.Unlock()
+
+	switch {
+	case !exists:
+		m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+	case denied:
+		m.writeErrorResponse(w, "AccessDenied", "Access Denied", http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handleGetObjectLegalHold handles GET requests for object legal holds
+func (m *Server) handleGetObjectLegalHold(w http.ResponseWriter, r *http.Request, key string) {
+	m.mutex.RLock()
+	obj, exists := m.objects[key]
+	response := objectLegalHold{Status: "OFF"}
+	if exists && obj.LegalHold {
+		response.Status = "ON"
+	}
+	m.mutex.RUnlock()
+
+	if !exists {
+		m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(response)
+}
+
+// handlePutObjectLegalHold handles PUT requests for object legal holds
+func (m *Server) handlePutObjectLegalHold(w http.ResponseWriter, r *http.Request, key string) {
+	var request objectLegalHold
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil || (request.Status != "ON" && request.Status != "OFF") {
+		m.writeErrorResponse(w, "MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest)
+		return
+	}
+
+	m.mutex.Lock()
+	obj, exists := m.objects[key]
+	if exists {
+		obj.LegalHold = request.Status == "ON"
+	}
+	m.mutex.Unlock()
+
+	if !exists {
+		m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
 // handleDeleteObject handles DELETE requests for objects
 func (m *Server) handleDeleteObject(w http.ResponseWriter, r *http.Request, key string) {
+	bypass := r.Header.Get("x-amz-bypass-governance-retention") == "true"
+	m.mutex.RLock()
+	obj, exists := m.objects[key]
+	locked := exists && obj.locked(bypass)
+	m.mutex.RUnlock()
+	if locked {
+		m.writeErrorResponse(w, "AccessDenied", "Access Denied because object protected by object lock", http.StatusForbidden)
+		return
+	}
+
+	if match := r.Header.Get("If-Match"); match != "" {
+		obj, exists := m.GetObject(key)
+		switch {
+		case !exists:
+			m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+			return
+		case obj.ETag != match:
+			m.writeErrorResponse(w, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold", http.StatusPreconditionFailed)
+			return
+		}
+	}
+
 	deleted := m.DeleteObject(key)
 	if deleted {
 		w.WriteHeader(http.StatusNoContent)
@@ -556,6 +1492,9 @@ type ListObjectsV2Response struct {
 	Contents              []ObjectInfo   `xml:"Contents"`
 	CommonPrefixes        []CommonPrefix `xml:"CommonPrefixes"`
 	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Marker                string         `xml:"Marker,omitempty"`     // V1 only
+	NextMarker            string         `xml:"NextMarker,omitempty"` // V1 only
+	EncodingType          string         `xml:"EncodingType,omitempty"`
 }
 
 // ObjectInfo represents an object in the list response
@@ -564,30 +1503,122 @@ type ObjectInfo struct {
 	LastModified time.Time `xml:"LastModified"`
 	ETag         string    `xml:"ETag"`
 	Size         int64     `xml:"Size"`
+	Owner        *Owner    `xml:"Owner,omitempty"`
+	StorageClass string    `xml:"StorageClass"`
+}
+
+// Owner represents the owner of an object in the list response
+type Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
 }
 
+// listOwner is the owner of every object, listed with fetch-owner=true
+var listOwner = Owner{ID: "mock-owner", DisplayName: "mock"}
+
 // CommonPrefix represents a common prefix in the list response
 type CommonPrefix struct {
 	Prefix string `xml:"Prefix"`
 }
 
-// handleListObjects handles GET requests for listing objects
+// handleHeadBucket handles HEAD requests for the bucket
+func (m *Server) handleHeadBucket(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("x-amz-bucket-region", m.region)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleDeleteObjects handles POST requests for multi-object deletes
+func (m *Server) handleDeleteObjects(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		m.writeErrorResponse(w, "MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest)
+		return
+	}
+
+	type deleted struct {
+		Key string `xml:"Key"`
+	}
+	type deleteError struct {
+		Key     string `xml:"Key"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	result := struct {
+		XMLName xml.Name      `xml:"DeleteResult"`
+		Deleted []deleted     `xml:"Deleted"`
+		Errors  []deleteError `xml:"Error"`
+	}{}
+
+	bypass := r.Header.Get("x-amz-bypass-governance-retention") == "true"
+	m.mutex.Lock()
+	for _, obj := range request.Objects {
+		if existing, ok := m.objects[obj.Key]; ok && existing.locked(bypass) {
+			result.Errors = append(result.Errors, deleteError{Key: obj.Key, Code: "AccessDenied", Message: "Access Denied because object protected by object lock"})
+			continue
+		}
+		delete(m.objects, obj.Key)
+		if !request.Quiet {
+			result.Deleted = append(result.Deleted, deleted{Key: obj.Key})
+		}
+	}
+	m.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(result)
+}
+
+// handleGetBucketLocation handles GET requests for the bucket location.
+// As with S3, buckets in us-east-1 have an empty location constraint.
+func (m *Server) handleGetBucketLocation(w http.ResponseWriter, r *http.Request) {
+	constraint := m.region
+	if constraint == "us-east-1" {
+		constraint = ""
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+		Region  string   `xml:",chardata"`
+	}{Region: constraint})
+}
+
+// handleListObjects handles GET requests for listing objects, with
+// ListObjectsV2 if list-type=2 is set and ListObjects (V1) otherwise
 func (m *Server) handleListObjects(w http.ResponseWriter, r *http.Request, query url.Values) {
 	prefix := query.Get("prefix")
 	delimiter := query.Get("delimiter")
 	maxKeysStr := query.Get("max-keys")
 	continuationToken := query.Get("continuation-token")
 	startAfter := query.Get("start-after")
+	fetchOwner := query.Get("fetch-owner") == "true"
+	v2 := query.Get("list-type") == "2"
+	if !v2 {
+		// V1 pages with a marker and always returns the owner
+		continuationToken, startAfter = "", query.Get("marker")
+		fetchOwner = true
+	}
 
 	maxKeys := 1000 // Default
 	if maxKeysStr != "" {
 		if parsed, err := strconv.Atoi(maxKeysStr); err == nil && parsed > 0 {
-			maxKeys = parsed
+			// S3 silently clamps max-keys to 1000
+			maxKeys = min(parsed, 1000)
 		}
 	}
 
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
+	if v2 && m.noListV2 {
+		m.writeErrorResponse(w, "NotImplemented", "A header you provided implies functionality that is not implemented", http.StatusNotImplemented)
+		return
+	}
 
 	var allKeys []string
 	for key := range m.objects {
@@ -607,11 +1638,9 @@ func (m *Server) handleListObjects(w http.ResponseWriter, r *http.Request, query
 	}
 
 	if startKey != "" {
-		for i, key := range allKeys {
-			if key > startKey {
-				startIndex = i
-				break
-			}
+		startIndex = sort.SearchStrings(allKeys, startKey)
+		if startIndex < len(allKeys) && allKeys[startIndex] == startKey {
+			startIndex++
 		}
 	}
 
@@ -635,7 +1664,7 @@ func (m *Server) handleListObjects(w http.ResponseWriter, r *http.Request, query
 			}
 
 			if delimiterIndex := strings.Index(relativePath, delimiter); delimiterIndex != -1 {
-				commonPrefix := prefix + relativePath[:delimiterIndex+1]
+				commonPrefix := prefix + relativePath[:delimiterIndex+len(delimiter)]
 				if !prefixSet[commonPrefix] {
 					commonPrefixes = append(commonPrefixes, CommonPrefix{Prefix: commonPrefix})
 					prefixSet[commonPrefix] = true
@@ -646,12 +1675,18 @@ func (m *Server) handleListObjects(w http.ResponseWriter, r *http.Request, query
 		}
 
 		obj := m.objects[key]
-		contents = append(contents, ObjectInfo{
+		info := ObjectInfo{
 			Key:          key,
 			LastModified: obj.LastModified,
 			ETag:         obj.ETag,
 			Size:         int64(len(obj.Content)),
-		})
+			StorageClass: obj.storageClass(),
+		}
+		if fetchOwner {
+			owner := listOwner
+			info.Owner = &owner
+		}
+		contents = append(contents, info)
 		count++
 	}
 
@@ -674,12 +1709,42 @@ func (m *Server) handleListObjects(w http.ResponseWriter, r *http.Request, query
 		CommonPrefixes:        commonPrefixes,
 		NextContinuationToken: nextToken,
 	}
+	if !v2 {
+		// S3 only returns a NextMarker with a delimiter
+		response.NextContinuationToken = ""
+		response.Marker = startAfter
+		if delimiter != "" {
+			response.NextMarker = nextToken
+		}
+	}
+
+	// with encoding-type=url, keys and prefixes are
+	// returned URL-encoded, as S3 does
+	if query.Get("encoding-type") == "url" {
+		response.EncodingType = "url"
+		response.Prefix = encodeKey(response.Prefix)
+		response.Delimiter = encodeKey(response.Delimiter)
+		response.Marker = encodeKey(response.Marker)
+		response.NextMarker = encodeKey(response.NextMarker)
+		for i := range response.Contents {
+			response.Contents[i].Key = encodeKey(response.Contents[i].Key)
+		}
+		for i := range response.CommonPrefixes {
+			response.CommonPrefixes[i].Prefix = encodeKey(response.CommonPrefixes[i].Prefix)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	xml.NewEncoder(w).Encode(response)
 }
 
+// encodeKey URL-encodes a key the way S3 does for a listing
+// requested with encoding-type=url, leaving slashes intact.
+func encodeKey(key string) string {
+	return strings.ReplaceAll(url.QueryEscape(key), "%2F", "/")
+}
+
 // InitiateMultipartUploadResponse represents the XML response for initiating multipart upload
 type InitiateMultipartUploadResponse struct {
 	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
@@ -688,11 +1753,13 @@ type InitiateMultipartUploadResponse struct {
 	UploadId string   `xml:"UploadId"`
 }
 
-// handleInitiateMultipartUpload handles POST requests to initiate multipart uploads
-func (m *Server) handleInitiateMultipartUpload(w http.ResponseWriter, r *http.Request, key string) {
+// CreateMultipartUpload initiates a multipart upload of the given key
+// directly on the mock server and returns its upload ID
+func (m *Server) CreateMultipartUpload(key string) string {
 	uploadID := generateUploadID()
 
 	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	m.uploads[uploadID] = &Multipart{
 		ID:       uploadID,
 		Bucket:   m.bucket,
@@ -701,6 +1768,105 @@ func (m *Server) handleInitiateMultipartUpload(w http.ResponseWriter, r *http.Re
 		Created:  time.Now().UTC(),
 		Metadata: make(map[string]string),
 	}
+	return uploadID
+}
+
+// ListPartsResponse represents the XML response for listing the parts of a multipart upload
+type ListPartsResponse struct {
+	XMLName              xml.Name       `xml:"ListPartsResult"`
+	Bucket               string         `xml:"Bucket"`
+	Key                  string         `xml:"Key"`
+	UploadId             string         `xml:"UploadId"`
+	PartNumberMarker     int            `xml:"PartNumberMarker"`
+	NextPartNumberMarker int            `xml:"NextPartNumberMarker"`
+	MaxParts             int            `xml:"MaxParts"`
+	IsTruncated          bool           `xml:"IsTruncated"`
+	Parts                []ListPartInfo `xml:"Part"`
+}
+
+// ListPartInfo represents a part in the list parts response
+type ListPartInfo struct {
+	PartNumber     int    `xml:"PartNumber"`
+	ETag           string `xml:"ETag"`
+	Size           int64  `xml:"Size"`
+	ChecksumSHA256 string `xml:"ChecksumSHA256,omitempty"`
+}
+
+// handleListParts handles GET requests for the parts of a multipart upload
+func (m *Server) handleListParts(w http.ResponseWriter, r *http.Request, key string, query url.Values) {
+	uploadID := query.Get("uploadId")
+	marker, _ := strconv.Atoi(query.Get("part-number-marker"))
+	maxParts := 1000
+	if parsed, err := strconv.Atoi(query.Get("max-parts")); err == nil && parsed > 0 {
+		maxParts = min(parsed, 1000)
+	}
+
+	m.mutex.RLock()
+	upload, exists := m.uploads[uploadID]
+	response := ListPartsResponse{
+		Bucket:           m.bucket,
+		Key:              key,
+		UploadId:         uploadID,
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+	}
+	if exists && upload.Key == key {
+		var numbers []int
+		for num := range upload.Parts {
+			if num > marker {
+				numbers = append(numbers, num)
+			}
+		}
+		sort.Ints(numbers)
+		if len(numbers) > maxParts {
+			numbers = numbers[:maxParts]
+			response.IsTruncated = true
+		}
+		for _, num := range numbers {
+			part := upload.Parts[num]
+			response.Parts = append(response.Parts, ListPartInfo{
+				PartNumber:     part.PartNumber,
+				ETag:           part.ETag,
+				Size:           part.Size,
+				ChecksumSHA256: part.Checksum,
+			})
+			response.NextPartNumberMarker = num
+		}
+	}
+	m.mutex.RUnlock()
+
+	if !exists || upload.Key != key {
+		m.writeErrorResponse(w, "NoSuchUpload", "The specified upload does not exist", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(response)
+}
+
+// handleInitiateMultipartUpload handles POST requests to initiate multipart uploads
+func (m *Server) handleInitiateMultipartUpload(w http.ResponseWriter, r *http.Request, key string) {
+	uploadID := generateUploadID()
+
+	m.mutex.Lock()
+	m.uploads[uploadID] = &Multipart{
+		ID:         uploadID,
+		Bucket:     m.bucket,
+		Key:        key,
+		Parts:      make(map[int]*PartInfo),
+		Created:    time.Now().UTC(),
+		Metadata:   make(map[string]string),
+		ACL:        r.Header.Get("x-amz-acl"),
+		Encryption: encryptionHeaders(r.Header),
+
+		CacheControl: r.Header.Get("Cache-Control"),
+		Disposition:  r.Header.Get("Content-Disposition"),
+		Headers:      storedHeaders(r.Header),
+		Expires:      r.Header.Get("Expires"),
+		Algorithm:    r.Header.Get("x-amz-checksum-algorithm"),
+	}
+	upload := m.uploads[uploadID]
 	m.mutex.Unlock()
 
 	response := InitiateMultipartUploadResponse{
@@ -710,6 +1876,7 @@ func (m *Server) handleInitiateMultipartUpload(w http.ResponseWriter, r *http.Re
 	}
 
 	w.Header().Set("Content-Type", "application/xml")
+	writeEncryption(w, upload.Encryption)
 	w.WriteHeader(http.StatusOK)
 	xml.NewEncoder(w).Encode(response)
 }
@@ -747,8 +1914,13 @@ func (m *Server) handleUploadPart(w http.ResponseWriter, r *http.Request, key st
 		m.writeErrorResponse(w, "InvalidRequest", "Failed to read request body", http.StatusBadRequest)
 		return
 	}
+	if alg, ok := checkDigest(r.Header, content); !ok {
+		m.writeErrorResponse(w, "BadDigest", "The "+alg+" you specified did not match the calculated checksum.", http.StatusBadRequest)
+		return
+	}
 
 	etag := generateETag(content)
+	sum := sha256.Sum256(content)
 
 	m.mutex.Lock()
 	upload.Parts[partNumber] = &PartInfo{
@@ -756,6 +1928,7 @@ func (m *Server) handleUploadPart(w http.ResponseWriter, r *http.Request, key st
 		ETag:       etag,
 		Size:       int64(len(content)),
 		Content:    content,
+		Checksum:   base64.StdEncoding.EncodeToString(sum[:]),
 	}
 	m.mutex.Unlock()
 
@@ -838,6 +2011,7 @@ func (m *Server) handleCopyPart(w http.ResponseWriter, r *http.Request, upload *
 	}
 
 	etag := generateETag(content)
+	sum := sha256.Sum256(content)
 
 	m.mutex.Lock()
 	upload.Parts[partNumber] = &PartInfo{
@@ -845,6 +2019,7 @@ func (m *Server) handleCopyPart(w http.ResponseWriter, r *http.Request, upload *
 		ETag:       etag,
 		Size:       int64(len(content)),
 		Content:    content,
+		Checksum:   base64.StdEncoding.EncodeToString(sum[:]),
 	}
 	m.mutex.Unlock()
 
@@ -883,12 +2058,20 @@ func (m *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 
 	m.mutex.RLock()
 	upload, exists := m.uploads[uploadID]
+	delay := m.complete
 	m.mutex.RUnlock()
 
 	if !exists {
 		m.writeErrorResponse(w, "NoSuchUpload", "The specified upload does not exist", http.StatusNotFound)
 		return
 	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
 
 	var request CompleteMultipartUploadRequest
 	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -898,6 +2081,7 @@ func (m *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 
 	// Validate and assemble parts
 	var finalContent []byte
+	var finalParts []*PartInfo
 	var partNumbers []int
 	for _, part := range request.Parts {
 		partNumbers = append(partNumbers, part.PartNumber)
@@ -911,13 +2095,24 @@ func (m *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 			return
 		}
 		finalContent = append(finalContent, partInfo.Content...)
+		finalParts = append(finalParts, partInfo)
 	}
 
 	// Create the final object
 	finalETag := m.PutObject(key, finalContent)
+	m.setACL(key, upload.ACL)
+	m.setEncryption(key, upload.Encryption)
+	m.setContentHeaders(key, upload.CacheControl, upload.Disposition, upload.Expires)
+	m.setHeaders(key, upload.Headers)
 
 	// Clean up the upload
 	m.mutex.Lock()
+	if obj, ok := m.objects[key]; ok {
+		obj.Parts = finalParts
+		if upload.Algorithm == "SHA256" {
+			obj.Checksum = compositeChecksum(finalParts)
+		}
+	}
 	delete(m.uploads, uploadID)
 	m.mutex.Unlock()
 
@@ -929,10 +2124,23 @@ func (m *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 	}
 
 	w.Header().Set("Content-Type", "application/xml")
+	writeEncryption(w, upload.Encryption)
 	w.WriteHeader(http.StatusOK)
 	xml.NewEncoder(w).Encode(response)
 }
 
+// compositeChecksum computes the composite SHA256 checksum of a
+// multipart object, which is the checksum of the concatenated
+// part checksums followed by the number of parts.
+func compositeChecksum(parts []*PartInfo) string {
+	h := sha256.New()
+	for _, p := range parts {
+		sum, _ := base64.StdEncoding.DecodeString(p.Checksum)
+		h.Write(sum)
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)) + "-" + strconv.Itoa(len(parts))
+}
+
 // handleAbortMultipartUpload handles DELETE requests to abort multipart uploads
 func (m *Server) handleAbortMultipartUpload(w http.ResponseWriter, r *http.Request, key string, query url.Values) {
 	uploadID := query.Get("uploadId")
@@ -955,9 +2163,14 @@ func (m *Server) handleAbortMultipartUpload(w http.ResponseWriter, r *http.Reque
 func (m *Server) handleS3Select(w http.ResponseWriter, r *http.Request, key string) {
 	m.mutex.RLock()
 	_, exists := m.objects[key]
+	noSelect := m.noSelect
 	m.mutex.RUnlock()
 
-	if !exists {
+	switch {
+	case noSelect:
+		m.writeErrorResponse(w, "NotImplemented", "A header you provided implies functionality that is not implemented", http.StatusNotImplemented)
+		return
+	case !exists:
 		m.writeErrorResponse(w, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
 		return
 	}
@@ -1075,6 +2288,20 @@ func (m *Server) ListMultipartUploads() map[string]*Multipart {
 	return uploads
 }
 
+// SetStorageClass sets the storage class of an existing object
+func (m *Server) SetStorageClass(key, class string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	obj, exists := m.objects[key]
+	if !exists {
+		return false
+	}
+
+	obj.StorageClass = class
+	return true
+}
+
 // SetObjectMetadata sets metadata for an existing object
 func (m *Server) SetObjectMetadata(key string, metadata map[string]string) bool {
 	m.mutex.Lock()
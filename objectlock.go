@@ -0,0 +1,185 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"time"
+)
+
+// Object lock retention modes.
+const (
+	RetentionGovernance = "GOVERNANCE" // may be bypassed with DeleteBypassGovernance
+	RetentionCompliance = "COMPLIANCE" // may not be bypassed by anyone
+)
+
+// Retention is the object lock retention of an object.
+type Retention struct {
+	XMLName xml.Name  `xml:"Retention"`
+	Mode    string    `xml:"Mode,omitempty"`  // RetentionGovernance or RetentionCompliance, empty if not set
+	Until   time.Time `xml:"RetainUntilDate"` // Until is the time at which the retention expires
+}
+
+// Active returns whether the retention
+// prevents the object from being deleted.
+func (r *Retention) Active() bool {
+	return r.Mode != "" && time.Now().Before(r.Until)
+}
+
+// legalHold is the XML body of a legal hold request.
+type legalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Status  string   `xml:"Status"` // ON or OFF
+}
+
+// lockRequest sends a request to the object lock
+// sub-resource of the object at key, which is one of
+// "retention" or "legal-hold", with the given body.
+func (b *Bucket) lockRequest(ctx context.Context, method, key, resource string, body any) (*http.Response, error) {
+	key = path.Clean(key)
+	if !fs.ValidPath(key) || key == "." {
+		return nil, badpath("s3 "+resource, key)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, uri(b.key, b.bkt, key)+"?"+resource+"=", nil)
+	if err != nil {
+		return nil, err
+	}
+	setUserAgent(req, b.UserAgent)
+	var payload []byte
+	if body != nil {
+		payload, err = xml.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		sum := md5.Sum(payload)
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	b.key.SignV4(req, payload)
+	return flakyDo(b.client(), b.Limiter, b.Logger, b.stats, req)
+}
+
+// lockError converts an unsuccessful object lock response
+// into an error, or returns nil if the object simply has
+// no object lock configuration of the requested kind.
+func lockError(op, key string, res *http.Response) error {
+	if res.StatusCode != http.StatusNotFound {
+		return subresourceError(op, key, res)
+	}
+	if responseError("s3 "+op, res).Code == "NoSuchObjectLockConfiguration" {
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: key, Err: fs.ErrNotExist}
+}
+
+// PutRetention sets the object lock retention of the object at key,
+// which is protected from deletion until the given time. The mode is
+// either RetentionGovernance or RetentionCompliance. The bucket must
+// have been created with object lock enabled.
+func (b *Bucket) PutRetention(ctx context.Context, key string, mode string, until time.Time) error {
+	if mode != RetentionGovernance && mode != RetentionCompliance {
+		return fmt.Errorf("s3: invalid retention mode %q", mode)
+	}
+	res, err := b.lockRequest(ctx, http.MethodPut, key, "retention", &Retention{
+		XMLName: xml.Name{Space: "http://s3.amazonaws.com/doc/2006-03-01/", Local: "Retention"},
+		Mode:    mode,
+		Until:   until.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return subresourceError("putretention", key, res)
+	}
+	return nil
+}
+
+// GetRetention returns the object lock retention of the object
+// at key. If the object has no retention, the returned Retention
+// has an empty Mode.
+func (b *Bucket) GetRetention(ctx context.Context, key string) (*Retention, error) {
+	res, err := b.lockRequest(ctx, http.MethodGet, key, "retention", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &Retention{}, lockError("getretention", key, res)
+	}
+	ret := new(Retention)
+	if err := xml.NewDecoder(res.Body).Decode(ret); err != nil {
+		return nil, fmt.Errorf("xml decoding response: %w", err)
+	}
+	return ret, nil
+}
+
+// PutLegalHold places or removes a legal hold on the object at key.
+// An object under a legal hold cannot be deleted regardless of its
+// retention.
+func (b *Bucket) PutLegalHold(ctx context.Context, key string, on bool) error {
+	hold := &legalHold{
+		XMLName: xml.Name{Space: "http://s3.amazonaws.com/doc/2006-03-01/", Local: "LegalHold"},
+		Status:  "OFF",
+	}
+	if on {
+		hold.Status = "ON"
+	}
+	res, err := b.lockRequest(ctx, http.MethodPut, key, "legal-hold", hold)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return subresourceError("putlegalhold", key, res)
+	}
+	return nil
+}
+
+// GetLegalHold returns whether the object at key is under a legal hold.
+func (b *Bucket) GetLegalHold(ctx context.Context, key string) (bool, error) {
+	res, err := b.lockRequest(ctx, http.MethodGet, key, "legal-hold", nil)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return false, lockError("getlegalhold", key, res)
+	}
+	var hold legalHold
+	if err := xml.NewDecoder(res.Body).Decode(&hold); err != nil {
+		return false, fmt.Errorf("xml decoding response: %w", err)
+	}
+	return hold.Status == "ON", nil
+}
+
+// DeleteBypassGovernance removes the object at fullpath even if
+// it is protected by a RetentionGovernance retention. Objects
+// under a RetentionCompliance retention or a legal hold cannot
+// be removed, and an error matching fs.ErrPermission is returned.
+func (b *Bucket) DeleteBypassGovernance(ctx context.Context, fullpath string) error {
+	fullpath = path.Clean(fullpath)
+	if !fs.ValidPath(fullpath) {
+		return badpath("delete", fullpath)
+	}
+	return b.delete(ctx, fullpath, "", true)
+}
@@ -0,0 +1,126 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectLock(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	t.Run("no retention", func(t *testing.T) {
+		_, err := b.Write(ctx, "lock/none.txt", []byte("none"))
+		assert.NoError(t, err)
+
+		ret, err := b.GetRetention(ctx, "lock/none.txt")
+		assert.NoError(t, err)
+		assert.Empty(t, ret.Mode)
+		assert.False(t, ret.Active())
+
+		hold, err := b.GetLegalHold(ctx, "lock/none.txt")
+		assert.NoError(t, err)
+		assert.False(t, hold)
+	})
+
+	t.Run("governance until expiry", func(t *testing.T) {
+		_, err := b.Write(ctx, "lock/expiry.txt", []byte("expiry"))
+		assert.NoError(t, err)
+
+		until := time.Now().Add(200 * time.Millisecond).Truncate(time.Millisecond)
+		assert.NoError(t, b.PutRetention(ctx, "lock/expiry.txt", RetentionGovernance, until))
+
+		ret, err := b.GetRetention(ctx, "lock/expiry.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, RetentionGovernance, ret.Mode)
+		assert.True(t, ret.Until.Equal(until))
+
+		err = b.Delete(ctx, "lock/expiry.txt")
+		assert.ErrorIs(t, err, fs.ErrPermission)
+		assert.True(t, mockServer.ObjectExists("lock/expiry.txt"))
+
+		time.Sleep(time.Until(until))
+		assert.NoError(t, b.Delete(ctx, "lock/expiry.txt"))
+		assert.False(t, mockServer.ObjectExists("lock/expiry.txt"))
+	})
+
+	t.Run("governance bypass", func(t *testing.T) {
+		_, err := b.Write(ctx, "lock/bypass.txt", []byte("bypass"))
+		assert.NoError(t, err)
+		assert.NoError(t, b.PutRetention(ctx, "lock/bypass.txt", RetentionGovernance, time.Now().Add(time.Hour)))
+
+		assert.ErrorIs(t, b.Delete(ctx, "lock/bypass.txt"), fs.ErrPermission)
+		assert.NoError(t, b.DeleteBypassGovernance(ctx, "lock/bypass.txt"))
+		assert.False(t, mockServer.ObjectExists("lock/bypass.txt"))
+		deletes := mockServer.GetRequestsWithMethod("DELETE")
+		assert.Equal(t, "true", deletes[len(deletes)-1].Headers["X-Amz-Bypass-Governance-Retention"])
+		assert.Contains(t, signedHeaders(deletes[len(deletes)-1]), "x-amz-bypass-governance-retention")
+	})
+
+	t.Run("compliance", func(t *testing.T) {
+		_, err := b.Write(ctx, "lock/compliance.txt", []byte("compliance"))
+		assert.NoError(t, err)
+		assert.NoError(t, b.PutRetention(ctx, "lock/compliance.txt", RetentionCompliance, time.Now().Add(time.Hour)))
+
+		assert.ErrorIs(t, b.DeleteBypassGovernance(ctx, "lock/compliance.txt"), fs.ErrPermission)
+		assert.True(t, mockServer.ObjectExists("lock/compliance.txt"))
+	})
+
+	t.Run("legal hold", func(t *testing.T) {
+		_, err := b.Write(ctx, "lock/hold.txt", []byte("hold"))
+		assert.NoError(t, err)
+		assert.NoError(t, b.PutLegalHold(ctx, "lock/hold.txt", true))
+
+		hold, err := b.GetLegalHold(ctx, "lock/hold.txt")
+		assert.NoError(t, err)
+		assert.True(t, hold)
+		assert.ErrorIs(t, b.DeleteBypassGovernance(ctx, "lock/hold.txt"), fs.ErrPermission)
+
+		assert.NoError(t, b.PutLegalHold(ctx, "lock/hold.txt", false))
+		hold, err = b.GetLegalHold(ctx, "lock/hold.txt")
+		assert.NoError(t, err)
+		assert.False(t, hold)
+		assert.NoError(t, b.Delete(ctx, "lock/hold.txt"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		err := b.PutRetention(ctx, "lock/none.txt", "FOREVER", time.Now())
+		assert.Error(t, err)
+
+		_, err = b.GetRetention(ctx, "lock/missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+
+		err = b.PutLegalHold(ctx, "lock/missing.txt", true)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+
+		_, err = b.GetLegalHold(ctx, "../bad")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+}
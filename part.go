@@ -0,0 +1,111 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+)
+
+// PartInfo describes a part read with Reader.ReadPart.
+type PartInfo struct {
+	PartNumber int    // PartNumber is the number of the part, starting at 1
+	Offset     int64  // Offset of the part within the object
+	Size       int64  // Size of the part in bytes
+	PartsCount int    // PartsCount is the number of parts of the object, or 1 if it was not uploaded in parts
+	ETag       string // ETag of the whole object, as S3 does not report the ETags of parts on read
+}
+
+// UploadedPart describes a part of an incomplete
+// multipart upload, as returned by Bucket.ListParts.
+type UploadedPart struct {
+	PartNumber     int    // PartNumber is the number of the part, starting at 1
+	Size           int64  // Size of the part in bytes
+	ETag           string // ETag of the part
+	ChecksumSHA256 string // ChecksumSHA256 of the part, if it was uploaded with one
+}
+
+// ReadPart reads a single part of a multipart object using
+// the partNumber parameter of GetObject, which is exact even
+// when the sizes of the parts are not known. Part numbers start
+// at 1; an object that was not uploaded in parts has a single part.
+//
+// The caller must close the returned body. If the ETag of r is set,
+// ReadPart fails with ErrETagChanged if the object has changed. If the
+// server ignores partNumber, ReadPart fails with an error matching
+// ErrRangeUnsupported for any part but the first.
+func (r *Reader) ReadPart(ctx context.Context, partNumber int) (io.ReadCloser, *PartInfo, error) {
+	if partNumber < 1 {
+		return nil, nil, fmt.Errorf("s3.Reader.ReadPart: invalid part number %d", partNumber)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri(r.Key, r.Bucket, r.Path)+"?partNumber="+strconv.Itoa(partNumber), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.ETag != "" {
+		req.Header.Set("If-Match", r.ETag)
+	}
+	setUserAgent(req, r.UserAgent)
+	r.Key.SignV4(req, nil)
+
+	res, err := flakyDo(r.Client, r.Limiter, r.Logger, r.stats, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch res.StatusCode {
+	default:
+		defer res.Body.Close()
+		return nil, nil, responseError("s3.Reader.ReadPart", res)
+	case http.StatusPreconditionFailed:
+		res.Body.Close()
+		return nil, nil, ErrETagChanged
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, nil, &fs.PathError{Op: "read", Path: r.Path, Err: fs.ErrNotExist}
+	case http.StatusOK:
+		// the server may have ignored partNumber and
+		// sent the full object, which only stands for
+		// the first part of an object without parts
+		if partNumber != 1 && res.Header.Get("Content-Range") == "" {
+			res.Body.Close()
+			return nil, nil, &fs.PathError{Op: "read", Path: r.Path, Err: ErrRangeUnsupported}
+		}
+	case http.StatusPartialContent:
+		// okay
+	}
+
+	info := &PartInfo{
+		PartNumber: partNumber,
+		Size:       res.ContentLength,
+		PartsCount: 1,
+		ETag:       res.Header.Get("ETag"),
+	}
+	if n, err := strconv.Atoi(res.Header.Get("x-amz-mp-parts-count")); err == nil {
+		info.PartsCount = n
+	}
+	if cr := res.Header.Get("Content-Range"); cr != "" {
+		var start, end int64
+		if _, err := fmt.Sscanf(cr, "bytes %d-%d/", &start, &end); err != nil {
+			res.Body.Close()
+			return nil, nil, fmt.Errorf("s3.Reader.ReadPart: bad Content-Range %q", cr)
+		}
+		info.Offset, info.Size = start, end-start+1
+	}
+	return budgeted(r.ReadBudget, res.Body), info, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPart(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	part1 := bytes.Repeat([]byte{1}, 1024)
+	part2 := []byte("the second and final part")
+	u := &uploader{Key: key, Bucket: bucket, Object: "parts.bin", MinPartOverride: 1024}
+	assert.NoError(t, u.Start(ctx))
+	assert.NoError(t, u.Upload(1, part1))
+	assert.NoError(t, u.Close(ctx, part2))
+	mockServer.PutObject("single.txt", []byte("hello"))
+
+	t.Run("second", func(t *testing.T) {
+		f, err := b.Open("parts.bin")
+		assert.NoError(t, err)
+		defer f.Close()
+
+		body, info, err := f.(*File).ReadPart(ctx, 2)
+		assert.NoError(t, err)
+		defer body.Close()
+
+		data, err := io.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, part2, data)
+		assert.Equal(t, 2, info.PartNumber)
+		assert.Equal(t, 2, info.PartsCount)
+		assert.Equal(t, int64(len(part1)), info.Offset)
+		assert.Equal(t, int64(len(part2)), info.Size)
+		assert.Equal(t, f.(*File).ETag, info.ETag)
+	})
+
+	t.Run("first", func(t *testing.T) {
+		r := Reader{Key: key, Bucket: bucket, Path: "parts.bin"}
+		body, info, err := r.ReadPart(ctx, 1)
+		assert.NoError(t, err)
+		defer body.Close()
+
+		data, err := io.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, part1, data)
+		assert.Equal(t, int64(0), info.Offset)
+		assert.Equal(t, int64(len(part1)), info.Size)
+	})
+
+	t.Run("single", func(t *testing.T) {
+		r := Reader{Key: key, Bucket: bucket, Path: "single.txt"}
+		body, info, err := r.ReadPart(ctx, 1)
+		assert.NoError(t, err)
+		defer body.Close()
+
+		data, err := io.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+		assert.Equal(t, 1, info.PartsCount)
+		assert.Equal(t, int64(5), info.Size)
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		r := Reader{Key: key, Bucket: bucket, Path: "parts.bin"}
+		_, _, err := r.ReadPart(ctx, 3)
+		var serr *Error
+		if assert.ErrorAs(t, err, &serr) {
+			assert.Equal(t, "InvalidPartNumber", serr.Code)
+		}
+
+		_, _, err = r.ReadPart(ctx, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("ignored part number", func(t *testing.T) {
+		// a backend that ignores partNumber
+		// sends the whole object for every part
+		whole := func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    http.StatusOK,
+				Status:        "200 OK",
+				Header:        http.Header{},
+				Body:          io.NopCloser(strings.NewReader("the whole object")),
+				ContentLength: 16,
+				Request:       req,
+			}, nil
+		}
+		r := Reader{Key: key, Bucket: bucket, Path: "parts.bin", Client: &http.Client{Transport: roundTripFunc(whole)}}
+		_, _, err := r.ReadPart(ctx, 2)
+		assert.ErrorIs(t, err, ErrRangeUnsupported)
+
+		body, info, err := r.ReadPart(ctx, 1)
+		assert.NoError(t, err)
+		defer body.Close()
+		assert.Equal(t, int64(16), info.Size)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		r := Reader{Key: key, Bucket: bucket, Path: "parts.bin", ETag: `"stale"`}
+		_, _, err := r.ReadPart(ctx, 1)
+		assert.ErrorIs(t, err, ErrETagChanged)
+	})
+}
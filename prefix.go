@@ -18,15 +18,20 @@ package s3
 import (
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"iter"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"path"
 	"slices"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/kelindar/s3/aws"
@@ -34,12 +39,20 @@ import (
 
 // Prefix implements fs.File, fs.ReadDirFile, and fs.DirEntry, and fs.FS.
 type Prefix struct {
-	Key    *aws.SigningKey `xml:"-"`      // Key is the signing key used to sign requests.
-	Client *http.Client    `xml:"-"`      // Client is the HTTP client used to make requests. If it is nil, then DefaultClient will be used.
-	Bucket string          `xml:"-"`      // Bucket is the bucket at the root of the "filesystem"
-	Path   string          `xml:"Prefix"` // Path is the path of this prefix, should always be a valid path  (see fs.ValidPath) plus a trailing forward slash to indicate that this is a pseudo-directory prefix.
-	token  string          `xml:"-"`      // listing token; "" means start from the beginning
-	dirEOF bool            `xml:"-"`      // if true, ReadDir returns io.EOF
+	Key         *aws.SigningKey `xml:"-"`      // Key is the signing key used to sign requests.
+	Client      *http.Client    `xml:"-"`      // Client is the HTTP client used to make requests. If it is nil, then DefaultClient will be used.
+	Bucket      string          `xml:"-"`      // Bucket is the bucket at the root of the "filesystem"
+	Path        string          `xml:"Prefix"` // Path is the path of this prefix, should always be a valid path  (see fs.ValidPath) plus a trailing forward slash to indicate that this is a pseudo-directory prefix.
+	UserAgent   string          `xml:"-"`      // UserAgent is sent with every request. If it is empty, then DefaultUserAgent will be used.
+	Limiter     Limiter         `xml:"-"`      // Limiter, if not nil, limits the rate of requests.
+	Logger      *slog.Logger    `xml:"-"`      // Logger, if not nil, logs every request at debug level.
+	ReadBudget  *int64          `xml:"-"`      // ReadBudget, if not nil, is the number of bytes of object contents that may still be read.
+	ErrorMapper ErrorMapper     `xml:"-"`      // ErrorMapper, if not nil, maps unsuccessful responses to errors, as for Bucket.ErrorMapper.
+	StrictETag  bool            `xml:"-"`      // StrictETag, if set, makes reads of the listed files fail with ErrETagChanged if the objects changed since they were listed.
+	token       string          `xml:"-"`      // listing token; "" means start from the beginning
+	dirEOF      bool            `xml:"-"`      // if true, ReadDir returns io.EOF
+	stats       *counters       `xml:"-"`      // counters of the Bucket, if any
+	listV1      *atomic.Bool    `xml:"-"`      // set once the server has rejected ListObjectsV2, shared with the Bucket
 }
 
 func (p *Prefix) join(extra string) string {
@@ -51,10 +64,18 @@ func (p *Prefix) join(extra string) string {
 
 func (p *Prefix) sub(name string) *Prefix {
 	return &Prefix{
-		Key:    p.Key,
-		Client: p.Client,
-		Bucket: p.Bucket,
-		Path:   p.join(name),
+		Key:         p.Key,
+		Client:      p.Client,
+		Bucket:      p.Bucket,
+		Path:        p.join(name),
+		UserAgent:   p.UserAgent,
+		Limiter:     p.Limiter,
+		Logger:      p.Logger,
+		ReadBudget:  p.ReadBudget,
+		ErrorMapper: p.ErrorMapper,
+		StrictETag:  p.StrictETag,
+		stats:       p.stats,
+		listV1:      p.listV1,
 	}
 }
 
@@ -68,7 +89,14 @@ func (p *Prefix) sub(name string) *Prefix {
 // do not produce a prefix that is present within
 // the target bucket, then an error matching
 // fs.ErrNotExist is returned.
+//
+// As with Bucket.Open, the object is fetched first
+// and the prefix is only listed if there is no such
+// object, unless file has a trailing slash.
 func (p *Prefix) Open(file string) (fs.File, error) {
+	// interpret a trailing / to mean
+	// a directory; see Bucket.Open
+	isDir := strings.HasSuffix(file, "/")
 	file = path.Clean(file)
 	if file == "." {
 		return p, nil
@@ -76,15 +104,35 @@ func (p *Prefix) Open(file string) (fs.File, error) {
 	if !fs.ValidPath(file) {
 		return nil, badpath("open", file)
 	}
-	return p.sub(file).openDir()
+	if !isDir {
+		// a GET is cheaper than a listing
+		f := &File{Reader: p.reader()}
+		err := f.open(p.Key, p.Bucket, p.join(file), true)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	// path.Join strips the trailing slash that
+	// keeps siblings sharing a string prefix out
+	// of the listing, so add it back
+	dir := p.sub(file)
+	dir.Path += "/"
+	return dir.openDir()
 }
 
 func (p *Prefix) openDir() (fs.File, error) {
+	return p.openDirContext(context.Background())
+}
+
+func (p *Prefix) openDirContext(ctx context.Context) (fs.File, error) {
 	if p.Path == "" || p.Path == "." {
 		// the root directory trivially exists
 		return p, nil
 	}
-	ret, err := p.list(1, "", "", "")
+	ret, err := p.listContext(ctx, 1, "", "", "")
 	if err != nil {
 		return nil, err
 	}
@@ -97,13 +145,59 @@ func (p *Prefix) openDir() (fs.File, error) {
 	}
 	path := p.Path + "/"
 	return &Prefix{
-		Key:    p.Key,
-		Bucket: p.Bucket,
-		Client: p.Client,
-		Path:   path,
+		Key:         p.Key,
+		Bucket:      p.Bucket,
+		Client:      p.Client,
+		Path:        path,
+		UserAgent:   p.UserAgent,
+		Limiter:     p.Limiter,
+		Logger:      p.Logger,
+		ReadBudget:  p.ReadBudget,
+		ErrorMapper: p.ErrorMapper,
+		StrictETag:  p.StrictETag,
+		stats:       p.stats,
+		listV1:      p.listV1,
 	}, nil
 }
 
+// reader returns a Reader with the settings of p,
+// so that every object read through p shares them.
+func (p *Prefix) reader() Reader {
+	return Reader{
+		Key:         p.Key,
+		Client:      p.Client,
+		Bucket:      p.Bucket,
+		UserAgent:   p.UserAgent,
+		Limiter:     p.Limiter,
+		Logger:      p.Logger,
+		ReadBudget:  p.ReadBudget,
+		ErrorMapper: p.ErrorMapper,
+		StrictETag:  p.StrictETag,
+		stats:       p.stats,
+	}
+}
+
+// notDir returns a *fs.PathError wrapping syscall.ENOTDIR
+// if the path of p, without its trailing slash, is an
+// object rather than a directory, and nil otherwise.
+// The HEAD of the object is bound to ctx.
+func (p *Prefix) notDir(ctx context.Context) error {
+	name := strings.TrimSuffix(p.Path, "/")
+	if name == "" || name == "." || ctx.Err() != nil {
+		return nil
+	}
+	r := p.reader()
+	r.ctx = ctx
+	body, err := r.open(p.Key, p.Bucket, name, false)
+	if body != nil {
+		body.Close()
+	}
+	if err != nil {
+		return nil
+	}
+	return &fs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+}
+
 // Name implements fs.DirEntry.Name
 func (p *Prefix) Name() string {
 	return path.Base(p.Path)
@@ -162,36 +256,97 @@ func (p *Prefix) Close() error {
 //
 // Every returned fs.DirEntry will be either
 // a Prefix or a File struct.
+//
+// S3 returns at most 1000 keys per list request,
+// so if n is larger than that, ReadDir issues as
+// many list requests as needed to return n entries.
+//
+// As with os.File, once every entry has been returned,
+// ReadDir with n > 0 returns (nil, io.EOF) rather than
+// returning io.EOF alongside the last entries.
 func (p *Prefix) ReadDir(n int) ([]fs.DirEntry, error) {
 	return p.readDirContext(context.Background(), n)
 }
 
 func (p *Prefix) readDirContext(ctx context.Context, n int) ([]fs.DirEntry, error) {
-	if p.dirEOF {
-		return nil, io.EOF
-	}
-	d, next, err := p.readDirAtContext(ctx, n, p.token, "", "")
-	if err == io.EOF {
-		p.dirEOF = true
-		if len(d) > 0 || n < 0 {
+	first := p.token == ""
+	for !p.dirEOF {
+		d, next, err := p.readDirAtContext(ctx, n, p.token, "", "")
+		if err == io.EOF {
+			p.dirEOF = true
+			if len(d) == 0 && first {
+				// an empty listing of an object
+				// path means it is not a directory
+				if err := p.notDir(ctx); err != nil {
+					return nil, err
+				}
+			}
+			if len(d) == 0 && n > 0 {
+				break
+			}
 			// the spec for fs.ReadDirFile says
 			// ReadDir(-1) shouldn't produce an explicit EOF
-			err = nil
+			return d, nil
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: p.Path, Err: err}
+		}
+		p.token = next
+		// a page holding only ignored keys (such as the
+		// directory marker) is empty but not the end, and
+		// ReadDir(n > 0) may only return nothing with an error
+		if len(d) > 0 || n <= 0 {
+			return d, nil
 		}
 	}
-	if err != nil {
-		return nil, &fs.PathError{Op: "readdir", Path: p.Path, Err: err}
+	return nil, io.EOF
+}
+
+// ReadDirLimit returns at most total entries of p, in the
+// order of ReadDir, issuing as many list requests as needed,
+// each of at most 1000 keys, and stopping once it has total
+// entries. Unlike ReadDir, it always starts from the first
+// entry and does not change the position of ReadDir, so it
+// can be used to bound a search of a large directory. Fewer
+// than total entries are returned only if p has fewer.
+func (p *Prefix) ReadDirLimit(ctx context.Context, total int) ([]fs.DirEntry, error) {
+	if total < 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: p.Path, Err: fmt.Errorf("negative total %d", total)}
 	}
-	p.token = next
+	var d []fs.DirEntry
+	token := ""
+	for len(d) < total {
+		page, next, err := p.readPage(ctx, min(total-len(d), maxListKeys), token, "", "")
+		d = append(d, page...)
+		if err == io.EOF {
+			if len(d) == 0 && token == "" {
+				if err := p.notDir(ctx); err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: p.Path, Err: err}
+		}
+		token = next
+	}
+	sortEntries(d)
 	return d, nil
 }
 
+// maxListKeys is the maximum number of keys S3 returns
+// from a single list request; larger max-keys values
+// are silently clamped by the server.
+const maxListKeys = 1000
+
 type listResponse struct {
 	IsTruncated    bool     `xml:"IsTruncated"`
 	Contents       []File   `xml:"Contents"`
 	CommonPrefixes []Prefix `xml:"CommonPrefixes"`
 	EncodingType   string   `xml:"EncodingType"`
 	NextToken      string   `xml:"NextContinuationToken"`
+	NextMarker     string   `xml:"NextMarker"` // only set by ListObjects (V1)
 }
 
 func (p *Prefix) list(n int, token, seek, prefix string) (*listResponse, error) {
@@ -199,47 +354,185 @@ func (p *Prefix) list(n int, token, seek, prefix string) (*listResponse, error)
 }
 
 func (p *Prefix) listContext(ctx context.Context, n int, token, seek, prefix string) (*listResponse, error) {
+	// the seek parameter is only meaningful
+	// if it is "larger" than the prefix being listed;
+	// otherwise we should reject it
+	// (AWS S3 accepts redundant start-after params,
+	// but Minio rejects them)
+	if seek != "" && (seek < prefix || !strings.HasPrefix(seek, prefix)) {
+		return nil, fmt.Errorf("seek %q not compatible with prefix %q", seek, prefix)
+	}
+	return p.listWith(ctx, ListOptions{
+		Delimiter:         "/",
+		Prefix:            prefix,
+		StartAfter:        seek,
+		MaxKeys:           n,
+		ContinuationToken: token,
+	})
+}
+
+// ListOptions configures a listing made with Prefix.ListWith.
+type ListOptions struct {
+	Delimiter         string // Delimiter groups keys into common prefixes. If it is empty, the listing is flat.
+	Prefix            string // Prefix restricts the listing to keys beginning with Prefix, relative to the listed Prefix.
+	StartAfter        string // StartAfter starts the listing after this key, relative to the listed Prefix.
+	MaxKeys           int    // MaxKeys limits the number of entries returned. If it is not positive, the server default is used. It is clamped to maxListKeys.
+	ContinuationToken string // ContinuationToken resumes a truncated listing.
+	FetchOwner        bool   // FetchOwner requests the Owner of each object, which S3 omits by default.
+}
+
+// ListResult is a single page of results returned by Prefix.ListWith.
+type ListResult struct {
+	Contents       []File   // Contents are the objects in the page, with their full keys as paths.
+	CommonPrefixes []string // CommonPrefixes are the full key prefixes grouped by the delimiter.
+	IsTruncated    bool     // IsTruncated is set if there are more results.
+	NextToken      string   // NextToken is the ContinuationToken for the next page.
+}
+
+// ListWith lists a single page of objects under p using
+// the given options, returning the raw result. Unlike
+// ReadDir, ListWith does not assume that "/" delimits
+// directories, and does not filter the returned keys.
+func (p *Prefix) ListWith(ctx context.Context, opts ListOptions) (*ListResult, error) {
+	ret, err := p.listWith(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := &ListResult{
+		Contents:       ret.Contents,
+		CommonPrefixes: make([]string, len(ret.CommonPrefixes)),
+		IsTruncated:    ret.IsTruncated,
+		NextToken:      ret.NextToken,
+	}
+	for i := range out.Contents {
+		out.Contents[i].Key = p.Key
+		out.Contents[i].Client = p.client()
+		out.Contents[i].Bucket = p.Bucket
+		out.Contents[i].UserAgent = p.UserAgent
+		out.Contents[i].Limiter = p.Limiter
+		out.Contents[i].ReadBudget = p.ReadBudget
+		out.Contents[i].ErrorMapper = p.ErrorMapper
+		out.Contents[i].StrictETag = p.StrictETag
+		out.Contents[i].Logger = p.Logger
+		out.Contents[i].stats = p.stats
+		out.Contents[i].ctx = context.Background()
+	}
+	for i := range ret.CommonPrefixes {
+		out.CommonPrefixes[i] = ret.CommonPrefixes[i].Path
+	}
+	return out, nil
+}
+
+// listWith lists a page with ListObjectsV2, falling back to
+// the original ListObjects API (V1) on servers which reject it.
+// The V1 marker is returned as the NextToken, so that the
+// fallback is transparent to callers which page a listing.
+//
+// Only the first page of a listing probes for V1, since the
+// continuation token of a V2 listing is opaque and cannot be
+// used as a V1 marker. Once a probe has found that the server
+// does not implement ListObjectsV2, the Bucket and all of its
+// prefixes list with V1 straight away.
+func (p *Prefix) listWith(ctx context.Context, opts ListOptions) (*listResponse, error) {
 	if !ValidBucket(p.Bucket) {
 		return nil, badBucket(p.Bucket)
 	}
+	if useListV1(p.listV1) {
+		return p.listPage(ctx, opts, true)
+	}
+	ret, err := p.listPage(ctx, opts, false)
+	var e *Error
+	if opts.ContinuationToken == "" && errors.As(err, &e) && listV2Unsupported(e) {
+		if p.listV1 != nil {
+			p.listV1.Store(true)
+		}
+		ret, err = p.listPage(ctx, opts, true)
+	}
+	return ret, err
+}
+
+// useListV1 reports whether v1 records that the
+// server does not implement ListObjectsV2.
+func useListV1(v1 *atomic.Bool) bool {
+	return v1 != nil && v1.Load()
+}
+
+// listV2Unsupported reports whether e is the response of
+// a server which does not implement ListObjectsV2. Those
+// either do not implement list-type=2 at all, or reject it
+// as an invalid argument. S3 also returns InvalidArgument
+// for a bad continuation token, max-keys or start-after,
+// so that is only taken to mean the former if the message
+// is about list-type.
+func listV2Unsupported(e *Error) bool {
+	switch e.StatusCode {
+	case http.StatusNotImplemented:
+		return true
+	case http.StatusBadRequest:
+		switch e.Code {
+		case "NotImplemented":
+			return true
+		case "InvalidArgument":
+			return strings.Contains(strings.ToLower(e.Message), "list-type")
+		}
+	}
+	return false
+}
+
+// listPage lists a single page, with ListObjects
+// rather than ListObjectsV2 if v1 is set.
+func (p *Prefix) listPage(ctx context.Context, opts ListOptions, v1 bool) (*listResponse, error) {
 	parts := []string{
-		"delimiter=%2F",
-		"list-type=2",
+		"encoding-type=url",
+	}
+	if !v1 {
+		parts = append(parts, "list-type=2")
+	}
+	if opts.Delimiter != "" {
+		parts = append(parts, "delimiter="+queryEscape(opts.Delimiter))
 	}
 	// make sure there's a '/' at the end and
 	// append the prefix
 	path := p.Path
 	if path != "" && path != "." {
 		if !strings.HasSuffix(path, "/") {
-			path += "/" + prefix
+			path += "/" + opts.Prefix
 		} else {
-			path += prefix
+			path += opts.Prefix
 		}
 	} else {
 		// NOTE: if p.Path was "." this will replace
 		// it with prefix which may be ""; this is
 		// the intended behavior
-		path = prefix
+		path = opts.Prefix
 	}
 	if path != "" {
 		parts = append(parts, "prefix="+queryEscape(path))
 	}
-	// the seek parameter is only meaningful
-	// if it is "larger" than the prefix being listed;
-	// otherwise we should reject it
-	// (AWS S3 accepts redundant start-after params,
-	// but Minio rejects them)
-	if seek != "" && (seek < prefix || !strings.HasPrefix(seek, prefix)) {
-		return nil, fmt.Errorf("seek %q not compatible with prefix %q", seek, prefix)
-	}
-	if seek != "" {
-		parts = append(parts, "start-after="+queryEscape(p.join(seek)))
-	}
-	if n > 0 {
-		parts = append(parts, fmt.Sprintf("max-keys=%d", n))
+	if opts.MaxKeys > 0 {
+		parts = append(parts, fmt.Sprintf("max-keys=%d", min(opts.MaxKeys, maxListKeys)))
 	}
-	if token != "" {
-		parts = append(parts, "continuation-token="+url.QueryEscape(token))
+	switch {
+	case v1:
+		// V1 has a single marker, which is the last key
+		// of the previous page or the key to start after
+		marker := opts.ContinuationToken
+		if marker == "" && opts.StartAfter != "" {
+			marker = p.join(opts.StartAfter)
+		}
+		if marker != "" {
+			parts = append(parts, "marker="+queryEscape(marker))
+		}
+	default:
+		if opts.StartAfter != "" {
+			parts = append(parts, "start-after="+queryEscape(p.join(opts.StartAfter)))
+		}
+		if opts.ContinuationToken != "" {
+			parts = append(parts, "continuation-token="+url.QueryEscape(opts.ContinuationToken))
+		}
+		if opts.FetchOwner {
+			parts = append(parts, "fetch-owner=true")
+		}
 	}
 	sort.Strings(parts)
 	query := "?" + strings.Join(parts, "&")
@@ -247,33 +540,151 @@ func (p *Prefix) listContext(ctx context.Context, n int, token, seek, prefix str
 	if err != nil {
 		return nil, fmt.Errorf("creating http request: %w", err)
 	}
+	setUserAgent(req, p.UserAgent)
 	p.Key.SignV4(req, nil)
-	res, err := flakyDo(p.client(), req)
+	res, err := flakyDo(p.client(), p.Limiter, p.Logger, p.stats, req)
 	if err != nil {
 		return nil, fmt.Errorf("executing request: %w", err)
 	}
 	defer res.Body.Close()
-	switch res.StatusCode {
-	case 200:
-		// ok
-	case 403:
-		return nil, fs.ErrPermission
-	case 404:
-		// this can actually mean the bucket doesn't exist,
+	if res.StatusCode != 200 {
+		// a 404 can actually mean the bucket doesn't exist,
 		// but for practical purposes we can treat it
 		// as an empty filesystem
-		return nil, fs.ErrNotExist
-	default:
-		return nil, fmt.Errorf("s3 list objects s3://%s/%s: %s", p.Bucket, p.Path, res.Status)
+		return nil, mapError(p.ErrorMapper, fmt.Sprintf("s3 list objects s3://%s/%s", p.Bucket, p.Path), res)
 	}
 
 	var ret listResponse
 	if err := xml.NewDecoder(res.Body).Decode(&ret); err != nil {
 		return nil, fmt.Errorf("xml decoding response: %w", err)
 	}
+	if err := ret.decode(); err != nil {
+		return nil, err
+	}
+	if v1 && ret.IsTruncated {
+		ret.NextToken = ret.nextMarker()
+	}
 	return &ret, nil
 }
 
+// nextMarker returns the marker of the page after r in
+// a V1 listing. S3 only returns a NextMarker when listing
+// with a delimiter; otherwise it is the last key of r.
+func (r *listResponse) nextMarker() string {
+	if r.NextMarker != "" {
+		return r.NextMarker
+	}
+	var last string
+	if n := len(r.Contents); n > 0 {
+		last = r.Contents[n-1].Path()
+	}
+	if n := len(r.CommonPrefixes); n > 0 && r.CommonPrefixes[n-1].Path > last {
+		last = r.CommonPrefixes[n-1].Path
+	}
+	return last
+}
+
+// SubDirs returns the names, relative to p, of the immediate
+// subdirectories of p, which are the common prefixes of a listing
+// delimited by "/", paging through the listing as needed. Unlike
+// ReadDir, SubDirs does not return the objects directly under p.
+func (p *Prefix) SubDirs(ctx context.Context) ([]string, error) {
+	dir := p.Path
+	if dir == "." {
+		dir = ""
+	} else if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	var out []string
+	opts := ListOptions{Delimiter: "/"}
+	for {
+		ret, err := p.listWith(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ret.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(ret.CommonPrefixes[i].Path, dir), "/")
+			if name != "" && name != "." && name != ".." {
+				out = append(out, name)
+			}
+		}
+		if !ret.IsTruncated {
+			return out, nil
+		}
+		opts.ContinuationToken = ret.NextToken
+	}
+}
+
+// FileEntry describes an object yielded by Prefix.Walk.
+type FileEntry struct {
+	Path    string    // Path is the full key of the object
+	Size    int64     // Size of the object in bytes
+	ModTime time.Time // ModTime is the LastModified time of the object
+	ETag    string    // ETag of the object
+}
+
+// Walk lazily yields every object under p, at any depth, in key
+// order. Rather than listing one directory at a time as fs.WalkDir
+// does, Walk pages through a flat listing, requesting the next page
+// only once the previous one has been consumed, so that breaking out
+// of the loop stops the listing. Directory markers (keys ending in
+// "/") are not yielded. Walk yields the first error and stops.
+func (p *Prefix) Walk(ctx context.Context) iter.Seq2[FileEntry, error] {
+	return func(yield func(FileEntry, error) bool) {
+		opts := ListOptions{MaxKeys: maxListKeys}
+		for {
+			ret, err := p.listWith(ctx, opts)
+			if err != nil {
+				yield(FileEntry{}, &fs.PathError{Op: "walk", Path: p.Path, Err: err})
+				return
+			}
+			for i := range ret.Contents {
+				f := &ret.Contents[i]
+				if strings.HasSuffix(f.Path(), "/") {
+					continue
+				}
+				entry := FileEntry{
+					Path:    f.Path(),
+					Size:    f.Reader.Size,
+					ModTime: f.Reader.LastModified,
+					ETag:    f.ETag,
+				}
+				if !yield(entry, nil) {
+					return
+				}
+			}
+			if !ret.IsTruncated {
+				return
+			}
+			opts.ContinuationToken = ret.NextToken
+		}
+	}
+}
+
+// decode URL-decodes the keys and prefixes of a listing
+// returned with encoding-type=url, which is requested so
+// that keys with characters XML cannot carry are listed.
+func (r *listResponse) decode() error {
+	if r.EncodingType != "url" {
+		return nil
+	}
+	var err error
+	for i := range r.Contents {
+		if r.Contents[i].Reader.Path, err = url.QueryUnescape(r.Contents[i].Reader.Path); err != nil {
+			return fmt.Errorf("decoding key: %w", err)
+		}
+	}
+	for i := range r.CommonPrefixes {
+		if r.CommonPrefixes[i].Path, err = url.QueryUnescape(r.CommonPrefixes[i].Path); err != nil {
+			return fmt.Errorf("decoding prefix: %w", err)
+		}
+	}
+	if r.NextMarker, err = url.QueryUnescape(r.NextMarker); err != nil {
+		return fmt.Errorf("decoding marker: %w", err)
+	}
+	return nil
+}
+
 func patmatch(pattern, name string) (bool, error) {
 	if pattern == "" {
 		return true, nil
@@ -309,11 +720,39 @@ func ignoreKey(key string, dirOK bool) bool {
 // entries, an empty continuation token, and
 // io.EOF. Note that this behavior differs from
 // fs.ReadDirFile.ReadDir.
+//
+// Since a single list request returns at most
+// maxListKeys keys, a positive n larger than
+// that is satisfied by issuing as many list
+// requests as needed. Otherwise, readDirAt
+// makes exactly one list request.
 func (p *Prefix) readDirAt(n int, token, seek, pattern string) (d []fs.DirEntry, next string, err error) {
 	return p.readDirAtContext(context.Background(), n, token, seek, pattern)
 }
 
 func (p *Prefix) readDirAtContext(ctx context.Context, n int, token, seek, pattern string) (d []fs.DirEntry, next string, err error) {
+	if n <= maxListKeys {
+		return p.readPage(ctx, n, token, seek, pattern)
+	}
+	for requested := 0; requested < n; requested += maxListKeys {
+		page, tok, err := p.readPage(ctx, min(n-requested, maxListKeys), token, seek, pattern)
+		d = append(d, page...)
+		if err != nil {
+			if err == io.EOF {
+				sortEntries(d)
+				return d, tok, err
+			}
+			return nil, "", err
+		}
+		token = tok
+	}
+	sortEntries(d)
+	return d, token, nil
+}
+
+// readPage reads the entries of a single
+// list request; see readDirAt.
+func (p *Prefix) readPage(ctx context.Context, n int, token, seek, pattern string) (d []fs.DirEntry, next string, err error) {
 	prefix, _ := splitMeta(pattern)
 	ret, err := p.listContext(ctx, n, token, seek, prefix)
 	if err != nil {
@@ -334,6 +773,13 @@ func (p *Prefix) readDirAtContext(ctx context.Context, n int, token, seek, patte
 		ret.Contents[i].Key = p.Key
 		ret.Contents[i].Client = p.client()
 		ret.Contents[i].Bucket = p.Bucket
+		ret.Contents[i].UserAgent = p.UserAgent
+		ret.Contents[i].Limiter = p.Limiter
+		ret.Contents[i].ReadBudget = p.ReadBudget
+		ret.Contents[i].ErrorMapper = p.ErrorMapper
+		ret.Contents[i].StrictETag = p.StrictETag
+		ret.Contents[i].Logger = p.Logger
+		ret.Contents[i].stats = p.stats
 		// FIXME: we're using the "wrong" context here
 		// because we really just wanted to use the
 		// embedded context for limiting the time spent
@@ -356,17 +802,30 @@ func (p *Prefix) readDirAtContext(ctx context.Context, n int, token, seek, patte
 		ret.CommonPrefixes[i].Key = p.Key
 		ret.CommonPrefixes[i].Bucket = p.Bucket
 		ret.CommonPrefixes[i].Client = p.Client
+		ret.CommonPrefixes[i].UserAgent = p.UserAgent
+		ret.CommonPrefixes[i].Limiter = p.Limiter
+		ret.CommonPrefixes[i].ReadBudget = p.ReadBudget
+		ret.CommonPrefixes[i].ErrorMapper = p.ErrorMapper
+		ret.CommonPrefixes[i].StrictETag = p.StrictETag
+		ret.CommonPrefixes[i].Logger = p.Logger
+		ret.CommonPrefixes[i].stats = p.stats
+		ret.CommonPrefixes[i].listV1 = p.listV1
 		out = append(out, &ret.CommonPrefixes[i])
 	}
-	slices.SortFunc(out, func(a, b fs.DirEntry) int {
-		return strings.Compare(a.Name(), b.Name())
-	})
+	sortEntries(out)
 	if !ret.IsTruncated {
 		err = io.EOF
 	}
 	return out, ret.NextToken, err
 }
 
+// sortEntries sorts directory entries by name.
+func sortEntries(d []fs.DirEntry) {
+	slices.SortFunc(d, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+}
+
 func (p *Prefix) client() *http.Client {
 	if p.Client == nil {
 		return &DefaultClient
@@ -15,10 +15,13 @@
 package s3
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -443,3 +446,823 @@ func TestPrefix(t *testing.T) {
 		assert.Equal(t, customClient, client)
 	})
 }
+
+func TestPrefix_ListWith(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	mockServer.PopulateTestData(map[string][]byte{
+		"2024-01-a":      []byte("a"),
+		"2024-01-b":      []byte("b"),
+		"2024-02-a":      []byte("c"),
+		"2025-01-a":      []byte("d"),
+		"other":          []byte("e"),
+		"lake/x-1-a.csv": []byte("f"),
+		"lake/x-2-a.csv": []byte("g"),
+	})
+
+	b := NewBucket(key, bucket)
+	root := b.sub(".")
+	ctx := context.Background()
+
+	t.Run("delimiter", func(t *testing.T) {
+		ret, err := root.ListWith(ctx, ListOptions{Delimiter: "-"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2024-", "2025-", "lake/x-"}, ret.CommonPrefixes)
+		if assert.Len(t, ret.Contents, 1) {
+			assert.Equal(t, "other", ret.Contents[0].Path())
+		}
+		assert.False(t, ret.IsTruncated)
+
+		ret, err = root.ListWith(ctx, ListOptions{Delimiter: "-", Prefix: "2024-"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2024-01-", "2024-02-"}, ret.CommonPrefixes)
+		assert.Empty(t, ret.Contents)
+	})
+
+	t.Run("sub prefix", func(t *testing.T) {
+		ret, err := b.sub("lake/").ListWith(ctx, ListOptions{Delimiter: "-"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"lake/x-"}, ret.CommonPrefixes)
+		assert.Empty(t, ret.Contents)
+	})
+
+	t.Run("flat pages", func(t *testing.T) {
+		var keys []string
+		opts := ListOptions{Prefix: "2024", MaxKeys: 2}
+		for {
+			ret, err := root.ListWith(ctx, opts)
+			assert.NoError(t, err)
+			assert.Empty(t, ret.CommonPrefixes)
+			for i := range ret.Contents {
+				keys = append(keys, ret.Contents[i].Path())
+			}
+			if !ret.IsTruncated {
+				break
+			}
+			opts.ContinuationToken = ret.NextToken
+		}
+		assert.Equal(t, []string{"2024-01-a", "2024-01-b", "2024-02-a"}, keys)
+	})
+
+	t.Run("start after", func(t *testing.T) {
+		ret, err := root.ListWith(ctx, ListOptions{StartAfter: "2024-02-a", MaxKeys: 1})
+		assert.NoError(t, err)
+		if assert.Len(t, ret.Contents, 1) {
+			assert.Equal(t, "2025-01-a", ret.Contents[0].Path())
+			data, err := io.ReadAll(&ret.Contents[0])
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("d"), data)
+		}
+	})
+
+	t.Run("storage class and owner", func(t *testing.T) {
+		assert.True(t, mockServer.SetStorageClass("other", "GLACIER"))
+		defer mockServer.SetStorageClass("other", "")
+
+		ret, err := root.ListWith(ctx, ListOptions{Prefix: "2025-"})
+		assert.NoError(t, err)
+		if assert.Len(t, ret.Contents, 1) {
+			assert.Equal(t, "STANDARD", ret.Contents[0].StorageClass)
+			assert.Nil(t, ret.Contents[0].Owner)
+		}
+
+		ret, err = root.ListWith(ctx, ListOptions{Prefix: "other", FetchOwner: true})
+		assert.NoError(t, err)
+		if assert.Len(t, ret.Contents, 1) {
+			f := &ret.Contents[0]
+			assert.Equal(t, "GLACIER", f.StorageClass)
+			if assert.NotNil(t, f.Owner) {
+				assert.Equal(t, "mock-owner", f.Owner.ID)
+			}
+
+			// the owner is kept, and the class refreshed, on open
+			body, err := f.Reader.open(key, bucket, "other", false)
+			assert.NoError(t, err)
+			body.Close()
+			assert.Equal(t, "GLACIER", f.StorageClass)
+			assert.NotNil(t, f.Owner)
+		}
+
+		entries, err := fs.ReadDir(b, ".")
+		assert.NoError(t, err)
+		for _, entry := range entries {
+			if f, ok := entry.(*File); ok && f.Name() == "other" {
+				assert.Equal(t, "GLACIER", f.StorageClass)
+			}
+		}
+	})
+}
+
+func TestPrefix_SubDirs(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	data := map[string][]byte{
+		"top.txt":         []byte("0"),
+		"a/1.txt":         []byte("1"),
+		"a/b/2.txt":       []byte("2"),
+		"a/b/c/3.txt":     []byte("3"),
+		"a/empty/":        nil,
+		"d/e/f/4.txt":     []byte("4"),
+		"a b/%20/5.txt":   []byte("5"),
+		"many/marker.txt": []byte("6"),
+	}
+	for i := 0; i < 1005; i++ {
+		data[fmt.Sprintf("many/%04d/x.txt", i)] = []byte("x")
+	}
+	mockServer.PopulateTestData(data)
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	t.Run("root", func(t *testing.T) {
+		dirs, err := b.sub(".").SubDirs(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a b", "a", "d", "many"}, dirs)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		dirs, err := b.sub("a/").SubDirs(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b", "empty"}, dirs)
+
+		dirs, err = b.sub("a/b").SubDirs(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"c"}, dirs)
+
+		dirs, err = b.sub("a b/").SubDirs(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"%20"}, dirs)
+	})
+
+	t.Run("leaf", func(t *testing.T) {
+		dirs, err := b.sub("a/b/c/").SubDirs(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, dirs)
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		dirs, err := b.sub("many/").SubDirs(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, dirs, 1005) {
+			assert.Equal(t, "0000", dirs[0])
+			assert.Equal(t, "1004", dirs[1004])
+		}
+	})
+}
+
+func TestPrefix_ListV1(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+	mockServer.DisableListV2(true)
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	data := map[string][]byte{
+		"top.txt":      []byte("0"),
+		"a/1.txt":      []byte("1"),
+		"a/2 + 2.txt":  []byte("2"),
+		"a/b/3.txt":    []byte("3"),
+		"d/e/f/4.txt":  []byte("4"),
+		"many/x.txt":   []byte("5"),
+		"many/y/z.txt": []byte("6"),
+	}
+	for i := 0; i < 1005; i++ {
+		data[fmt.Sprintf("many/%04d/x.txt", i)] = []byte("x")
+	}
+	mockServer.PopulateTestData(data)
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	t.Run("read dir", func(t *testing.T) {
+		entries, err := fs.ReadDir(b, "a")
+		assert.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		assert.Equal(t, []string{"1.txt", "2 + 2.txt", "b"}, names)
+	})
+
+	t.Run("pages with delimiter", func(t *testing.T) {
+		dirs, err := b.sub("many/").SubDirs(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, dirs, 1006) {
+			assert.Equal(t, "0000", dirs[0])
+			assert.Equal(t, "y", dirs[1005])
+		}
+	})
+
+	t.Run("pages without delimiter", func(t *testing.T) {
+		var keys []string
+		opts := ListOptions{MaxKeys: 2}
+		for {
+			ret, err := b.sub("a/").ListWith(ctx, opts)
+			assert.NoError(t, err)
+			for _, f := range ret.Contents {
+				keys = append(keys, f.Path())
+			}
+			if !ret.IsTruncated {
+				break
+			}
+			assert.NotEmpty(t, ret.NextToken)
+			opts.ContinuationToken = ret.NextToken
+		}
+		assert.Equal(t, []string{"a/1.txt", "a/2 + 2.txt", "a/b/3.txt"}, keys)
+	})
+
+	t.Run("start after", func(t *testing.T) {
+		ret, err := b.sub("a/").ListWith(ctx, ListOptions{StartAfter: "1.txt"})
+		assert.NoError(t, err)
+		assert.Len(t, ret.Contents, 2)
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		var files int
+		err := fs.WalkDir(b, ".", func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				files++
+			}
+			return err
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), files)
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		fresh := NewBucket(key, bucket)
+		assert.NoError(t, fresh.Ping(ctx))
+		assert.NoError(t, fresh.Ping(ctx))
+		_, err := fresh.sub("a/").ListWith(ctx, ListOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("token is not a marker", func(t *testing.T) {
+		// a V2 continuation token cannot resume a V1 listing
+		fresh := NewBucket(key, bucket)
+		before := len(mockServer.GetRequestLog())
+		_, err := fresh.sub("a/").ListWith(ctx, ListOptions{ContinuationToken: "opaque"})
+		assert.Error(t, err)
+		assert.False(t, fresh.listV1.Load())
+		for _, req := range mockServer.GetRequestLog()[before:] {
+			assert.NotContains(t, req.Query, "marker=")
+		}
+	})
+
+	// the listings were retried with V1, paging with a marker,
+	// and only the first request of each bucket tried V2
+	var v1, v2, markers int
+	for _, req := range mockServer.GetRequestsWithMethod("GET") {
+		if strings.Contains(req.Query, "list-type=2") {
+			v2++
+			continue
+		}
+		v1++
+		if strings.Contains(req.Query, "marker=") {
+			markers++
+		}
+		assert.NotContains(t, req.Query, "continuation-token")
+	}
+	assert.NotZero(t, v1)
+	assert.NotZero(t, markers)
+	assert.Equal(t, 3, v2)
+}
+
+func TestListV2Unsupported(t *testing.T) {
+	cases := []struct {
+		name string
+		err  Error
+		want bool
+	}{
+		{"not implemented", Error{StatusCode: http.StatusNotImplemented}, true},
+		{"bad request not implemented", Error{StatusCode: http.StatusBadRequest, Code: "NotImplemented"}, true},
+		{"list-type rejected", Error{StatusCode: http.StatusBadRequest, Code: "InvalidArgument", Message: "Invalid list-type value"}, true},
+		{"bad token", Error{StatusCode: http.StatusBadRequest, Code: "InvalidArgument", Message: "The continuation token provided is incorrect"}, false},
+		{"bad max-keys", Error{StatusCode: http.StatusBadRequest, Code: "InvalidArgument", Message: "Provided max-keys not an integer or within integer range"}, false},
+		{"forbidden", Error{StatusCode: http.StatusForbidden, Code: "AccessDenied"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, listV2Unsupported(&c.err))
+		})
+	}
+}
+
+func TestPrefix_StrictETag(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+	list := func(t *testing.T, strict bool) *File {
+		mockServer.PopulateTestData(map[string][]byte{"dir/file.txt": []byte("listed")})
+		p := b.sub("dir/")
+		p.StrictETag = strict
+		entries, err := p.ReadDir(-1)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		f := entries[0].(*File)
+		assert.NotEmpty(t, f.ETag)
+		assert.Equal(t, strict, f.StrictETag)
+
+		// modify the object after it was listed
+		_, err = b.Write(ctx, "dir/file.txt", []byte("modified"))
+		assert.NoError(t, err)
+		return f
+	}
+
+	t.Run("read", func(t *testing.T) {
+		_, err := io.ReadAll(list(t, true))
+		assert.ErrorIs(t, err, ErrETagChanged)
+	})
+
+	t.Run("write to", func(t *testing.T) {
+		var buf strings.Builder
+		_, err := list(t, true).WriteTo(&buf)
+		assert.ErrorIs(t, err, ErrETagChanged)
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("not strict", func(t *testing.T) {
+		var buf strings.Builder
+		_, err := list(t, false).WriteTo(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "modified", buf.String())
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		mockServer.PopulateTestData(map[string][]byte{"dir/file.txt": []byte("listed")})
+		p := b.sub("dir/")
+		p.StrictETag = true
+		entries, err := p.ReadDir(-1)
+		assert.NoError(t, err)
+		var buf strings.Builder
+		_, err = entries[0].(*File).WriteTo(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "listed", buf.String())
+	})
+}
+
+func TestPrefix_Walk(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	data := map[string][]byte{
+		"top.txt":           []byte("0"),
+		"a/1.txt":           []byte("11"),
+		"a/b/2.txt":         []byte("222"),
+		"a/b/c/3.txt":       []byte("3333"),
+		"a/b/c/d/e/4.txt":   []byte("44444"),
+		"a/empty/":          nil,
+		"a/b/c/d/e/f/5.txt": []byte("555555"),
+		"x/6.txt":           []byte("6666666"),
+	}
+	for i := 0; i < 1005; i++ {
+		data[fmt.Sprintf("a/many/%04d/x.txt", i)] = []byte("x")
+	}
+	mockServer.PopulateTestData(data)
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	t.Run("all", func(t *testing.T) {
+		seen := make(map[string]int64)
+		var last string
+		for entry, err := range b.sub("a/").Walk(ctx) {
+			assert.NoError(t, err)
+			assert.Greater(t, entry.Path, last)
+			assert.False(t, entry.ModTime.IsZero())
+			assert.NotEmpty(t, entry.ETag)
+			seen[entry.Path] = entry.Size
+			last = entry.Path
+		}
+
+		want := make(map[string]int64)
+		for k, v := range data {
+			if strings.HasPrefix(k, "a/") && !strings.HasSuffix(k, "/") {
+				want[k] = int64(len(v))
+			}
+		}
+		assert.Equal(t, want, seen)
+	})
+
+	t.Run("root", func(t *testing.T) {
+		var n int
+		for _, err := range b.sub(".").Walk(ctx) {
+			assert.NoError(t, err)
+			n++
+		}
+		assert.Equal(t, len(data)-1, n)
+	})
+
+	t.Run("break", func(t *testing.T) {
+		before := len(mockServer.GetRequestsWithMethod("GET"))
+		var n int
+		for range b.sub("a/").Walk(ctx) {
+			if n++; n == 3 {
+				break
+			}
+		}
+		assert.Equal(t, 3, n)
+		assert.Equal(t, 1, len(mockServer.GetRequestsWithMethod("GET"))-before)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mockServer.EnableErrorSimulation(mock.ErrorSimulation{PermissionErrors: true})
+		defer mockServer.DisableErrorSimulation()
+		var errs int
+		for _, err := range b.sub("a/").Walk(ctx) {
+			assert.ErrorIs(t, err, fs.ErrPermission)
+			errs++
+		}
+		assert.Equal(t, 1, errs)
+	})
+}
+
+func TestPrefix_ReadDirLarge(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	data := make(map[string][]byte, 3000)
+	for i := range 3000 {
+		data[fmt.Sprintf("big/file%04d.txt", i)] = []byte("x")
+	}
+	mockServer.PopulateTestData(data)
+
+	b := NewBucket(key, bucket)
+
+	// maxKeysOf returns the max-keys parameter of each list request
+	maxKeysOf := func(requests []mock.RequestLog) (out []string) {
+		for _, req := range requests {
+			if q, err := url.ParseQuery(req.Query); err == nil && q.Has("list-type") {
+				out = append(out, q.Get("max-keys"))
+			}
+		}
+		return out
+	}
+
+	t.Run("paged", func(t *testing.T) {
+		f, err := b.Open("big/")
+		assert.NoError(t, err)
+		defer f.Close()
+
+		before := len(mockServer.GetRequestLog())
+		entries, err := f.(fs.ReadDirFile).ReadDir(2500)
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 2500) {
+			assert.Equal(t, "file0000.txt", entries[0].Name())
+			assert.Equal(t, "file2499.txt", entries[2499].Name())
+		}
+		assert.Equal(t, []string{"1000", "1000", "500"}, maxKeysOf(mockServer.GetRequestLog()[before:]))
+
+		// the remaining entries continue where the previous call left off
+		entries, err = f.(fs.ReadDirFile).ReadDir(2500)
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 500) {
+			assert.Equal(t, "file2500.txt", entries[0].Name())
+		}
+		_, err = f.(fs.ReadDirFile).ReadDir(2500)
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("clamped", func(t *testing.T) {
+		before := len(mockServer.GetRequestLog())
+		ret, err := b.sub("big/").ListWith(context.Background(), ListOptions{MaxKeys: 5000})
+		assert.NoError(t, err)
+		assert.Len(t, ret.Contents, 1000)
+		assert.True(t, ret.IsTruncated)
+		assert.Equal(t, []string{"1000"}, maxKeysOf(mockServer.GetRequestLog()[before:]))
+	})
+}
+
+func TestPrefix_OpenFile(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	mockServer.PopulateTestData(map[string][]byte{
+		"data/file.txt":       []byte("file"),
+		"data/file.txtx":      []byte("sibling"),
+		"data/sub/nested.txt": []byte("nested"),
+		"data/both":           []byte("object"),
+		"data/both/inner.txt": []byte("inner"),
+	})
+
+	b := NewBucket(key, bucket)
+	dir, err := b.Sub("data")
+	assert.NoError(t, err)
+
+	// lists counts the list requests made since the given request
+	lists := func(since int) (n int) {
+		for _, req := range mockServer.GetRequestLog()[since:] {
+			if strings.Contains(req.Query, "list-type") {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("file", func(t *testing.T) {
+		before := len(mockServer.GetRequestLog())
+		f, err := dir.Open("file.txt")
+		assert.NoError(t, err)
+		defer f.Close()
+
+		assert.IsType(t, &File{}, f)
+		assert.Equal(t, "data/file.txt", f.(*File).Path())
+		body, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, "file", string(body))
+
+		requests := mockServer.GetRequestLog()[before:]
+		if assert.Len(t, requests, 1) {
+			assert.Equal(t, "GET", requests[0].Method)
+			assert.Equal(t, "/test-bucket/data/file.txt", requests[0].Path)
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		before := len(mockServer.GetRequestLog())
+		f, err := dir.Open("sub")
+		assert.NoError(t, err)
+		assert.IsType(t, &Prefix{}, f)
+		assert.Equal(t, 1, lists(before))
+
+		before = len(mockServer.GetRequestLog())
+		f, err = dir.Open("sub/")
+		assert.NoError(t, err)
+		assert.IsType(t, &Prefix{}, f)
+		assert.Len(t, mockServer.GetRequestLog()[before:], 1)
+		assert.Equal(t, 1, lists(before))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		// the sibling "file.txtx" must not make "file.txt" look like a directory
+		_, err := dir.Open("file.txt/")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = dir.Open("missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("shadowed", func(t *testing.T) {
+		// the object is preferred over the prefix
+		// of the same name, as with Bucket.Open
+		before := len(mockServer.GetRequestLog())
+		f, err := dir.Open("both")
+		assert.NoError(t, err)
+		assert.IsType(t, &File{}, f)
+		assert.Zero(t, lists(before))
+		f.Close()
+
+		f, err = dir.Open("both/")
+		assert.NoError(t, err)
+		assert.IsType(t, &Prefix{}, f)
+
+		// walking still descends into the prefix
+		var got []string
+		err = fsutil.WalkGlob(dir, "", "both/*", func(p string, f fs.File, err error) error {
+			assert.NoError(t, err)
+			f.Close()
+			got = append(got, p)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"both/inner.txt"}, got)
+	})
+}
+
+func TestPrefix_ReadDirEOF(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+
+	// readAll pages through dir with ReadDir(n) until an error
+	readAll := func(t *testing.T, dir string, n int) (pages [][]string, err error) {
+		f, err := b.Open(dir)
+		assert.NoError(t, err)
+		defer f.Close()
+		for {
+			entries, err := f.(fs.ReadDirFile).ReadDir(n)
+			if err != nil {
+				assert.Empty(t, entries)
+				return pages, err
+			}
+			if !assert.NotEmpty(t, entries) || !assert.LessOrEqual(t, len(entries), n) {
+				return pages, nil
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			pages = append(pages, names)
+		}
+	}
+
+	t.Run("exhaust", func(t *testing.T) {
+		for i := range 7 {
+			mockServer.PutObject(fmt.Sprintf("seven/file%d.txt", i), []byte("x"))
+		}
+		pages, err := readAll(t, "seven", 3)
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, [][]string{
+			{"file0.txt", "file1.txt", "file2.txt"},
+			{"file3.txt", "file4.txt", "file5.txt"},
+			{"file6.txt"},
+		}, pages)
+	})
+
+	t.Run("exact", func(t *testing.T) {
+		for i := range 6 {
+			mockServer.PutObject(fmt.Sprintf("six/file%d.txt", i), []byte("x"))
+		}
+		pages, err := readAll(t, "six", 3)
+		assert.Equal(t, io.EOF, err)
+		assert.Len(t, pages, 2)
+	})
+
+	t.Run("repeated", func(t *testing.T) {
+		f, err := b.Open("six")
+		assert.NoError(t, err)
+		defer f.Close()
+		dir := f.(fs.ReadDirFile)
+		entries, err := dir.ReadDir(10)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 6)
+		for range 2 {
+			entries, err = dir.ReadDir(3)
+			assert.Nil(t, entries)
+			assert.Equal(t, io.EOF, err)
+		}
+	})
+
+	t.Run("marker", func(t *testing.T) {
+		// the first page holds only the directory marker
+		mockServer.PutObject("marked/", nil)
+		mockServer.PutObject("marked/a.txt", []byte("a"))
+		mockServer.PutObject("marked/b.txt", []byte("b"))
+		pages, err := readAll(t, "marked", 1)
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, [][]string{{"a.txt"}, {"b.txt"}}, pages)
+	})
+}
+
+func TestPrefix_EncodedKeys(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	b := NewBucket(key, bucket)
+
+	mockServer.PopulateTestData(map[string][]byte{
+		"odd/a b.txt":     []byte("space"),
+		"odd/c+d.txt":     []byte("plus"),
+		"odd/e%20f.txt":   []byte("percent"),
+		"odd/sub dir/g":   []byte("nested"),
+		"odd/x+y z/h.txt": []byte("nested"),
+	})
+
+	t.Run("read dir", func(t *testing.T) {
+		entries, err := b.ReadDir("odd")
+		assert.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		assert.Equal(t, []string{"a b.txt", "c+d.txt", "e%20f.txt", "sub dir", "x+y z"}, names)
+
+		// the listing is requested URL-encoded
+		lists := mockServer.GetRequestsWithMethod("GET")
+		if assert.NotEmpty(t, lists) {
+			q, err := url.ParseQuery(lists[len(lists)-1].Query)
+			assert.NoError(t, err)
+			assert.Equal(t, "url", q.Get("encoding-type"))
+		}
+
+		// decoded entries can be opened
+		data, err := fs.ReadFile(b, "odd/c+d.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "plus", string(data))
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		var files []string
+		err := fs.WalkDir(b, "odd", func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				files = append(files, path)
+			}
+			return err
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"odd/a b.txt", "odd/c+d.txt", "odd/e%20f.txt", "odd/sub dir/g", "odd/x+y z/h.txt",
+		}, files)
+	})
+
+	t.Run("list with", func(t *testing.T) {
+		ret, err := b.sub("odd/").ListWith(context.Background(), ListOptions{Delimiter: "/"})
+		assert.NoError(t, err)
+		var keys []string
+		for i := range ret.Contents {
+			keys = append(keys, ret.Contents[i].Path())
+		}
+		assert.Equal(t, []string{"odd/a b.txt", "odd/c+d.txt", "odd/e%20f.txt"}, keys)
+		assert.Equal(t, []string{"odd/sub dir/", "odd/x+y z/"}, ret.CommonPrefixes)
+	})
+}
+
+func TestPrefix_ReadDirLimit(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	data := make(map[string][]byte, 3000)
+	for i := 0; i < 3000; i++ {
+		data[fmt.Sprintf("many/%04d.txt", i)] = []byte("x")
+	}
+	data["small/a.txt"] = []byte("a")
+	data["small/b/c.txt"] = []byte("c")
+	mockServer.PopulateTestData(data)
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	// maxKeys returns the max-keys of the list
+	// requests made since the log had n entries
+	maxKeys := func(n int) (out []string) {
+		for _, req := range mockServer.GetRequestLog()[n:] {
+			if q, err := url.ParseQuery(req.Query); err == nil && q.Has("list-type") {
+				out = append(out, q.Get("max-keys"))
+			}
+		}
+		return out
+	}
+	open := func(name string) *Prefix {
+		f, err := b.Open(name)
+		assert.NoError(t, err)
+		dir, ok := f.(*Prefix)
+		assert.True(t, ok)
+		return dir
+	}
+
+	t.Run("capped", func(t *testing.T) {
+		dir := open("many")
+
+		n := len(mockServer.GetRequestLog())
+		entries, err := dir.ReadDirLimit(ctx, 1500)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1500)
+		assert.Equal(t, "0000.txt", entries[0].Name())
+		assert.Equal(t, "1499.txt", entries[1499].Name())
+		assert.Equal(t, []string{"1000", "500"}, maxKeys(n))
+
+		// the position of ReadDir is unchanged
+		entries, err = dir.ReadDir(10)
+		assert.NoError(t, err)
+		assert.Equal(t, "0000.txt", entries[0].Name())
+	})
+
+	t.Run("fewer", func(t *testing.T) {
+		dir := open("small")
+
+		entries, err := dir.ReadDirLimit(ctx, 1500)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+
+		entries, err = dir.ReadDirLimit(ctx, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
@@ -0,0 +1,282 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// rangeParallelism is the number of single-range
+// requests made concurrently by ReadRanges.
+const rangeParallelism = 8
+
+// Range is a byte range of an object.
+type Range struct {
+	Offset int64 // Offset is the first byte of the range.
+	Length int64 // Length is the number of bytes in the range.
+}
+
+func (r Range) end() int64 { return r.Offset + r.Length }
+
+// SplitRanges splits [0, size) into consecutive ranges of partSize
+// bytes, the last of which holds the remainder and may be shorter.
+// It returns no ranges if size is not positive, and panics if
+// partSize is not positive.
+func SplitRanges(size, partSize int64) []Range {
+	if partSize <= 0 {
+		panic("s3.SplitRanges: part size must be positive")
+	}
+	if size <= 0 {
+		return nil
+	}
+	ranges := make([]Range, 0, (size+partSize-1)/partSize)
+	for off := int64(0); off < size; off += partSize {
+		ranges = append(ranges, Range{Offset: off, Length: min(partSize, size-off)})
+	}
+	return ranges
+}
+
+// SplitParts splits [0, size) in the same way as SplitRanges, but
+// doubles minPartSize as many times as needed to split the object into
+// at most MaxParts ranges, which is the part size used by multipart uploads.
+// It panics if minPartSize is not positive.
+func SplitParts(size, minPartSize int64) []Range {
+	if minPartSize <= 0 {
+		panic("s3.SplitParts: part size must be positive")
+	}
+	return SplitRanges(size, calculatePartSize(size, minPartSize))
+}
+
+// span is a contiguous range that is fetched
+// with a single request, covering one or more
+// of the requested ranges.
+type span struct {
+	Range
+	data []byte
+}
+
+// coalesce merges overlapping and adjacent ranges
+// into as few spans as possible, sorted by offset.
+func coalesce(ranges []Range) []*span {
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b Range) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+	var spans []*span
+	for _, rg := range sorted {
+		if rg.Length == 0 {
+			continue
+		}
+		if n := len(spans); n > 0 && rg.Offset <= spans[n-1].end() {
+			last := spans[n-1]
+			last.Length = max(last.end(), rg.end()) - last.Offset
+			continue
+		}
+		spans = append(spans, &span{Range: rg})
+	}
+	return spans
+}
+
+// ReadRanges reads each of the byte ranges of the object and
+// returns their contents in the same order as ranges.
+//
+// Overlapping and adjacent ranges are coalesced so that they
+// are fetched with a single request, and the returned slices
+// of such ranges may share memory. If r.MultiRange is set and
+// more than one request is needed, ReadRanges first asks for
+// all of the ranges in one multipart/byteranges request; if the
+// server does not support this, or r.MultiRange is not set,
+// the ranges are fetched with concurrent single-range requests.
+func (r *Reader) ReadRanges(ctx context.Context, ranges []Range) ([][]byte, error) {
+	for _, rg := range ranges {
+		if rg.Offset < 0 || rg.Length < 0 || (r.Size > 0 && rg.end() > r.Size) {
+			return nil, fmt.Errorf("s3.Reader.ReadRanges: invalid range [%d, %d)", rg.Offset, rg.end())
+		}
+	}
+
+	spans := coalesce(ranges)
+	fetched := false
+	if r.MultiRange && len(spans) > 1 {
+		var err error
+		if fetched, err = r.readMultiRange(ctx, spans); err != nil {
+			return nil, err
+		}
+	}
+	if !fetched {
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(rangeParallelism)
+		for _, s := range spans {
+			g.Go(func() error {
+				body, err := r.rangeReader(gctx, s.Offset, s.Length)
+				if err != nil {
+					return err
+				}
+				defer body.Close()
+				s.data = make([]byte, s.Length)
+				_, err = io.ReadFull(body, s.data)
+				return err
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([][]byte, len(ranges))
+	for i, rg := range ranges {
+		if rg.Length == 0 {
+			out[i] = []byte{}
+			continue
+		}
+		j, _ := slices.BinarySearchFunc(spans, rg.Offset, func(s *span, off int64) int {
+			switch {
+			case s.end() <= off:
+				return -1
+			case s.Offset > off:
+				return 1
+			}
+			return 0
+		})
+		s := spans[j]
+		out[i] = s.data[rg.Offset-s.Offset : rg.end()-s.Offset : rg.end()-s.Offset]
+	}
+	return out, nil
+}
+
+// RangeTo is a byte range of an object
+// and the writer its contents are copied to.
+type RangeTo struct {
+	Range
+	W io.Writer // W receives the contents of the range.
+}
+
+// ReadRangesTo copies each of the byte ranges of the object to
+// its writer, with concurrent single-range requests, e.g. to
+// extract several members of an archive at once. The writers
+// are written to concurrently, so they must be distinct or safe
+// for concurrent use.
+//
+// ReadRangesTo returns the first error, from a request or a
+// writer, and cancels the requests still in flight. The writers
+// of the ranges that were not read in full may then have been
+// written to partially. A range that ends before its length
+// fails with an error matching io.ErrUnexpectedEOF.
+func (r *Reader) ReadRangesTo(ctx context.Context, reqs []RangeTo) error {
+	for _, req := range reqs {
+		if req.Offset < 0 || req.Length < 0 || (r.Size > 0 && req.end() > r.Size) {
+			return fmt.Errorf("s3.Reader.ReadRangesTo: invalid range [%d, %d)", req.Offset, req.end())
+		}
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(rangeParallelism)
+	for _, req := range reqs {
+		if req.Length == 0 {
+			continue
+		}
+		g.Go(func() error {
+			body, err := r.rangeReader(gctx, req.Offset, req.Length)
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+			n, err := io.CopyN(req.W, body, req.Length)
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("s3: reading %s: range [%d, %d) ended after %d bytes: %w", r.Path, req.Offset, req.end(), n, io.ErrUnexpectedEOF)
+			}
+			return err
+		})
+	}
+	return g.Wait()
+}
+
+// readMultiRange fetches all of the spans with a single
+// multipart/byteranges request. It returns false without
+// an error if the server did not honor the request.
+func (r *Reader) readMultiRange(ctx context.Context, spans []*span) (bool, error) {
+	specs := make([]string, len(spans))
+	for i, s := range spans {
+		specs[i] = fmt.Sprintf("%d-%d", s.Offset, s.end()-1)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri(r.Key, r.Bucket, r.Path), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Range", "bytes="+strings.Join(specs, ","))
+	if r.ETag != "" {
+		req.Header.Set("If-Match", r.ETag)
+	}
+	setUserAgent(req, r.UserAgent)
+	r.Key.SignV4(req, nil)
+
+	res, err := flakyDo(r.Client, r.Limiter, r.Logger, r.stats, req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusPreconditionFailed:
+		return false, ErrETagChanged
+	case http.StatusPartialContent:
+		// okay; check that it is multipart below
+	default:
+		return false, nil
+	}
+	media, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil || media != "multipart/byteranges" {
+		return false, nil
+	}
+
+	mr := multipart.NewReader(budgeted(r.ReadBudget, res.Body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return false, fmt.Errorf("s3.Reader.ReadRanges: %w", err)
+		}
+		var start, end int64
+		if _, err := fmt.Sscanf(part.Header.Get("Content-Range"), "bytes %d-%d/", &start, &end); err != nil {
+			return false, fmt.Errorf("s3.Reader.ReadRanges: bad Content-Range %q", part.Header.Get("Content-Range"))
+		}
+		i := slices.IndexFunc(spans, func(s *span) bool {
+			return s.Offset == start && s.end() == end+1
+		})
+		if i < 0 {
+			return false, fmt.Errorf("s3.Reader.ReadRanges: unexpected range %d-%d", start, end)
+		}
+		spans[i].data = make([]byte, spans[i].Length)
+		if _, err := io.ReadFull(part, spans[i].data); err != nil {
+			return false, err
+		}
+	}
+	for _, s := range spans {
+		if s.data == nil {
+			return false, fmt.Errorf("s3.Reader.ReadRanges: missing range %d-%d", s.Offset, s.end()-1)
+		}
+	}
+	return true, nil
+}
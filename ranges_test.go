@@ -0,0 +1,232 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"math/rand"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRanges(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	mockServer.PutObject("test/columns.bin", data)
+
+	b := NewBucket(key, bucket)
+	b.Lazy = true
+	ctx := context.Background()
+
+	ranges := []Range{
+		{Offset: 900, Length: 50},
+		{Offset: 0, Length: 10},
+		{Offset: 10, Length: 10}, // adjacent
+		{Offset: 15, Length: 15}, // overlapping
+		{Offset: 500, Length: 20},
+		{Offset: 100, Length: 0},
+	}
+	check := func(t *testing.T, out [][]byte) {
+		assert.Len(t, out, len(ranges))
+		for i, rg := range ranges {
+			assert.Equal(t, data[rg.Offset:rg.Offset+rg.Length], out[i])
+		}
+	}
+	gets := func() int { return len(mockServer.GetRequestsWithMethod("GET")) }
+
+	t.Run("single range", func(t *testing.T) {
+		f, err := b.Open("test/columns.bin")
+		assert.NoError(t, err)
+
+		before := gets()
+		out, err := f.(*File).ReadRanges(ctx, ranges)
+		assert.NoError(t, err)
+		check(t, out)
+		assert.Equal(t, 3, gets()-before)
+	})
+
+	t.Run("multi range", func(t *testing.T) {
+		f, err := b.Open("test/columns.bin")
+		assert.NoError(t, err)
+		f.(*File).MultiRange = true
+
+		before := gets()
+		out, err := f.(*File).ReadRanges(ctx, ranges)
+		assert.NoError(t, err)
+		check(t, out)
+		assert.Equal(t, 1, gets()-before)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		f, err := b.Open("test/columns.bin")
+		assert.NoError(t, err)
+		_, err = f.(*File).ReadRanges(ctx, []Range{{Offset: 990, Length: 20}})
+		assert.Error(t, err)
+	})
+}
+
+func TestReadRangesTo(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	mockServer.PutObject("test/archive.bin", data)
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+	f, err := b.Open("test/archive.bin")
+	assert.NoError(t, err)
+	r := &f.(*File).Reader
+
+	t.Run("extract", func(t *testing.T) {
+		var bufs [3]bytes.Buffer
+		reqs := []RangeTo{
+			{Range: Range{Offset: 0, Length: 100}, W: &bufs[0]},
+			{Range: Range{Offset: 400, Length: 250}, W: &bufs[1]},
+			{Range: Range{Offset: 950, Length: 50}, W: &bufs[2]},
+			{Range: Range{Offset: 10, Length: 0}, W: failWriter{}}, // not written to
+		}
+		assert.NoError(t, r.ReadRangesTo(ctx, reqs))
+		for i := range bufs {
+			assert.Equal(t, data[reqs[i].Offset:reqs[i].end()], bufs[i].Bytes(), "range %d", i)
+		}
+	})
+
+	t.Run("writer error", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := r.ReadRangesTo(ctx, []RangeTo{
+			{Range: Range{Offset: 0, Length: 100}, W: &buf},
+			{Range: Range{Offset: 100, Length: 100}, W: failWriter{}},
+		})
+		assert.ErrorIs(t, err, io.ErrShortWrite)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err := r.ReadRangesTo(ctx, []RangeTo{{Range: Range{Offset: 990, Length: 20}, W: io.Discard}})
+		assert.Error(t, err)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		mockServer.DeleteObject("test/archive.bin")
+		defer mockServer.PutObject("test/archive.bin", data)
+		err := r.ReadRangesTo(ctx, []RangeTo{{Range: Range{Offset: 0, Length: 10}, W: io.Discard}})
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
+
+// failWriter fails every write with io.ErrShortWrite.
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, io.ErrShortWrite }
+
+func TestSplitRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int64
+		partSize int64
+		want     []Range
+	}{
+		{name: "zero size", size: 0, partSize: 10, want: nil},
+		{name: "negative size", size: -1, partSize: 10, want: nil},
+		{name: "smaller than a part", size: 7, partSize: 10, want: []Range{{0, 7}}},
+		{name: "exactly one part", size: 10, partSize: 10, want: []Range{{0, 10}}},
+		{name: "exact multiple", size: 30, partSize: 10, want: []Range{{0, 10}, {10, 10}, {20, 10}}},
+		{name: "short final part", size: 25, partSize: 10, want: []Range{{0, 10}, {10, 10}, {20, 5}}},
+		{name: "one byte over", size: 21, partSize: 10, want: []Range{{0, 10}, {10, 10}, {20, 1}}},
+		{name: "one byte parts", size: 3, partSize: 1, want: []Range{{0, 1}, {1, 1}, {2, 1}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, SplitRanges(tc.size, tc.partSize))
+		})
+	}
+
+	t.Run("invalid part size", func(t *testing.T) {
+		assert.Panics(t, func() { SplitRanges(10, 0) })
+		assert.Panics(t, func() { SplitRanges(10, -1) })
+	})
+}
+
+func TestSplitParts(t *testing.T) {
+	const part = MinPartSize
+
+	// ranges cover [0, size) without gaps
+	covers := func(t *testing.T, ranges []Range, size int64) {
+		var off int64
+		for _, rg := range ranges {
+			assert.Equal(t, off, rg.Offset)
+			off += rg.Length
+		}
+		assert.Equal(t, size, off)
+	}
+
+	t.Run("small", func(t *testing.T) {
+		ranges := SplitParts(part*2+1, part)
+		assert.Len(t, ranges, 3)
+		assert.Equal(t, Range{Offset: part * 2, Length: 1}, ranges[2])
+	})
+
+	t.Run("exactly max parts", func(t *testing.T) {
+		size := int64(part) * MaxParts
+		ranges := SplitParts(size, part)
+		assert.Len(t, ranges, MaxParts)
+		assert.Equal(t, int64(part), ranges[0].Length)
+		covers(t, ranges, size)
+	})
+
+	t.Run("final part over max parts", func(t *testing.T) {
+		// one more byte would need a final part beyond
+		// MaxParts, so the part size has to be doubled
+		size := int64(part)*MaxParts + 1
+		ranges := SplitParts(size, part)
+		assert.LessOrEqual(t, len(ranges), MaxParts)
+		assert.Equal(t, int64(part)*2, ranges[0].Length)
+		covers(t, ranges, size)
+	})
+
+	t.Run("large", func(t *testing.T) {
+		size := int64(part)*MaxParts*3 + 12345
+		ranges := SplitParts(size, part)
+		assert.LessOrEqual(t, len(ranges), MaxParts)
+		covers(t, ranges, size)
+	})
+
+	t.Run("zero size", func(t *testing.T) {
+		assert.Empty(t, SplitParts(0, part))
+	})
+
+	t.Run("bad part size", func(t *testing.T) {
+		assert.Panics(t, func() { SplitParts(100, 0) })
+		assert.Panics(t, func() { SplitParts(100, -1) })
+	})
+}
@@ -21,15 +21,23 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kelindar/s3/aws"
@@ -40,6 +48,11 @@ import (
 var DefaultClient = http.Client{
 	Transport: &http.Transport{
 		ResponseHeaderTimeout: 60 * time.Second,
+		// Requests sent with Expect: 100-continue
+		// (see UploadOptions.ExpectContinue) wait
+		// this long for the server before sending
+		// the body anyway, as some never answer.
+		ExpectContinueTimeout: time.Second,
 		// Empirically, AWS creates about 40
 		// DNS entries for S3, so 5 connections
 		// per host is about 100 total connections.
@@ -59,6 +72,10 @@ var DefaultClient = http.Client{
 	},
 }
 
+// DefaultUserAgent is the User-Agent header
+// sent with requests when none has been configured.
+const DefaultUserAgent = "kelindar-s3/1.0"
+
 var (
 	// ErrInvalidBucket is returned from calls that attempt
 	// to use a bucket name that isn't valid according to
@@ -70,6 +87,26 @@ var (
 	// that file read operations are always consistent with respect
 	// to the ETag originally associated with the file handle.)
 	ErrETagChanged = errors.New("file ETag changed")
+	// ErrChecksumMismatch is returned from read operations
+	// on a Reader with Verify set when the digest of the
+	// bytes read does not match the ETag of the object.
+	ErrChecksumMismatch = errors.New("object checksum mismatch")
+	// ErrPreconditionFailed is returned from conditional
+	// operations when the condition does not hold.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrRangeUnsupported is returned from range reads
+	// when the server ignores the requested byte range
+	// and responds with the full object instead.
+	ErrRangeUnsupported = errors.New("range requests not supported")
+	// ErrThrottled is matched by errors from requests that
+	// S3 rejected because the request rate was too high,
+	// i.e. a 503 SlowDown or a 429 response. Callers can
+	// use it to back off across all of their requests.
+	ErrThrottled = errors.New("request rate throttled")
+	// ErrUnreachable is matched by errors from Bucket.Ping
+	// when no response could be obtained from S3, e.g.
+	// because of a DNS, connection or TLS failure.
+	ErrUnreachable = errors.New("s3 unreachable")
 )
 
 func badBucket(name string) error {
@@ -133,16 +170,255 @@ type Reader struct {
 	// Size is the object size in bytes.
 	// It is populated on Open.
 	Size int64 `xml:"Size"`
+	// StorageClass is the storage class of the object
+	// as returned by listing or a HEAD operation.
+	StorageClass string `xml:"StorageClass"`
+	// Owner is the owner of the object, which is only
+	// returned by listings that set ListOptions.FetchOwner.
+	// It is preserved, but not populated, on Open.
+	Owner *Grantee `xml:"Owner"`
 	// Bucket is the S3 bucket holding the object.
 	Bucket string `xml:"-"`
 	// Path is the S3 object key.
 	Path string `xml:"Key"`
+	// UserAgent is the User-Agent header sent
+	// with every request. If it is empty,
+	// DefaultUserAgent is used instead.
+	UserAgent string `xml:"-"`
+	// Limiter, if not nil, limits the rate
+	// of requests made by the Reader.
+	Limiter Limiter `xml:"-"`
+	// Logger, if not nil, logs every request
+	// made by the Reader at debug level.
+	Logger *slog.Logger `xml:"-"`
+	// ReadBudget, if not nil, is the number of bytes
+	// of object contents that may still be read. It is
+	// decremented atomically by reads, which fail with
+	// ErrBudgetExceeded once it is exhausted.
+	ReadBudget *int64 `xml:"-"`
+	// ErrorMapper, if not nil, maps the status code
+	// and S3 error code of an unsuccessful response
+	// to Open or Stat to the error returned, as for
+	// Bucket.ErrorMapper.
+	ErrorMapper ErrorMapper `xml:"-"`
+	// Verify, if set, causes reads of the entire
+	// object to compute an MD5 digest of the contents
+	// and compare it against the ETag once the end of
+	// the object is reached. Objects whose ETag is not
+	// a plain MD5 (e.g. multipart uploads, or objects
+	// encrypted with SSE-KMS or SSE-C) are not verified.
+	Verify bool `xml:"-"`
+	// StrictETag, if set, makes every read of the object
+	// send If-Match with ETag, so that reads fail with
+	// ErrETagChanged once the object has been replaced.
+	// Ranged reads always do so when ETag is set, but
+	// WriteTo otherwise reads the current contents.
+	StrictETag bool `xml:"-"`
+	// MultiRange, if set, lets ReadRanges fetch
+	// several byte ranges with one multipart/byteranges
+	// request. S3 itself does not support such requests,
+	// but some S3-compatible backends and caches do.
+	MultiRange bool `xml:"-"`
+	// BucketKey reports whether the object is
+	// encrypted using an S3 Bucket Key. It is
+	// populated on Open.
+	BucketKey bool `xml:"-"`
+	// Encryption is the server-side encryption
+	// algorithm of the object, e.g. "AES256" or
+	// "aws:kms", and SSECustomerAlgorithm is the
+	// algorithm of its customer-provided key, if
+	// it is encrypted with SSE-C. The ETags of
+	// objects encrypted with SSE-KMS or SSE-C are
+	// not MD5 digests, so they are not verified.
+	// Both are populated on Open.
+	Encryption           string `xml:"-"`
+	SSECustomerAlgorithm string `xml:"-"`
+	// ContentType, CacheControl and ContentDisposition
+	// are the Content-Type, Cache-Control and
+	// Content-Disposition headers of the object.
+	// They are populated on Open.
+	ContentType        string `xml:"-"`
+	CacheControl       string `xml:"-"`
+	ContentDisposition string `xml:"-"`
+	// Expires is the Expires header of the
+	// object, if any. It is populated on Open.
+	Expires time.Time `xml:"-"`
+	// Expiration is the scheduled expiration of the
+	// object by a lifecycle rule (x-amz-expiration),
+	// or nil if none applies. It is populated on Open.
+	Expiration *Expiration `xml:"-"`
+	// Headers holds the standard headers of the object
+	// that are set, including those without a dedicated
+	// field such as Content-Language, Content-Encoding and
+	// X-Amz-Website-Redirect-Location. It is populated on Open.
+	Headers http.Header `xml:"-"`
+
+	ctx   context.Context // bound by WithContext, if not nil
+	stats *counters       // counters of the Bucket, if any
+}
+
+// WithContext returns a copy of r whose RangeReader, ReadAt and
+// WriteTo make their requests with ctx, so that cancelling ctx
+// aborts all of the reads in flight, including the bodies that
+// are still being read. The provided ctx must be non-nil.
+func (r *Reader) WithContext(ctx context.Context) *Reader {
+	if ctx == nil {
+		panic("s3.Reader.WithContext: nil context")
+	}
+	r2 := *r
+	r2.ctx = ctx
+	return &r2
+}
+
+// Clone returns a copy of r for use in another goroutine.
+// The copy refers to the same object (key, bucket, path,
+// ETag and size) and binds the same context, but shares
+// no mutable state with r, so that it can be updated, e.g.
+// by Stat, without affecting r.
+//
+// To read an object in parallel, give each goroutine its
+// own clone and have it use ReadAt or RangeReader over its
+// share of the object. The ETag keeps every read consistent:
+// if the object is replaced, reads fail with ErrETagChanged.
+func (r *Reader) Clone() *Reader {
+	r2 := *r
+	r2.Headers = r.Headers.Clone()
+	if r.Expiration != nil {
+		exp := *r.Expiration
+		r2.Expiration = &exp
+	}
+	if r.Owner != nil {
+		owner := *r.Owner
+		r2.Owner = &owner
+	}
+	return &r2
+}
+
+// context returns the context bound
+// by WithContext, if any.
+func (r *Reader) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// Expiration is the expiration of an object
+// scheduled by a bucket lifecycle rule.
+type Expiration struct {
+	Date   time.Time // Date is the time at which the object expires.
+	RuleID string    // RuleID is the ID of the lifecycle rule expiring the object.
+}
+
+// parseExpiration parses an x-amz-expiration header such as
+// expiry-date="Fri, 23 Dec 2012 00:00:00 GMT", rule-id="rule".
+func parseExpiration(h string) *Expiration {
+	var e Expiration
+	for h != "" {
+		name, rest, ok := strings.Cut(strings.TrimLeft(h, ", "), `="`)
+		if !ok {
+			break
+		}
+		value, rest, ok := strings.Cut(rest, `"`)
+		if !ok {
+			break
+		}
+		switch name {
+		case "expiry-date":
+			e.Date, _ = http.ParseTime(value)
+		case "rule-id":
+			e.RuleID = value
+		}
+		h = rest
+	}
+	if e.Date.IsZero() {
+		return nil
+	}
+	return &e
+}
+
+// bucketKeyEnabled returns whether the response headers
+// indicate that an S3 Bucket Key was used for encryption.
+func bucketKeyEnabled(h http.Header) bool {
+	return h.Get("x-amz-server-side-encryption-bucket-key-enabled") == "true"
+}
+
+// etagDigest returns the MD5 digest encoded
+// in etag, if etag is a single-part ETag.
+func etagDigest(etag string) ([]byte, bool) {
+	sum, err := hex.DecodeString(strings.Trim(etag, `"`))
+	if err != nil || len(sum) != md5.Size {
+		return nil, false
+	}
+	return sum, true
+}
+
+// digestETag reports whether the ETag of an object
+// encrypted with the given server-side encryption
+// and customer key algorithms may be an MD5 digest,
+// which is not the case for SSE-KMS and SSE-C.
+func digestETag(encryption, customer string) bool {
+	return !strings.HasPrefix(encryption, "aws:kms") && customer == ""
+}
+
+// verify checks the digest of the object contents
+// against the ETag of r, if it can be verified.
+func (r *Reader) verify(sum []byte) error {
+	if !digestETag(r.Encryption, r.SSECustomerAlgorithm) {
+		return nil
+	}
+	want, ok := etagDigest(r.ETag)
+	if !ok || bytes.Equal(want, sum) {
+		return nil
+	}
+	return &fs.PathError{Op: "read", Path: r.Path, Err: ErrChecksumMismatch}
+}
+
+// setUserAgent populates the User-Agent header
+// of req, falling back to DefaultUserAgent.
+func setUserAgent(req *http.Request, agent string) {
+	if agent == "" {
+		agent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", agent)
+}
+
+// directoryZone returns the availability zone ID
+// encoded in the name of an S3 Express One Zone
+// directory bucket (bucket-base-name--azid--x-s3).
+func directoryZone(bucket string) (string, bool) {
+	name, ok := strings.CutSuffix(bucket, "--x-s3")
+	if !ok {
+		return "", false
+	}
+	i := strings.LastIndex(name, "--")
+	if i <= 0 || i+2 == len(name) {
+		return "", false
+	}
+	return name[i+2:], true
+}
+
+// DirectoryEndpoint returns the zonal endpoint
+// of an S3 Express One Zone directory bucket,
+// or false if bucket is not a directory bucket name.
+//
+// The endpoint can be passed to aws.ExpressSessions.Key
+// to obtain a session key for the bucket.
+func DirectoryEndpoint(region, bucket string) (string, bool) {
+	zone, ok := directoryZone(bucket)
+	if !ok {
+		return "", false
+	}
+	return "https://" + bucket + ".s3express-" + zone + "." + region + ".amazonaws.com", true
 }
 
 // rawURI produces a URI with a pre-escaped path+query string
 func rawURI(k *aws.SigningKey, bucket string, query string) string {
 	endPoint := k.BaseURI
 	if endPoint == "" {
+		if zonal, ok := DirectoryEndpoint(k.Region, bucket); ok {
+			return zonal + "/" + query
+		}
 		// use virtual-host style if the bucket is compatible
 		// (fallback to path-style if not)
 		if strings.IndexByte(bucket, '.') < 0 {
@@ -223,30 +499,235 @@ func Open(k *aws.SigningKey, bucket, object string, contents bool) (*File, error
 	return f, nil
 }
 
-func flakyDo(cl *http.Client, req *http.Request) (*http.Response, error) {
+// maxAttempts is the number of times flakyDo
+// tries a request that fails with a transient error.
+const maxAttempts = 2
+
+// RetryError is returned from requests that
+// still failed after exhausting their retries.
+type RetryError struct {
+	Attempts   int   // Attempts is the number of attempts that were made.
+	StatusCode int   // StatusCode is the status of the last response, or 0 if there was none.
+	Err        error // Err is the last error encountered.
+}
+
+// Error implements error.Error
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s (after %d attempts)", e.Err, e.Attempts)
+}
+
+// Unwrap returns the last error encountered.
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// RetryInfo returns the number of attempts made
+// by the request that produced err, or false if
+// err did not come from a request that was retried.
+func RetryInfo(err error) (attempts int, ok bool) {
+	var re *RetryError
+	if errors.As(err, &re) {
+		return re.Attempts, true
+	}
+	return 0, false
+}
+
+// Limiter limits the rate of outgoing requests.
+// A *rate.Limiter from golang.org/x/time/rate
+// satisfies this interface.
+type Limiter interface {
+	// Wait blocks until a request may be made
+	// or ctx is done, returning ctx.Err() in
+	// the latter case.
+	Wait(ctx context.Context) error
+}
+
+// replayable returns whether req can safely be sent again
+// after a failed attempt: its body must be rewindable and,
+// as with net/http, its method must be idempotent unless
+// it has an Idempotency-Key or X-Idempotency-Key header.
+func replayable(req *http.Request) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	_, xok := req.Header["X-Idempotency-Key"]
+	return ok || xok
+}
+
+// flakyDo performs req with cl, retrying transient
+// failures if req is replayable. If lim is not nil,
+// every attempt waits on lim before it is made, and
+// if log is not nil, the outcome is logged once the
+// final attempt has been made.
+func flakyDo(cl *http.Client, lim Limiter, log *slog.Logger, st *counters, req *http.Request) (*http.Response, error) {
 	hasBody := req.Body != nil
+	retry := replayable(req)
 	if cl == nil {
 		cl = &DefaultClient
 	}
-	res, err := cl.Do(req)
-	if err == nil && (res.StatusCode != 500 && res.StatusCode != 503) {
-		return res, err
+	req, tr := traceRequest(log, req)
+	req = st.trace(req)
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		if lim != nil {
+			if err := lim.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+		tr.reset()
+		st.attempt(req, attempt)
+		res, err := cl.Do(req)
+		if err == nil && !retryable(res.StatusCode) {
+			logRequest(log, req, res, err, start, attempt-1, tr)
+			res.Body = st.body(res)
+			return res, err
+		}
+		// we can't re-do this request if we can't
+		// rewind the Body reader or it has side effects
+		if attempt >= maxAttempts || !retry || req.Context().Err() != nil {
+			logRequest(log, req, res, err, start, attempt-1, tr)
+			return nil, retryError(attempt, res, err)
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		if hasBody {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("req.GetBody: %w", err)
+			}
+		}
+	}
+}
+
+// redactedParams are the query parameters
+// of presigned URLs that carry credentials.
+var redactedParams = []string{
+	"X-Amz-Credential",
+	"X-Amz-Signature",
+	"X-Amz-Security-Token",
+}
+
+// redactURL returns u as a string, with
+// any credentials in the query redacted.
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	redacted := false
+	for _, name := range redactedParams {
+		if q.Has(name) {
+			q.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted && u.User == nil {
+		return u.String()
+	}
+	c := *u
+	c.User = nil
+	if redacted {
+		c.RawQuery = q.Encode()
+	}
+	return c.String()
+}
+
+// logRequest logs the outcome of req at debug level,
+// if log is not nil. Headers are never logged, since
+// they contain the request signature.
+func logRequest(log *slog.Logger, req *http.Request, res *http.Response, err error, start time.Time, retries int, tr *requestTrace) {
+	if log == nil || !log.Enabled(req.Context(), slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL)),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("retries", retries),
 	}
-	if hasBody && req.GetBody == nil {
-		// can't re-do this request because
-		// we can't rewind the Body reader
-		return res, err
+	if ttfb := tr.ttfb(); ttfb > 0 {
+		attrs = append(attrs, slog.Duration("ttfb", ttfb))
 	}
 	if res != nil {
-		res.Body.Close()
+		attrs = append(attrs, slog.Int("status", res.StatusCode))
 	}
-	if hasBody {
-		req.Body, err = req.GetBody()
-		if err != nil {
-			return nil, fmt.Errorf("req.GetBody: %w", err)
-		}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	log.LogAttrs(req.Context(), slog.LevelDebug, "s3 request", attrs...)
+}
+
+// requestTrace records when the last attempt of a request
+// was sent and when the first byte of its response arrived.
+// The times are in Unix nanoseconds, as the transport may
+// report them from another goroutine.
+type requestTrace struct {
+	wrote, first atomic.Int64
+}
+
+// traceRequest returns req with a requestTrace attached
+// if log records requests, or req and nil otherwise.
+func traceRequest(log *slog.Logger, req *http.Request) (*http.Request, *requestTrace) {
+	if log == nil || !log.Enabled(req.Context(), slog.LevelDebug) {
+		return req, nil
+	}
+	tr := new(requestTrace)
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteRequest:         func(httptrace.WroteRequestInfo) { tr.wrote.Store(time.Now().UnixNano()) },
+		GotFirstResponseByte: func() { tr.first.Store(time.Now().UnixNano()) },
+	})
+	return req.WithContext(ctx), tr
+}
+
+// reset forgets the times of a previous attempt.
+func (t *requestTrace) reset() {
+	if t != nil {
+		t.wrote.Store(0)
+		t.first.Store(0)
+	}
+}
+
+// ttfb returns the time to first byte of the last attempt:
+// the time between sending the request and receiving the
+// first byte of the response, or zero if it is unknown.
+func (t *requestTrace) ttfb() time.Duration {
+	if t == nil {
+		return 0
+	}
+	wrote, first := t.wrote.Load(), t.first.Load()
+	if wrote == 0 || first < wrote {
+		return 0
+	}
+	return time.Duration(first - wrote)
+}
+
+// retryable returns whether a response with
+// the given status code should be retried.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// retryError produces a *RetryError from the
+// final response or error of a failed request.
+func retryError(attempts int, res *http.Response, err error) error {
+	if res == nil {
+		return &RetryError{Attempts: attempts, Err: err}
+	}
+	defer res.Body.Close()
+	op := "s3"
+	if res.Request != nil {
+		op += " " + res.Request.Method
+	}
+	return &RetryError{
+		Attempts:   attempts,
+		StatusCode: res.StatusCode,
+		Err:        responseError(op, res),
 	}
-	return cl.Do(req)
 }
 
 func (f *File) open(k *aws.SigningKey, bucket, object string, contents bool) error {
@@ -266,6 +747,24 @@ func (f *File) open(k *aws.SigningKey, bucket, object string, contents bool) err
 	return nil
 }
 
+// reader returns a Reader with the settings and
+// context of r, but without the object r points to.
+func (r *Reader) reader() Reader {
+	return Reader{
+		Key:         r.Key,
+		Client:      r.Client,
+		Bucket:      r.Bucket,
+		UserAgent:   r.UserAgent,
+		Limiter:     r.Limiter,
+		Logger:      r.Logger,
+		ReadBudget:  r.ReadBudget,
+		ErrorMapper: r.ErrorMapper,
+		StrictETag:  r.StrictETag,
+		stats:       r.stats,
+		ctx:         r.ctx,
+	}
+}
+
 func (r *Reader) open(k *aws.SigningKey, bucket, object string, contents bool) (io.ReadCloser, error) {
 	if !ValidBucket(bucket) {
 		return nil, badBucket(bucket)
@@ -274,29 +773,23 @@ func (r *Reader) open(k *aws.SigningKey, bucket, object string, contents bool) (
 	if contents {
 		method = http.MethodGet
 	}
-	req, err := http.NewRequest(method, uri(k, bucket, object), nil)
+	req, err := http.NewRequestWithContext(r.context(), method, uri(k, bucket, object), nil)
 	if err != nil {
 		return nil, err
 	}
+	setUserAgent(req, r.UserAgent)
 	k.SignV4(req, nil)
 
 	// FIXME: configurable http.Client here?
-	res, err := flakyDo(&DefaultClient, req)
+	res, err := flakyDo(&DefaultClient, r.Limiter, r.Logger, r.stats, req)
 	if err != nil {
 		return nil, err
 	}
 	if res.StatusCode != 200 {
-		var inner error
-		switch res.StatusCode {
-		case 404:
-			inner = fs.ErrNotExist
-		case 403:
-			inner = fs.ErrPermission
-		default:
-			// NOTE: we can't extractMessage() here, because HEAD
-			// errors do not produce a response with an error message
-			inner = fmt.Errorf("s3.Open: %s returned %s", req.Method, res.Status)
-		}
+		// NOTE: HEAD errors do not produce a response with
+		// an error message, but the headers of the response
+		// may still point out a region mismatch
+		inner := mapError(r.ErrorMapper, "s3.Open "+req.Method, res)
 		err := &fs.PathError{
 			Op:   "open",
 			Path: "s3://" + bucket + "/" + object,
@@ -304,39 +797,171 @@ func (r *Reader) open(k *aws.SigningKey, bucket, object string, contents bool) (
 		}
 		return res.Body, err
 	}
-	if res.ContentLength < 0 {
-		return res.Body, fmt.Errorf("s3.Open: content length %d invalid", res.ContentLength)
+	size := res.ContentLength
+	if size < 0 && contents {
+		// some S3-compatible gateways stream GET responses
+		// without a Content-Length, so the size of the object
+		// has to be read from a HEAD instead
+		head := r.reader()
+		body, err := head.open(k, bucket, object, false)
+		if body != nil {
+			body.Close()
+		}
+		if err != nil {
+			return res.Body, err
+		}
+		size = head.Size
+	}
+	if size < 0 {
+		return res.Body, fmt.Errorf("s3.Open: content length %d invalid", size)
 	}
-	lm, _ := time.Parse(time.RFC1123, res.Header.Get("LastModified"))
+	lm, _ := http.ParseTime(res.Header.Get("Last-Modified"))
 	*r = Reader{
 		Key:          k,
 		Client:       &DefaultClient,
 		ETag:         res.Header.Get("ETag"),
 		LastModified: lm,
-		Size:         res.ContentLength,
+		Size:         size,
 		Bucket:       bucket,
 		Path:         object,
+		UserAgent:    r.UserAgent,
+		Limiter:      r.Limiter,
+		ReadBudget:   r.ReadBudget,
+		ErrorMapper:  r.ErrorMapper,
+		Logger:       r.Logger,
+		Verify:       r.Verify,
+		StrictETag:   r.StrictETag,
+		stats:        r.stats,
+		BucketKey:    bucketKeyEnabled(res.Header),
+		StorageClass: storageClass(res.Header),
+		Owner:        r.Owner,
+
+		Encryption:           res.Header.Get("x-amz-server-side-encryption"),
+		SSECustomerAlgorithm: res.Header.Get("x-amz-server-side-encryption-customer-algorithm"),
+
+		ContentType:        res.Header.Get("Content-Type"),
+		CacheControl:       res.Header.Get("Cache-Control"),
+		ContentDisposition: res.Header.Get("Content-Disposition"),
+		Expiration:         parseExpiration(res.Header.Get("x-amz-expiration")),
+
+		ctx: r.ctx,
+	}
+	r.Expires, _ = http.ParseTime(res.Header.Get("Expires"))
+	r.Headers = objectHeaders(res.Header)
+	return budgeted(r.ReadBudget, res.Body), nil
+}
+
+// storageClass returns the storage class of an object
+// from the x-amz-storage-class header, which S3 omits
+// for objects in the STANDARD class.
+func storageClass(h http.Header) string {
+	if class := h.Get("x-amz-storage-class"); class != "" {
+		return class
 	}
-	return res.Body, nil
+	return "STANDARD"
+}
+
+// storedHeaders are the headers of an object, set on upload
+// (see UploadOptions.ExtraHeaders), that are returned by S3
+// on GET and HEAD and collected in Reader.Headers.
+var storedHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Type",
+	"Expires",
+	"X-Amz-Website-Redirect-Location",
+}
+
+// objectHeaders returns the storedHeaders of h that are set.
+func objectHeaders(h http.Header) http.Header {
+	out := make(http.Header)
+	for _, name := range storedHeaders {
+		if v := h.Values(name); len(v) > 0 {
+			out[name] = v
+		}
+	}
+	return out
+}
+
+func (r *Reader) client() *http.Client {
+	if r.Client == nil {
+		return &DefaultClient
+	}
+	return r.Client
+}
+
+// Stat returns an fs.FileInfo describing the object,
+// with a name derived from r.Path. If the ETag of the
+// object is not yet known, Stat first performs a HEAD
+// to populate the size, ETag, and modification time.
+func (r *Reader) Stat() (fs.FileInfo, error) {
+	if r.ETag == "" {
+		body, err := r.open(r.Key, r.Bucket, r.Path, false)
+		if body != nil {
+			body.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &File{Reader: *r, ctx: context.Background()}, nil
 }
 
 // WriteTo implements io.WriterTo
 func (r *Reader) WriteTo(w io.Writer) (int64, error) {
-	req, err := http.NewRequest("GET", uri(r.Key, r.Bucket, r.Path), nil)
+	req, err := http.NewRequestWithContext(r.context(), "GET", uri(r.Key, r.Bucket, r.Path), nil)
 	if err != nil {
 		return 0, err
 	}
+	if r.StrictETag && r.ETag != "" {
+		req.Header.Set("If-Match", r.ETag)
+	}
+	setUserAgent(req, r.UserAgent)
 	r.Key.SignV4(req, nil)
 
-	res, err := flakyDo(r.Client, req)
+	res, err := flakyDo(r.Client, r.Limiter, r.Logger, r.stats, req)
 	if err != nil {
 		return 0, err
 	}
 	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		return 0, fmt.Errorf("s3.Reader.WriteTo: status %s %q", res.Status, extractMessage(res.Body))
+	switch res.StatusCode {
+	case http.StatusOK:
+		// okay
+	case http.StatusPreconditionFailed:
+		return 0, ErrETagChanged
+	default:
+		return 0, responseError("s3.Reader.WriteTo", res)
 	}
-	return io.Copy(w, res.Body)
+	body := budgeted(r.ReadBudget, res.Body)
+	// the encryption of an object that was listed
+	// rather than opened is only known from the response
+	verify := r.Verify && digestETag(res.Header.Get("x-amz-server-side-encryption"), res.Header.Get("x-amz-server-side-encryption-customer-algorithm"))
+	if !verify {
+		n, err := io.Copy(w, body)
+		if err == nil && n < r.Size {
+			err = r.shortRead(n)
+		}
+		return n, err
+	}
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(w, h), body)
+	if err != nil {
+		return n, err
+	}
+	if n < r.Size {
+		return n, r.shortRead(n)
+	}
+	return n, r.verify(h.Sum(nil))
+}
+
+// shortRead returns the error for a body that ended
+// after n bytes, before the end of the object. The
+// length of a body is not checked by the HTTP client
+// when it is sent without a Content-Length.
+func (r *Reader) shortRead(n int64) error {
+	return fmt.Errorf("s3: reading %s: body ended after %d of %d bytes: %w", r.Path, n, r.Size, io.ErrUnexpectedEOF)
 }
 
 // RangeReader produces an io.ReadCloser that reads
@@ -344,8 +969,16 @@ func (r *Reader) WriteTo(w io.Writer) (int64, error) {
 //
 // It is the caller's responsibility to call Close()
 // on the returned io.ReadCloser.
+//
+// If the server does not honor the requested range
+// and off is not zero, then RangeReader returns an
+// error matching ErrRangeUnsupported.
 func (r *Reader) RangeReader(off, width int64) (io.ReadCloser, error) {
-	req, err := http.NewRequest("GET", uri(r.Key, r.Bucket, r.Path), nil)
+	return r.rangeReader(r.context(), off, width)
+}
+
+func (r *Reader) rangeReader(ctx context.Context, off, width int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri(r.Key, r.Bucket, r.Path), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -353,26 +986,41 @@ func (r *Reader) RangeReader(off, width int64) (io.ReadCloser, error) {
 	if r.ETag != "" {
 		req.Header.Set("If-Match", r.ETag)
 	}
+	setUserAgent(req, r.UserAgent)
 	r.Key.SignV4(req, nil)
 
-	res, err := flakyDo(r.Client, req)
+	res, err := flakyDo(r.Client, r.Limiter, r.Logger, r.stats, req)
 	if err != nil {
 		return nil, err
 	}
 	switch res.StatusCode {
 	default:
 		defer res.Body.Close()
-		return nil, fmt.Errorf("s3.Reader.RangeReader: status %s %q", res.Status, extractMessage(res.Body))
+		return nil, responseError("s3.Reader.RangeReader", res)
 	case http.StatusPreconditionFailed:
 		res.Body.Close()
 		return nil, ErrETagChanged
 	case http.StatusNotFound:
 		res.Body.Close()
 		return nil, &fs.PathError{Op: "read", Path: r.Path, Err: fs.ErrNotExist}
-	case http.StatusPartialContent, http.StatusOK:
+	case http.StatusOK:
+		// the server ignored the Range header and sent
+		// the full object; a prefix of it can still be
+		// served, but any other range cannot
+		if off != 0 {
+			res.Body.Close()
+			return nil, &fs.PathError{Op: "read", Path: r.Path, Err: ErrRangeUnsupported}
+		}
+		return &readCloser{Reader: io.LimitReader(budgeted(r.ReadBudget, res.Body), width), Closer: res.Body}, nil
+	case http.StatusPartialContent:
 		// okay; fallthrough
 	}
-	return res.Body, nil
+	return budgeted(r.ReadBudget, res.Body), nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
 
 // ReadAt implements io.ReaderAt
@@ -385,39 +1033,132 @@ func (r *Reader) ReadAt(dst []byte, off int64) (int, error) {
 	return io.ReadFull(rd, dst)
 }
 
+// Peek returns the first n bytes of the object, or all of
+// them if it is shorter, fetching only those with a single
+// ranged GET, e.g. to sniff the type of its contents.
+func (r *Reader) Peek(ctx context.Context, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("s3.Reader.Peek: negative count %d", n)
+	}
+	if n == 0 {
+		return []byte{}, nil
+	}
+	rd, err := r.rangeReader(ctx, 0, int64(n))
+	if err != nil {
+		// the range of an empty object is not satisfiable
+		var e *Error
+		if errors.As(err, &e) && e.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			return []byte{}, nil
+		}
+		return nil, err
+	}
+	defer rd.Close()
+	buf := make([]byte, n)
+	m, err := io.ReadFull(rd, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return buf[:m], err
+}
+
+// regions caches the region of each bucket
+// resolved by BucketRegion, keyed by endpoint
+// and bucket name.
+var regions sync.Map
+
 // BucketRegion returns the region associated
 // with the given bucket.
+//
+// The region is read from the bucket location
+// constraint and, if that is not available, from
+// the x-amz-bucket-region header of a HEAD bucket
+// request. Resolved regions are cached for the
+// lifetime of the process.
 func BucketRegion(k *aws.SigningKey, bucket string) (string, error) {
 	if !ValidBucket(bucket) {
 		return "", badBucket(bucket)
 	}
-	if k.BaseURI != "" {
-		return k.Region, nil
+	id := k.BaseURI + "/" + bucket
+	if region, ok := regions.Load(id); ok {
+		return region.(string), nil
+	}
+	region, err := bucketLocation(k, bucket)
+	if err != nil {
+		if k.BaseURI != "" {
+			return k.Region, nil
+		}
+		var ok bool
+		region, ok, err = headRegion(k, bucket)
+		if err != nil || !ok {
+			return region, err
+		}
 	}
-	uri := rawURI(k, bucket, "")
-	req, err := http.NewRequest(http.MethodHead, uri, nil)
+	regions.Store(id, region)
+	return region, nil
+}
+
+// bucketLocation returns the region of a bucket
+// using the GetBucketLocation API.
+func bucketLocation(k *aws.SigningKey, bucket string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURI(k, bucket, "?location="), nil)
 	if err != nil {
 		return "", err
 	}
+	setUserAgent(req, "")
 	k.SignV4(req, nil)
-	res, err := flakyDo(&DefaultClient, req)
+	res, err := flakyDo(&DefaultClient, nil, nil, nil, req)
 	if err != nil {
 		return "", err
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("s3.BucketRegion: %s %q", res.Status, extractMessage(res.Body))
+	}
+	var loc struct {
+		Region string `xml:",chardata"`
+	}
+	if err := xml.NewDecoder(res.Body).Decode(&loc); err != nil {
+		return "", fmt.Errorf("xml decoding response: %w", err)
+	}
+	switch region := strings.TrimSpace(loc.Region); region {
+	case "":
+		return "us-east-1", nil
+	case "EU":
+		return "eu-west-1", nil
+	default:
+		return region, nil
+	}
+}
+
+// headRegion returns the region of a bucket using
+// the x-amz-bucket-region header of a HEAD bucket
+// request. If the header is not present, the region
+// of k is returned and ok is false.
+func headRegion(k *aws.SigningKey, bucket string) (region string, ok bool, err error) {
+	req, err := http.NewRequest(http.MethodHead, rawURI(k, bucket, ""), nil)
+	if err != nil {
+		return "", false, err
+	}
+	setUserAgent(req, "")
+	k.SignV4(req, nil)
+	res, err := flakyDo(&DefaultClient, nil, nil, nil, req)
+	if err != nil {
+		return "", false, err
+	}
+	defer res.Body.Close()
 	switch res.StatusCode {
 	case 403:
-		return k.Region, nil
+		return k.Region, false, nil
 	case 200, 301:
 		// ok
 	default:
-		return "", fmt.Errorf("s3.BucketRegion: %s %q", res.Status, extractMessage(res.Body))
+		return "", false, fmt.Errorf("s3.BucketRegion: %s %q", res.Status, extractMessage(res.Body))
 	}
-	region := res.Header.Get("x-amz-bucket-region")
+	region = res.Header.Get("x-amz-bucket-region")
 	if region == "" {
-		return k.Region, nil
+		return k.Region, false, nil
 	}
-	return region, nil
+	return region, true, nil
 }
 
 // DeriveForBucket can be passed to aws.AmbientCreds
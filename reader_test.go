@@ -17,8 +17,17 @@ package s3
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/kelindar/s3/aws"
 	"github.com/kelindar/s3/mock"
@@ -138,6 +147,120 @@ func TestReader_ReadAt(t *testing.T) {
 	assert.Equal(t, content[5:15], buf)
 }
 
+func TestReader_Peek(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	ctx := context.Background()
+
+	content := bytes.Repeat([]byte("\x89PNG\r\n\x1a\n0123456789ABCDEF"), 64)
+	etag := mockServer.PutObject("test/image.png", content)
+	reader := &Reader{Key: key, Client: &DefaultClient, ETag: etag, Size: int64(len(content)), Bucket: bucket, Path: "test/image.png"}
+
+	t.Run("prefix", func(t *testing.T) {
+		n := len(mockServer.GetRequestLog())
+		head, err := reader.Peek(ctx, 16)
+		assert.NoError(t, err)
+		assert.Equal(t, content[:16], head)
+
+		log := mockServer.GetRequestLog()[n:]
+		assert.Len(t, log, 1)
+		assert.Equal(t, "bytes=0-15", log[0].Headers["Range"])
+	})
+
+	t.Run("short", func(t *testing.T) {
+		mockServer.PutObject("test/short.txt", []byte("tiny"))
+		short := &Reader{Key: key, Client: &DefaultClient, Bucket: bucket, Path: "test/short.txt"}
+		head, err := short.Peek(ctx, 16)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("tiny"), head)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		mockServer.PutObject("test/empty.txt", nil)
+		empty := &Reader{Key: key, Client: &DefaultClient, Bucket: bucket, Path: "test/empty.txt"}
+		head, err := empty.Peek(ctx, 16)
+		assert.NoError(t, err)
+		assert.Empty(t, head)
+
+		head, err = reader.Peek(ctx, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, head)
+		_, err = reader.Peek(ctx, -1)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		missing := &Reader{Key: key, Client: &DefaultClient, Bucket: bucket, Path: "test/missing.png"}
+		_, err := missing.Peek(ctx, 16)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
+
+func TestReader_Clone(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	content := make([]byte, 64*1024)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	objectKey := "test/clone-test.bin"
+	mockServer.PutObject(objectKey, content)
+
+	reader, err := Stat(key, bucket, objectKey)
+	assert.NoError(t, err)
+
+	t.Run("parallel", func(t *testing.T) {
+		const workers, chunk = 8, 8 * 1024
+		out := make([]byte, len(content))
+		var wg sync.WaitGroup
+		for i := range workers {
+			wg.Add(1)
+			go func(r *Reader) {
+				defer wg.Done()
+				off := int64(i * chunk)
+				n, err := r.ReadAt(out[off:off+chunk], off)
+				assert.NoError(t, err)
+				assert.Equal(t, chunk, n)
+			}(reader.Clone())
+		}
+		wg.Wait()
+		assert.Equal(t, content, out)
+	})
+
+	t.Run("independent", func(t *testing.T) {
+		clone := reader.Clone()
+		assert.Equal(t, reader.ETag, clone.ETag)
+		assert.Equal(t, reader.Size, clone.Size)
+		assert.Equal(t, reader.Path, clone.Path)
+
+		clone.ETag = "changed"
+		clone.Size = 1
+		clone.Headers.Set("Content-Language", "fr")
+		assert.NotEqual(t, "changed", reader.ETag)
+		assert.Equal(t, int64(len(content)), reader.Size)
+		assert.Empty(t, reader.Headers.Get("Content-Language"))
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		owned := *reader
+		owned.Owner = &Grantee{ID: "owner-id", DisplayName: "owner"}
+		clone := owned.Clone()
+		assert.Equal(t, owned.Owner, clone.Owner)
+
+		clone.Owner.DisplayName = "changed"
+		assert.Equal(t, "owner", owned.Owner.DisplayName)
+	})
+}
+
 func TestReader_WriteTo(t *testing.T) {
 	bucket := "test-bucket"
 	mockServer := mock.New(bucket, "us-east-1")
@@ -186,6 +309,40 @@ func TestStat(t *testing.T) {
 	assert.NotEmpty(t, reader.ETag)
 }
 
+func TestReader_Stat(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	content := []byte("Reader stat content")
+	objectKey := "test/reader-stat.txt"
+	mockServer.PutObject(objectKey, content)
+	obj, ok := mockServer.GetObject(objectKey)
+	assert.True(t, ok)
+
+	r := &Reader{Key: key, Bucket: bucket, Path: objectKey}
+	info, err := r.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, "reader-stat.txt", info.Name())
+	assert.Equal(t, int64(len(content)), info.Size())
+	assert.Equal(t, obj.LastModified.Truncate(time.Second), info.ModTime().UTC())
+	assert.False(t, info.IsDir())
+	assert.Equal(t, obj.ETag, r.ETag)
+
+	// a known object is not fetched again
+	heads := len(mockServer.GetRequestsWithMethod("HEAD"))
+	_, err = r.Stat()
+	assert.NoError(t, err)
+	assert.Len(t, mockServer.GetRequestsWithMethod("HEAD"), heads)
+
+	r = &Reader{Key: key, Bucket: bucket, Path: "test/missing.txt"}
+	_, err = r.Stat()
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
 func TestNewFile(t *testing.T) {
 	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
 	bucket := "test-bucket"
@@ -236,6 +393,70 @@ func TestBucketRegion(t *testing.T) {
 	assert.ErrorIs(t, err, ErrInvalidBucket)
 }
 
+func TestBucketRegion_Location(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "eu-west-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	region, err := BucketRegion(key, bucket)
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", region)
+
+	log := mockServer.GetRequestLog()
+	assert.Len(t, log, 1)
+	assert.Equal(t, "GET", log[0].Method)
+	assert.Equal(t, "location=", log[0].Query)
+
+	// Second lookup is served from the cache
+	region, err = BucketRegion(key, bucket)
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", region)
+	assert.Len(t, mockServer.GetRequestLog(), 1)
+}
+
+func TestRegionMismatch(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "eu-west-1")
+	defer mockServer.Close()
+	mockServer.PopulateTestData(map[string][]byte{"a.txt": []byte("a")})
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	hint := "signing region us-east-1 but bucket is in region eu-west-1; set region to eu-west-1"
+
+	t.Run("get", func(t *testing.T) {
+		_, err := NewBucket(key, bucket).ReadFile("a.txt")
+		assert.ErrorContains(t, err, hint)
+		var s3err *Error
+		if assert.ErrorAs(t, err, &s3err) {
+			assert.Equal(t, "AuthorizationHeaderMalformed", s3err.Code)
+			assert.Equal(t, "us-east-1", s3err.SigningRegion)
+			assert.Equal(t, "eu-west-1", s3err.BucketRegion)
+		}
+	})
+
+	t.Run("head", func(t *testing.T) {
+		b := NewBucket(key, bucket)
+		b.Lazy = true
+		_, err := b.Open("a.txt")
+		assert.ErrorContains(t, err, hint)
+	})
+
+	t.Run("same region", func(t *testing.T) {
+		k := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "eu-west-1", "s3")
+		k.BaseURI = mockServer.URL()
+		data, err := NewBucket(k, bucket).ReadFile("a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("a"), data)
+
+		_, err = NewBucket(k, bucket).ReadFile("missing.txt")
+		assert.NotContains(t, err.Error(), "set region")
+	})
+}
+
 func TestDeriveForBucket(t *testing.T) {
 	bucket := "test-bucket"
 	mockServer := mock.New(bucket, "us-east-1")
@@ -284,6 +505,43 @@ func TestBucketRegion_Comprehensive(t *testing.T) {
 	assert.ErrorIs(t, err, ErrInvalidBucket)
 }
 
+func TestRangeUnsupported(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+	mockServer.IgnoreRange(true)
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	content := []byte("0123456789abcdef")
+	etag := mockServer.PutObject("range.txt", content)
+	reader := &Reader{
+		Key:    key,
+		Client: &DefaultClient,
+		ETag:   etag,
+		Size:   int64(len(content)),
+		Bucket: bucket,
+		Path:   "range.txt",
+	}
+
+	// A prefix is served by truncating the full body
+	rd, err := reader.RangeReader(0, 4)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(rd)
+	assert.NoError(t, err)
+	assert.NoError(t, rd.Close())
+	assert.Equal(t, []byte("0123"), data)
+
+	// Any other range is reported
+	_, err = reader.RangeReader(4, 4)
+	assert.ErrorIs(t, err, ErrRangeUnsupported)
+
+	buf := make([]byte, 4)
+	_, err = reader.ReadAt(buf, 8)
+	assert.ErrorIs(t, err, ErrRangeUnsupported)
+}
+
 func TestReaderErrors(t *testing.T) {
 	t.Run("range reader", func(t *testing.T) {
 		bucket := "test-bucket"
@@ -399,3 +657,272 @@ func TestReaderErrors(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestDirectoryEndpoint(t *testing.T) {
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-west-2", "s3express")
+
+	endpoint, ok := DirectoryEndpoint("us-west-2", "my-bucket--usw2-az1--x-s3")
+	assert.True(t, ok)
+	assert.Equal(t, "https://my-bucket--usw2-az1--x-s3.s3express-usw2-az1.us-west-2.amazonaws.com", endpoint)
+	assert.Equal(t, endpoint+"/path/to/file.txt", uri(key, "my-bucket--usw2-az1--x-s3", "path/to/file.txt"))
+
+	// general purpose buckets are not directory buckets
+	for _, bucket := range []string{"my-bucket", "my--x-s3", "my-bucket--x-s3", "a--b--c"} {
+		_, ok := DirectoryEndpoint("us-west-2", bucket)
+		assert.False(t, ok, bucket)
+	}
+	assert.Equal(t, "https://my-bucket.s3.us-west-2.amazonaws.com/file.txt", uri(key, "my-bucket", "file.txt"))
+}
+
+func TestRetryInfo(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = srv.URL
+
+	_, err := NewBucket(key, "test-bucket").Write(context.Background(), "file.txt", []byte("data"))
+	assert.Error(t, err)
+
+	attempts, ok := RetryInfo(err)
+	assert.True(t, ok)
+	assert.Equal(t, maxAttempts, attempts)
+	assert.Equal(t, int32(maxAttempts), calls.Load())
+
+	var re *RetryError
+	assert.ErrorAs(t, err, &re)
+	assert.Equal(t, http.StatusServiceUnavailable, re.StatusCode)
+
+	_, ok = RetryInfo(fs.ErrNotExist)
+	assert.False(t, ok)
+}
+
+func TestRetryReplay(t *testing.T) {
+	var calls atomic.Int32
+	var bodies []string
+	var lock sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		bodies = append(bodies, string(body))
+		lock.Unlock()
+		if calls.Add(1)%2 == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	reset := func() {
+		calls.Store(0)
+		bodies = nil
+	}
+
+	t.Run("get", func(t *testing.T) {
+		reset()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		res, err := flakyDo(srv.Client(), nil, nil, nil, req)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		assert.Equal(t, "ok", string(data))
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("put rewinds", func(t *testing.T) {
+		reset()
+		req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("payload"))
+		res, err := flakyDo(srv.Client(), nil, nil, nil, req)
+		assert.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+	})
+
+	t.Run("put one-shot", func(t *testing.T) {
+		reset()
+		req, _ := http.NewRequest(http.MethodPut, srv.URL, io.NopCloser(strings.NewReader("payload")))
+		_, err := flakyDo(srv.Client(), nil, nil, nil, req)
+		attempts, ok := RetryInfo(err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("post", func(t *testing.T) {
+		reset()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+		_, err := flakyDo(srv.Client(), nil, nil, nil, req)
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+
+		reset()
+		req, _ = http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+		req.Header["Idempotency-Key"] = nil
+		res, err := flakyDo(srv.Client(), nil, nil, nil, req)
+		assert.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func TestThrottled(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	mockServer.EnableErrorSimulation(mock.ErrorSimulation{SlowDownErrors: true})
+
+	_, err := b.Write(context.Background(), "file.txt", []byte("data"))
+	assert.ErrorIs(t, err, ErrThrottled)
+	attempts, ok := RetryInfo(err)
+	assert.True(t, ok)
+	assert.Equal(t, maxAttempts, attempts)
+
+	var s3err *Error
+	assert.ErrorAs(t, err, &s3err)
+	assert.Equal(t, "SlowDown", s3err.Code)
+
+	mockServer.DisableErrorSimulation()
+	_, err = b.Write(context.Background(), "file.txt", []byte("data"))
+	assert.NoError(t, err)
+
+	// 429 responses are retried and reported as throttling as well
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	key.BaseURI = srv.URL
+	_, err = NewBucket(key, bucket).Write(context.Background(), "file.txt", []byte("data"))
+	assert.ErrorIs(t, err, ErrThrottled)
+	assert.Equal(t, int32(maxAttempts), calls.Load())
+
+	// other server errors are not throttling
+	assert.NotErrorIs(t, &Error{StatusCode: http.StatusServiceUnavailable}, ErrThrottled)
+	assert.NotErrorIs(t, &Error{StatusCode: http.StatusInternalServerError, Code: "InternalError"}, ErrThrottled)
+}
+
+func TestReader_Chunked(t *testing.T) {
+	content := []byte("chunked content streamed without a length")
+
+	// GET responses are streamed without a Content-Length;
+	// the HEAD response claims a size of 'size'
+	var size atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.FormatInt(size.Load(), 10))
+			return
+		}
+		half := len(content) / 2
+		w.Write(content[:half])
+		w.(http.Flusher).Flush()
+		w.Write(content[half:])
+	}))
+	defer srv.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = srv.URL
+
+	t.Run("read", func(t *testing.T) {
+		size.Store(int64(len(content)))
+		f, err := Open(key, "test-bucket", "chunked.txt", true)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(content)), f.Size())
+
+		data, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, content, data)
+
+		var buf bytes.Buffer
+		_, err = f.WriteTo(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, content, buf.Bytes())
+	})
+
+	t.Run("short", func(t *testing.T) {
+		size.Store(int64(len(content)) + 10)
+		f, err := Open(key, "test-bucket", "chunked.txt", true)
+		assert.NoError(t, err)
+
+		data, err := io.ReadAll(f)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, content, data)
+
+		_, err = f.WriteTo(io.Discard)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
+
+func TestReader_WithContext(t *testing.T) {
+	// every response sends a few bytes and then stalls
+	// until the request is aborted by the client
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = srv.URL
+	base := &Reader{Key: key, Client: &DefaultClient, Bucket: "test-bucket", Path: "slow.bin", Size: 1 << 20}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := base.WithContext(ctx)
+	assert.Nil(t, base.ctx)
+
+	reads := []func() error{
+		func() error {
+			_, err := r.ReadAt(make([]byte, 1024), 0)
+			return err
+		},
+		func() error {
+			_, err := r.ReadAt(make([]byte, 1024), 4096)
+			return err
+		},
+		func() error {
+			rd, err := r.RangeReader(1024, 1024)
+			if err != nil {
+				return err
+			}
+			defer rd.Close()
+			_, err = io.ReadAll(rd)
+			return err
+		},
+		func() error {
+			_, err := r.WriteTo(io.Discard)
+			return err
+		},
+	}
+
+	errs := make(chan error, len(reads))
+	for _, read := range reads {
+		go func() { errs <- read() }()
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	timeout := time.After(5 * time.Second)
+	for range reads {
+		select {
+		case err := <-errs:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-timeout:
+			t.Fatal("reads did not return after cancellation")
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// Package s3test wires the mock S3 server to an s3.Bucket, so that code
+// using the s3 package can be tested without any S3 infrastructure. It
+// is separate from the mock package, which the tests of the s3 package
+// itself depend on.
+package s3test
+
+import (
+	"github.com/kelindar/s3"
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+)
+
+// NewBucket starts a mock server for the bucket in the region and
+// returns it along with an s3.Bucket whose requests are signed with
+// fake credentials and sent to the server. The caller must Close
+// the server once done, e.g.
+//
+//	server, bucket := s3test.NewBucket("test-bucket", "us-east-1")
+//	defer server.Close()
+func NewBucket(bucket, region string) (*mock.Server, *s3.Bucket) {
+	server := mock.New(bucket, region)
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", region, "s3")
+	key.BaseURI = server.URL()
+	return server, s3.NewBucket(key, bucket)
+}
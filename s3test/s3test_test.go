@@ -0,0 +1,52 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3test
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBucket(t *testing.T) {
+	server, bucket := NewBucket("test-bucket", "eu-west-1")
+	defer server.Close()
+	ctx := context.Background()
+
+	t.Run("round trip", func(t *testing.T) {
+		etag, err := bucket.Write(ctx, "dir/hello.txt", []byte("Hello, World!"))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, etag)
+		assert.True(t, server.ObjectExists("dir/hello.txt"))
+
+		content, err := fs.ReadFile(bucket, "dir/hello.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello, World!", string(content))
+	})
+
+	t.Run("populated", func(t *testing.T) {
+		server.PutObject("dir/other.txt", []byte("other"))
+		entries, err := fs.ReadDir(bucket, "dir")
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := fs.ReadFile(bucket, "dir/missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// selectProbe is a trivial SelectObjectContent request that
+// returns at most one record of the object, read as CSV.
+const selectProbe = `<?xml version="1.0" encoding="UTF-8"?>
+<SelectObjectContentRequest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+<Expression>SELECT * FROM S3Object LIMIT 1</Expression>
+<ExpressionType>SQL</ExpressionType>
+<InputSerialization><CSV><FileHeaderInfo>NONE</FileHeaderInfo></CSV></InputSerialization>
+<OutputSerialization><CSV/></OutputSerialization>
+</SelectObjectContentRequest>`
+
+// SelectSupported reports whether the backend supports S3 Select
+// on the object, by issuing a trivial Select that returns at most
+// one record. A NotImplemented or MethodNotAllowed response means
+// that S3 Select is not supported, in which case SelectSupported
+// returns false and no error, so that the caller can download and
+// filter the object instead.
+//
+// The returned error matches fs.ErrNotExist if the object does not
+// exist and fs.ErrPermission if access is denied.
+func (r *Reader) SelectSupported(ctx context.Context) (bool, error) {
+	body := []byte(selectProbe)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri(r.Key, r.Bucket, r.Path)+"?select=&select-type=2", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	setUserAgent(req, r.UserAgent)
+	r.Key.SignV4(req, body)
+	res, err := flakyDo(r.client(), r.Limiter, r.Logger, r.stats, req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		// the result is not needed
+		io.Copy(io.Discard, res.Body)
+		return true, nil
+	case http.StatusNotFound, http.StatusForbidden:
+		return false, subresourceError("select", r.Path, res)
+	}
+	e := responseError("s3 select", res)
+	switch {
+	case e.StatusCode == http.StatusNotImplemented, e.StatusCode == http.StatusMethodNotAllowed:
+		return false, nil
+	case e.Code == "NotImplemented", e.Code == "MethodNotAllowed":
+		return false, nil
+	}
+	return false, e
+}
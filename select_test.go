@@ -0,0 +1,75 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader_SelectSupported(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	ctx := context.Background()
+
+	mockServer.PutObject("data.csv", []byte("a,b\n1,2\n"))
+	r := &Reader{Key: key, Bucket: bucket, Path: "data.csv"}
+
+	t.Run("supported", func(t *testing.T) {
+		ok, err := r.SelectSupported(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		req := mockServer.GetRequestsWithMethod("POST")
+		assert.Contains(t, string(req[len(req)-1].Body), "SELECT * FROM S3Object LIMIT 1")
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		mockServer.DisableSelect(true)
+		defer mockServer.DisableSelect(false)
+
+		ok, err := r.SelectSupported(ctx)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}))
+		defer srv.Close()
+
+		k := aws.DeriveKey(srv.URL, "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+		ok, err := (&Reader{Key: k, Bucket: bucket, Path: "data.csv"}).SelectSupported(ctx)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := (&Reader{Key: key, Bucket: bucket, Path: "missing.csv"}).SelectSupported(ctx)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
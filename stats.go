@@ -0,0 +1,137 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+// Stats are the counters of the requests made through
+// a Bucket and the prefixes, files and readers it returns,
+// as returned by Bucket.Stats.
+type Stats struct {
+	Requests    int64 // Requests is the number of requests made, not counting retries.
+	Retries     int64 // Retries is the number of requests that were sent again after a failed attempt.
+	BytesIn     int64 // BytesIn is the number of bytes of response bodies read.
+	BytesOut    int64 // BytesOut is the number of bytes of request bodies sent, including retries.
+	NewConns    int64 // NewConns is the number of attempts that dialed a new connection.
+	ReusedConns int64 // ReusedConns is the number of attempts that reused an idle connection.
+}
+
+// counters are the live, concurrency-safe
+// counters behind Stats.
+type counters struct {
+	requests, retries   atomic.Int64
+	bytesIn, bytesOut   atomic.Int64
+	newConns, reusedCon atomic.Int64
+}
+
+// Stats returns the counters of the requests made through b
+// since it was created or since the last call to ResetStats.
+// The connection counters tell whether the HTTP client keeps
+// connections alive: a low ratio of reused connections usually
+// means that response bodies are not read to the end or closed,
+// or that the transport keeps too few idle connections per host.
+func (b *Bucket) Stats() Stats {
+	return b.stats.load()
+}
+
+// ResetStats resets the counters returned by Stats to zero.
+func (b *Bucket) ResetStats() {
+	b.stats.reset()
+}
+
+func (c *counters) load() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	return Stats{
+		Requests:    c.requests.Load(),
+		Retries:     c.retries.Load(),
+		BytesIn:     c.bytesIn.Load(),
+		BytesOut:    c.bytesOut.Load(),
+		NewConns:    c.newConns.Load(),
+		ReusedConns: c.reusedCon.Load(),
+	}
+}
+
+func (c *counters) reset() {
+	if c == nil {
+		return
+	}
+	c.requests.Store(0)
+	c.retries.Store(0)
+	c.bytesIn.Store(0)
+	c.bytesOut.Store(0)
+	c.newConns.Store(0)
+	c.reusedCon.Store(0)
+}
+
+// trace returns req with a trace that counts the
+// connections obtained for it, if c is not nil.
+func (c *counters) trace(req *http.Request) *http.Request {
+	if c == nil {
+		return req
+	}
+	c.requests.Add(1)
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				c.reusedCon.Add(1)
+			} else {
+				c.newConns.Add(1)
+			}
+		},
+	})
+	return req.WithContext(ctx)
+}
+
+// attempt counts an attempt at sending req,
+// which is a retry if it is not the first one.
+func (c *counters) attempt(req *http.Request, attempt int) {
+	if c == nil {
+		return
+	}
+	if attempt > 1 {
+		c.retries.Add(1)
+	}
+	if req.Body != nil && req.ContentLength > 0 {
+		c.bytesOut.Add(req.ContentLength)
+	}
+}
+
+// body returns res.Body counting the bytes read from it.
+func (c *counters) body(res *http.Response) io.ReadCloser {
+	if c == nil {
+		return res.Body
+	}
+	return &countingBody{ReadCloser: res.Body, n: &c.bytesIn}
+}
+
+// countingBody is a response body that
+// counts the bytes that are read from it.
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+	"testing"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucket_Stats(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+	data := []byte("Hello, World!")
+
+	t.Run("sequential", func(t *testing.T) {
+		b.ResetStats()
+		for _, name := range []string{"stats/a.txt", "stats/b.txt", "stats/c.txt"} {
+			_, err := b.Write(ctx, name, data)
+			assert.NoError(t, err)
+		}
+		for i := 0; i < 3; i++ {
+			content, err := fs.ReadFile(b, "stats/a.txt")
+			assert.NoError(t, err)
+			assert.Equal(t, data, content)
+		}
+
+		stats := b.Stats()
+		assert.GreaterOrEqual(t, stats.Requests, int64(6))
+		assert.Zero(t, stats.Retries)
+		assert.Equal(t, int64(3*len(data)), stats.BytesOut)
+		assert.GreaterOrEqual(t, stats.BytesIn, int64(3*len(data)))
+		assert.Greater(t, stats.ReusedConns, int64(0))
+		assert.Equal(t, stats.Requests, stats.NewConns+stats.ReusedConns)
+	})
+
+	t.Run("listing", func(t *testing.T) {
+		b.ResetStats()
+		entries, err := fs.ReadDir(b, "stats")
+		assert.NoError(t, err)
+		assert.Len(t, entries, 3)
+
+		stats := b.Stats()
+		assert.Equal(t, int64(1), stats.Requests)
+		assert.Greater(t, stats.BytesIn, int64(0))
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		b.ResetStats()
+		mockServer.EnableErrorSimulation(mock.ErrorSimulation{SlowDownErrors: true})
+		_, err := b.Write(ctx, "stats/slow.txt", data)
+		mockServer.DisableErrorSimulation()
+		assert.Error(t, err)
+
+		stats := b.Stats()
+		assert.Equal(t, int64(1), stats.Requests)
+		assert.Equal(t, int64(maxAttempts-1), stats.Retries)
+		assert.Equal(t, int64(maxAttempts*len(data)), stats.BytesOut)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		b.ResetStats()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := fs.ReadFile(b, "stats/b.txt")
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		stats := b.Stats()
+		assert.GreaterOrEqual(t, stats.Requests, int64(8))
+		assert.Equal(t, stats.Requests, stats.NewConns+stats.ReusedConns)
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		b.ResetStats()
+		before := len(mockServer.GetRequestLog())
+		u, err := b.newUploader("stats/multi.bin", 0, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, u.Start(ctx))
+		assert.NoError(t, u.Abort(ctx))
+
+		// creating and aborting the upload are both counted
+		stats := b.Stats()
+		assert.Equal(t, int64(len(mockServer.GetRequestLog())-before), stats.Requests)
+		assert.Equal(t, int64(2), stats.Requests)
+		assert.Greater(t, stats.BytesIn, int64(0))
+		assert.Equal(t, stats.Requests, stats.NewConns+stats.ReusedConns)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		b.ResetStats()
+		assert.Equal(t, Stats{}, b.Stats())
+	})
+}
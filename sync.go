@@ -0,0 +1,165 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultSyncParallelism is the number of files
+// uploaded concurrently by Sync if SyncOptions.Parallel
+// is not set.
+const DefaultSyncParallelism = 8
+
+// SyncOptions configures Sync.
+type SyncOptions struct {
+	Delete   bool          // Delete removes destination objects under the prefix that are not present in the source.
+	Parallel int           // Parallel is the number of concurrent uploads. If it is not positive, DefaultSyncParallelism is used.
+	Upload   UploadOptions // Upload configures the uploaded objects.
+}
+
+// SyncResult reports the work done by Sync.
+type SyncResult struct {
+	Uploaded int // Uploaded is the number of new or changed files that were uploaded.
+	Skipped  int // Skipped is the number of files that were already up to date.
+	Deleted  int // Deleted is the number of extraneous destination objects that were removed.
+}
+
+// Sync makes the objects under prefix in dst mirror the regular
+// files of src, in the manner of rsync. Each file is uploaded to
+// the key formed by joining prefix and its path within src, unless
+// an object with the same size and MD5 digest already exists there.
+// If opts.Delete is set, objects under prefix with no corresponding
+// file in src are deleted.
+//
+// Files are uploaded with a single PutObject request each, so that
+// their ETags can be compared with the MD5 of the local files on
+// subsequent runs. Objects uploaded in multiple parts by other means
+// are always considered to have changed.
+func Sync(ctx context.Context, src fs.FS, dst *Bucket, prefix string, opts SyncOptions) (SyncResult, error) {
+	var result SyncResult
+	prefix = path.Clean(prefix)
+	if !fs.ValidPath(prefix) {
+		return result, badpath("s3 sync", prefix)
+	}
+	if err := opts.Upload.validate(); err != nil {
+		return result, err
+	}
+
+	dir := dst.sub(".")
+	if prefix != "." {
+		dir = dst.sub(prefix + "/")
+	}
+	existing, err := listFlat(ctx, dir)
+	if err != nil {
+		return result, err
+	}
+
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = DefaultSyncParallelism
+	}
+	var uploaded, skipped atomic.Int64
+	seen := make(map[string]bool)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(parallel)
+	err = fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case !d.Type().IsRegular():
+			return gctx.Err()
+		}
+		key := name
+		if prefix != "." {
+			key = prefix + "/" + name
+		}
+		seen[key] = true
+		remote, ok := existing[key]
+		g.Go(func() error {
+			contents, err := fs.ReadFile(src, name)
+			if err != nil {
+				return err
+			}
+			if ok && remote.Size == int64(len(contents)) {
+				sum := md5.Sum(contents)
+				if etag, ok := etagDigest(remote.ETag); ok && bytes.Equal(etag, sum[:]) {
+					skipped.Add(1)
+					return nil
+				}
+			}
+			if _, err := dst.put(gctx, key, contents, []UploadOptions{opts.Upload}); err != nil {
+				return fmt.Errorf("s3 sync %s: %w", name, err)
+			}
+			uploaded.Add(1)
+			return nil
+		})
+		return gctx.Err()
+	})
+	if werr := g.Wait(); err == nil {
+		err = werr
+	}
+	result.Uploaded, result.Skipped = int(uploaded.Load()), int(skipped.Load())
+	if err != nil || !opts.Delete {
+		return result, err
+	}
+
+	var extra []string
+	for key := range existing {
+		if !seen[key] {
+			extra = append(extra, key)
+		}
+	}
+	slices.Sort(extra)
+	for chunk := range slices.Chunk(extra, maxDeleteKeys) {
+		if err := dst.deleteObjects(ctx, chunk); err != nil {
+			return result, err
+		}
+		result.Deleted += len(chunk)
+	}
+	return result, nil
+}
+
+// listFlat returns every object under p, keyed by
+// its full path, without grouping by delimiter.
+func listFlat(ctx context.Context, p *Prefix) (map[string]*Reader, error) {
+	files := make(map[string]*Reader)
+	var token string
+	for {
+		ret, err := p.listWith(ctx, ListOptions{
+			MaxKeys:           maxDeleteKeys,
+			ContinuationToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range ret.Contents {
+			files[ret.Contents[i].Path()] = &ret.Contents[i].Reader
+		}
+		if !ret.IsTruncated {
+			return files, nil
+		}
+		token = ret.NextToken
+	}
+}
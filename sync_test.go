@@ -0,0 +1,71 @@
+// Copyright 2025 Roman Atachiants
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kelindar/s3/aws"
+	"github.com/kelindar/s3/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSync(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	src := fstest.MapFS{
+		"index.html":        {Data: []byte("<html></html>")},
+		"css/site.css":      {Data: []byte("body {}")},
+		"js/app.js":         {Data: []byte("console.log(1)")},
+		"js/vendor/lib.js":  {Data: []byte("var lib = {}")},
+		"img/empty.png":     {Data: []byte{}},
+		"docs/readme.txt":   {Data: []byte("read me")},
+		"docs/nested/a.txt": {Data: []byte("a")},
+	}
+	mockServer.PutObject("site/stale.txt", []byte("stale"))
+	mockServer.PutObject("other/keep.txt", []byte("keep"))
+
+	result, err := Sync(ctx, src, b, "site", SyncOptions{Delete: true})
+	assert.NoError(t, err)
+	assert.Equal(t, SyncResult{Uploaded: len(src), Deleted: 1}, result)
+	content, found := mockServer.ObjectContent("site/js/vendor/lib.js")
+	assert.True(t, found)
+	assert.Equal(t, []byte("var lib = {}"), content)
+	assert.False(t, mockServer.ObjectExists("site/stale.txt"))
+	assert.True(t, mockServer.ObjectExists("other/keep.txt"))
+
+	// a second run has nothing to do
+	result, err = Sync(ctx, src, b, "site", SyncOptions{Delete: true})
+	assert.NoError(t, err)
+	assert.Equal(t, SyncResult{Skipped: len(src)}, result)
+
+	// only the changed file is uploaded
+	src["css/site.css"] = &fstest.MapFile{Data: []byte("body { margin: 0 }")}
+	result, err = Sync(ctx, src, b, "site", SyncOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, SyncResult{Uploaded: 1, Skipped: len(src) - 1}, result)
+	content, _ = mockServer.ObjectContent("site/css/site.css")
+	assert.Equal(t, []byte("body { margin: 0 }"), content)
+}
@@ -21,11 +21,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/kelindar/s3/aws"
 	"golang.org/x/sync/errgroup"
@@ -47,6 +52,22 @@ type uploader struct {
 	// will be the Content-Type of the new object.
 	ContentType string
 
+	// UserAgent, if not an empty string, is sent
+	// with every request instead of DefaultUserAgent.
+	UserAgent string
+
+	// Limiter, if not nil, limits the
+	// rate of requests, including parts.
+	Limiter Limiter
+
+	// Logger, if not nil, logs every
+	// request at debug level.
+	Logger *slog.Logger
+
+	// Options configures the object created
+	// when the upload is completed.
+	Options UploadOptions
+
 	Bucket, Object string
 
 	Scheme string
@@ -65,6 +86,31 @@ type uploader struct {
 	// (For example, use Mbps = 25000 on a 25Gbps link, etc.)
 	Mbps int
 
+	// PartRetries is the number of times UploadFrom
+	// re-reads and re-uploads a part that failed before
+	// failing the whole upload. If it is zero, then
+	// DefaultPartRetries is used; if it is negative,
+	// failed parts are not retried.
+	//
+	// These retries are in addition to the retries
+	// of transient errors performed for every request.
+	PartRetries int
+
+	// MinPartOverride, if non-zero, replaces MinPartSize
+	// as the minimum size of every part but the last.
+	// AWS rejects parts smaller than MinPartSize, but
+	// some S3-compatible backends do not, so this can be
+	// used to upload smaller parts to such backends.
+	MinPartOverride int
+
+	// counters of the Bucket, if any
+	stats *counters
+
+	// pool of the part buffers of UploadFrom; if it
+	// is nil, then each upload gets its own, sized
+	// from the number of parts uploaded at once
+	bufpool *bufferPool
+
 	// upload ID
 	id string
 
@@ -95,15 +141,20 @@ type uploader struct {
 // MinPartSize returns the minimum part size
 // for the uploader.
 //
-// (The return value of MinPartSize is always s3.MinPartSize.)
+// (The return value of MinPartSize is s3.MinPartSize
+// unless MinPartOverride is set.)
 func (u *uploader) MinPartSize() int {
+	if u.MinPartOverride > 0 {
+		return u.MinPartOverride
+	}
 	return MinPartSize
 }
 
 type tagpart struct {
-	Num  int64  `xml:"PartNumber"`
-	ETag string `xml:"ETag"`
-	size int64  `xml:"-"`
+	Num      int64  `xml:"PartNumber"`
+	ETag     string `xml:"ETag"`
+	Checksum string `xml:"ChecksumSHA256,omitempty"`
+	size     int64  `xml:"-"`
 }
 
 func (u *uploader) req(ctx context.Context, method, uri, query string) *http.Request {
@@ -122,19 +173,39 @@ func (u *uploader) req(ctx context.Context, method, uri, query string) *http.Req
 
 	}
 	req, _ := http.NewRequestWithContext(ctx, method, obj.String(), nil)
+	setUserAgent(req, u.UserAgent)
 	return req
 }
 
-// Start begins a multipart upload.
-// Start must be called exactly once,
-// before any calls to WritePart are made.
-func (u *uploader) Start(ctx context.Context) error {
-	if u.started {
-		panic("multiple calls to uploader.Start()")
+// do performs req without retrying it,
+// waiting on u.Limiter first if it is set.
+func (u *uploader) do(req *http.Request) (*http.Response, error) {
+	if u.Limiter != nil {
+		if err := u.Limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	req, tr := traceRequest(u.Logger, req)
+	req = u.stats.trace(req)
+	start := time.Now()
+	u.stats.attempt(req, 1)
+	res, err := u.Client.Do(req)
+	logRequest(u.Logger, req, res, err, start, 0, tr)
+	if err == nil {
+		res.Body = u.stats.body(res)
 	}
+	return res, err
+}
+
+// init resolves the endpoint and client used by
+// the upload before it is started or resumed.
+func (u *uploader) init() error {
 	if u.Key.BaseURI == "" {
 		u.Scheme = "https"
 		u.Host = "s3." + u.Key.Region + ".amazonaws.com"
+		if zone, ok := directoryZone(u.Bucket); ok {
+			u.Host = "s3express-" + zone + "." + u.Key.Region + ".amazonaws.com"
+		}
 	} else {
 		uu, _ := url.Parse(u.Key.BaseURI)
 		u.Scheme = uu.Scheme
@@ -146,18 +217,35 @@ func (u *uploader) Start(ctx context.Context) error {
 	if u.Bucket == "" || u.Object == "" {
 		return fmt.Errorf("s3.Uploader.Bucket and s3.Uploader.Object must be present")
 	}
+	return nil
+}
+
+// Start begins a multipart upload.
+// Start must be called exactly once,
+// before any calls to WritePart are made.
+func (u *uploader) Start(ctx context.Context) error {
+	if u.started {
+		panic("multiple calls to uploader.Start()")
+	}
+	if err := u.init(); err != nil {
+		return err
+	}
 	req := u.req(ctx, "POST", u.Object, "uploads=")
 	if u.ContentType != "" {
 		req.Header.Set("Content-Type", u.ContentType)
 	}
-	u.Key.SignV4(req, nil)
-	res, err := u.Client.Do(req)
+	if u.Options.ChecksumSHA256 != "" {
+		req.Header.Set("x-amz-checksum-algorithm", "SHA256")
+	}
+	u.Options.apply(req)
+	u.Key.SignV4(req, nil, append(u.Options.signed(), "x-amz-checksum-algorithm")...)
+	res, err := u.do(req)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		return fmt.Errorf("s3.Uploader.Start: %s %q", res.Status, extractMessage(res.Body))
+		return responseError("s3.Uploader.Start", res)
 	}
 	rt := struct {
 		Bucket string `xml:"Bucket"`
@@ -179,6 +267,116 @@ func (u *uploader) Start(ctx context.Context) error {
 	return nil
 }
 
+// Resume continues the multipart upload with the given ID,
+// which was initiated elsewhere (e.g. by another process),
+// in place of calling Start. The ID is validated by listing
+// the parts of the upload, and any parts that were already
+// uploaded are included when the upload is completed.
+//
+// NextPart continues from the highest part number uploaded
+// so far, and uploading a part again replaces it.
+func (u *uploader) Resume(ctx context.Context, id string) error {
+	if u.started {
+		panic("uploader.Resume after Start or Resume")
+	}
+	if err := u.init(); err != nil {
+		return err
+	}
+	parts, err := u.listParts(ctx, "s3.Uploader.Resume", id)
+	if err != nil {
+		return err
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.id = id
+	u.parts = parts
+	u.maxpart = 0
+	for i := range parts {
+		u.maxpart = max(u.maxpart, parts[i].Num)
+	}
+	u.part = u.maxpart
+	u.started = true
+	return nil
+}
+
+// listParts lists every part of the
+// multipart upload with the given ID,
+// prefixing errors with op.
+func (u *uploader) listParts(ctx context.Context, op, id string) ([]tagpart, error) {
+	var parts []tagpart
+	marker := 0
+	for {
+		query := fmt.Sprintf("uploadId=%s", id)
+		if marker > 0 {
+			query += fmt.Sprintf("&part-number-marker=%d", marker)
+		}
+		req := u.req(ctx, "GET", u.Object, query)
+		u.Key.SignV4(req, nil)
+		res, err := flakyDo(u.Client, u.Limiter, u.Logger, u.stats, req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		rt := struct {
+			UploadID    string `xml:"UploadId"`
+			NextMarker  int    `xml:"NextPartNumberMarker"`
+			IsTruncated bool   `xml:"IsTruncated"`
+			Parts       []struct {
+				Num      int64  `xml:"PartNumber"`
+				ETag     string `xml:"ETag"`
+				Size     int64  `xml:"Size"`
+				Checksum string `xml:"ChecksumSHA256"`
+			} `xml:"Part"`
+		}{}
+		if res.StatusCode != 200 {
+			err = responseError(op, res)
+		} else if derr := xml.NewDecoder(res.Body).Decode(&rt); derr != nil {
+			err = fmt.Errorf("%s: decoding response: %w", op, derr)
+		}
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if rt.UploadID != id {
+			return nil, fmt.Errorf("returned upload ID %q not input upload ID %q?", rt.UploadID, id)
+		}
+		for _, p := range rt.Parts {
+			parts = append(parts, tagpart{Num: p.Num, ETag: p.ETag, Checksum: p.Checksum, size: p.Size})
+		}
+		if !rt.IsTruncated || rt.NextMarker <= marker {
+			return parts, nil
+		}
+		marker = rt.NextMarker
+	}
+}
+
+// addPart records an uploaded part, replacing any
+// earlier upload of the same part number.
+func (u *uploader) addPart(p tagpart) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if p.Num > u.maxpart {
+		u.maxpart = p.Num
+	}
+	for i := range u.parts {
+		if u.parts[i].Num == p.Num {
+			u.parts[i] = p
+			return
+		}
+	}
+	u.parts = append(u.parts, p)
+}
+
+// trimParts drops the parts numbered above n, which
+// were listed by Resume but are not part of the data
+// being uploaded, so that the final part is numbered
+// n+1 and stale parts are not included in the object.
+func (u *uploader) trimParts(n int64) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.parts = slices.DeleteFunc(u.parts, func(p tagpart) bool { return p.Num > n })
+	u.maxpart = n
+}
+
 // NextPart atomically increments the internal
 // part counter inside the uploader and returns
 // the next available part number.
@@ -200,15 +398,17 @@ func (u *uploader) NextPart() int64 {
 
 // Default upload configuration values
 const (
-	MinPartSize = 5 * 1024 * 1024
-	MaxParts    = 10000 // AWS limit
+	MinPartSize        = 5 * 1024 * 1024
+	MaxParts           = 10000 // AWS limit
+	DefaultPartRetries = 2
 )
 
-// calculatePartSize determines the optimal part size for a given total size
-func calculatePartSize(totalSize int64) int64 {
-	partSize := int64(MinPartSize)
-	if totalSize > 0 { // Keep doubling until we have ≤10,000 parts
-		for totalSize/partSize > MaxParts {
+// calculatePartSize determines the optimal part size for a given
+// total size, starting from the minimum part size minSize
+func calculatePartSize(totalSize, minSize int64) int64 {
+	partSize := minSize
+	if totalSize > 0 { // Keep doubling until we have ≤10,000 parts, including the final one
+		for (totalSize+partSize-1)/partSize > MaxParts {
 			partSize *= 2
 		}
 	}
@@ -228,10 +428,140 @@ func extractMessage(r io.Reader) string {
 	return "(no message)"
 }
 
+// Error is an unsuccessful response returned by S3.
+type Error struct {
+	Op         string // Op is the operation that failed, e.g. "s3 PUT".
+	StatusCode int    // StatusCode is the HTTP status code of the response.
+	Status     string // Status is the HTTP status of the response, e.g. "404 Not Found".
+	Code       string // Code is the S3 error code, e.g. "NoSuchKey", if one was returned.
+	Message    string // Message is the S3 error message, if one was returned.
+	RequestID  string // RequestID is the x-amz-request-id of the response.
+	HostID     string // HostID is the x-amz-id-2 of the response.
+
+	// SigningRegion and BucketRegion are set when S3 reported
+	// that the bucket is in another region than the one that
+	// the request was signed for, which S3 otherwise tends to
+	// surface as an opaque signature or redirect error.
+	SigningRegion, BucketRegion string
+}
+
+// Error implements error.Error
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = "(no message)"
+	}
+	if e.BucketRegion != "" {
+		msg += fmt.Sprintf(" (signing region %s but bucket is in region %s; set region to %s)", e.SigningRegion, e.BucketRegion, e.BucketRegion)
+	}
+	if e.RequestID == "" {
+		return fmt.Sprintf("%s: %s %s", e.Op, e.Status, msg)
+	}
+	return fmt.Sprintf("%s: %s %s (request id %s)", e.Op, e.Status, msg, e.RequestID)
+}
+
+// Is reports whether e matches target. Responses
+// indicating that the request rate was too high
+// match ErrThrottled.
+func (e *Error) Is(target error) bool {
+	if target != ErrThrottled {
+		return false
+	}
+	switch e.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return e.Code == "SlowDown" || e.Code == "ServiceUnavailable"
+	}
+	return false
+}
+
+// responseError produces an *Error from an unsuccessful
+// response, consuming (but not closing) its body.
+func responseError(op string, res *http.Response) *Error {
+	rt := struct {
+		Code      string `xml:"Code"`
+		Message   string `xml:"Message"`
+		RequestID string `xml:"RequestId"`
+		HostID    string `xml:"HostId"`
+		Region    string `xml:"Region"`
+	}{}
+	xml.NewDecoder(res.Body).Decode(&rt)
+	e := &Error{
+		Op:         op,
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Code:       rt.Code,
+		Message:    rt.Message,
+		RequestID:  res.Header.Get("x-amz-request-id"),
+		HostID:     res.Header.Get("x-amz-id-2"),
+	}
+	if e.RequestID == "" {
+		e.RequestID = rt.RequestID
+	}
+	if e.HostID == "" {
+		e.HostID = rt.HostID
+	}
+	if res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusMovedPermanently {
+		region := rt.Region
+		if region == "" {
+			region = res.Header.Get("x-amz-bucket-region")
+		}
+		if signing := signingRegion(res.Request); region != "" && signing != "" && signing != region {
+			e.SigningRegion, e.BucketRegion = signing, region
+		}
+	}
+	return e
+}
+
+// ErrorMapper maps the status code and S3 error code (which is
+// empty for responses without a body, such as those to HEAD) of
+// an unsuccessful response to the error that is returned for it.
+// Returning nil keeps the default mapping.
+type ErrorMapper func(status int, code string) error
+
+// mapError returns the error for an unsuccessful response to op,
+// as mapped by mapper if it is not nil, and otherwise fs.ErrNotExist
+// for a 404, fs.ErrPermission for a 403 and an *Error for the rest.
+func mapError(mapper ErrorMapper, op string, res *http.Response) error {
+	e := responseError(op, res)
+	if mapper != nil {
+		if err := mapper(e.StatusCode, e.Code); err != nil {
+			return err
+		}
+	}
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return fs.ErrNotExist
+	case http.StatusForbidden:
+		return fs.ErrPermission
+	}
+	return e
+}
+
+// signingRegion returns the region of the credential
+// scope that req was signed with, or "" if it is unknown.
+func signingRegion(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	credential := req.URL.Query().Get("X-Amz-Credential")
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		_, credential, _ = strings.Cut(auth, "Credential=")
+		credential, _, _ = strings.Cut(credential, ",")
+	}
+	// <key>/<date>/<region>/<service>/aws4_request
+	if parts := strings.Split(credential, "/"); len(parts) == 5 {
+		return parts[2]
+	}
+	return ""
+}
+
 // Upload uploads the part number num from
 // the ReadCloser r, which must return exactly size bytes of data.
 // S3 prohibits multi-part upload parts smaller than 5MB (except
-// for the final bytes), so size must be at least 5MB.
+// for the final bytes), so size must be at least 5MB, unless
+// MinPartOverride is set.
 //
 // It is safe to call Upload from multiple goroutines
 // simultaneously. However, calls to Upload must be
@@ -241,37 +571,39 @@ func (u *uploader) Upload(num int64, contents []byte) error {
 	switch {
 	case !u.started:
 		panic("s3.uploader.UploadPart before Start()")
-	case len(contents) < MinPartSize:
-		return fmt.Errorf("UploadPart size %d below min part size %d", len(contents), MinPartSize)
+	case len(contents) < u.MinPartSize():
+		return fmt.Errorf("UploadPart size %d below min part size %d", len(contents), u.MinPartSize())
 	}
 	return u.upload(context.Background(), num, contents)
 }
 
 func (u *uploader) upload(ctx context.Context, num int64, contents []byte) error {
 	req := u.req(ctx, "PUT", u.Object, fmt.Sprintf("partNumber=%d&uploadId=%s", num, u.id))
-	u.Key.SignV4(req, contents)
-	res, err := flakyDo(u.Client, req)
+	var checksum string
+	if u.Options.ChecksumSHA256 != "" {
+		checksum = partSHA256(contents)
+		req.Header.Set("x-amz-checksum-sha256", checksum)
+	}
+	u.Key.SignV4(req, contents, "x-amz-checksum-sha256")
+	u.Options.expect(req)
+	res, err := flakyDo(u.Client, u.Limiter, u.Logger, u.stats, req)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		return fmt.Errorf("UploadPart: %s %q", res.Status, extractMessage(res.Body))
+		return responseError("UploadPart", res)
 	}
 	etag := res.Header.Get("ETag")
 	if etag == "" {
 		return fmt.Errorf("s3.Uploader.UploadPart: response missing ETag?")
 	}
-	u.lock.Lock()
-	if num > u.maxpart {
-		u.maxpart = num
-	}
-	u.parts = append(u.parts, tagpart{
-		Num:  num,
-		ETag: etag,
-		size: int64(len(contents)),
+	u.addPart(tagpart{
+		Num:      num,
+		ETag:     etag,
+		Checksum: checksum,
+		size:     int64(len(contents)),
 	})
-	u.lock.Unlock()
 	return nil
 }
 
@@ -279,12 +611,55 @@ func (u *uploader) uploadWithContext(ctx context.Context, num int64, contents []
 	switch {
 	case !u.started:
 		panic("s3.uploader.UploadPart before Start()")
-	case len(contents) < MinPartSize:
-		return fmt.Errorf("UploadPart size %d below min part size %d", len(contents), MinPartSize)
+	case len(contents) < u.MinPartSize():
+		return fmt.Errorf("UploadPart size %d below min part size %d", len(contents), u.MinPartSize())
 	}
 	return u.upload(ctx, num, contents)
 }
 
+// uploadReader uploads buf, which holds the first part, and
+// then the rest of r as the parts of the upload, one after the
+// other, reading every part into a buffer of the same size as
+// buf. The last part, which may be shorter, is uploaded by Close.
+func (u *uploader) uploadReader(ctx context.Context, r io.Reader, buf []byte) error {
+	tee := func(p []byte) error {
+		if u.Options.Tee == nil {
+			return nil
+		}
+		if _, err := u.Options.Tee.Write(p); err != nil {
+			return fmt.Errorf("writing to tee: %w", err)
+		}
+		return nil
+	}
+	cur, next := buf, make([]byte, len(buf))
+	for num := int64(1); ; num++ {
+		if err := tee(cur); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(r, next)
+		switch {
+		case n == 0 && errors.Is(err, io.EOF):
+			return u.Close(ctx, cur)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			if err := u.uploadWithContext(ctx, num, cur); err != nil {
+				return err
+			}
+			if err := tee(next[:n]); err != nil {
+				return err
+			}
+			return u.Close(ctx, next[:n])
+		case err != nil:
+			return err
+		case num >= MaxParts:
+			return fmt.Errorf("s3.Uploader: contents exceed %d parts of %d bytes", MaxParts, len(buf))
+		}
+		if err := u.uploadWithContext(ctx, num, cur); err != nil {
+			return err
+		}
+		cur, next = next, cur
+	}
+}
+
 // CopyFrom performs a server side copy for the part number `num`.
 //
 // Set `start` and `end` to `0` to copy the entire source object.
@@ -312,8 +687,8 @@ func (u *uploader) CopyFrom(ctx context.Context, num int64, source *Reader, star
 		}
 		size = end - start
 	}
-	if size < MinPartSize {
-		return fmt.Errorf("CopyFrom size %d below min part size %d", size, MinPartSize)
+	if size < int64(u.MinPartSize()) {
+		return fmt.Errorf("CopyFrom size %d below min part size %d", size, u.MinPartSize())
 	}
 
 	// update the max part before launching anything
@@ -349,14 +724,14 @@ func (u *uploader) copy(ctx context.Context, num int64, source *Reader, start in
 		req.Header.Add("x-amz-copy-source-range", fmt.Sprintf("bytes=%d-%d", start, end-1))
 	}
 	u.Key.SignV4(req, nil)
-	res, err := flakyDo(u.Client, req)
+	res, err := flakyDo(u.Client, u.Limiter, u.Logger, u.stats, req)
 	if err != nil {
 		u.noteErr(err)
 		return
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		u.noteErr(fmt.Errorf("CopyFrom: %s %q", res.Status, extractMessage(res.Body)))
+		u.noteErr(responseError("CopyFrom", res))
 		return
 	}
 	var etag string
@@ -370,13 +745,11 @@ func (u *uploader) copy(ctx context.Context, num int64, source *Reader, start in
 		u.noteErr(fmt.Errorf("s3.Uploader.CopyFrom: response missing ETag?"))
 		return
 	}
-	u.lock.Lock()
-	u.parts = append(u.parts, tagpart{
+	u.addPart(tagpart{
 		Num:  num,
 		ETag: etag,
 		size: size,
 	})
-	u.lock.Unlock()
 }
 
 // CompletedParts returns the number of parts
@@ -419,6 +792,13 @@ func (u *uploader) Size() int64 {
 // (If size is zero, then r may be nil, in which case no final
 // part is uploaded before the multi-part object is finalized.)
 //
+// If u.Options.ChecksumSHA256 is set, Close verifies the
+// checksum of the completed object against it.
+//
+// Every request made by Close, as well as waiting for any
+// outstanding CopyFrom operations, honors the deadline and
+// cancellation of ctx.
+//
 // Close will panic if Start has never been called
 // or if Close has already been called and returned successfully.
 func (u *uploader) Close(ctx context.Context, final []byte) error {
@@ -440,7 +820,9 @@ func (u *uploader) Close(ctx context.Context, final []byte) error {
 	}
 	// wait for any/all CopyFrom operations to finish;
 	// after this we know u.parts will be fully up-to-date
-	u.bg.Wait()
+	if err := u.wait(ctx); err != nil {
+		return fmt.Errorf("s3.Uploader.Close: %w", err)
+	}
 	if u.asyncerr != nil {
 		return u.asyncerr
 	}
@@ -450,7 +832,16 @@ func (u *uploader) Close(ctx context.Context, final []byte) error {
 		return u.parts[i].Num < u.parts[j].Num
 	})
 
-	req := u.req(ctx, "POST", u.Object, fmt.Sprintf("uploadId=%s", u.id))
+	cctx, cl := ctx, u.Client
+	if d := u.Options.CompleteTimeout; d > 0 {
+		var cancel context.CancelFunc
+		cctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+		var done func()
+		cl, done = withTimeout(u.Client, d)
+		defer done()
+	}
+	req := u.req(cctx, "POST", u.Object, fmt.Sprintf("uploadId=%s", u.id))
 	req.Header.Set("Content-Type", "application/xml")
 	buf, err := xml.Marshal(&struct {
 		XMLName xml.Name  `xml:"CompleteMultipartUpload"`
@@ -465,13 +856,13 @@ func (u *uploader) Close(ctx context.Context, final []byte) error {
 	}
 	u.Key.SignV4(req, buf)
 
-	res, err := flakyDo(u.Client, req)
+	res, err := flakyDo(cl, u.Limiter, u.Logger, u.stats, req)
 	if err != nil {
 		return fmt.Errorf("s3.Uploader.Close: %w", err)
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		return fmt.Errorf("s3.Uploader.Close: %s %q", res.Status, extractMessage(res.Body))
+		return responseError("s3.Uploader.Close", res)
 	}
 
 	// This is a bit nasty:
@@ -504,6 +895,9 @@ func (u *uploader) Close(ctx context.Context, final []byte) error {
 	}
 	u.finalETag = rt.ETag
 	u.finished = true
+	if u.Options.ChecksumSHA256 != "" {
+		return u.verifyChecksum(ctx)
+	}
 	return nil
 }
 
@@ -514,9 +908,31 @@ func (u *uploader) ETag() string {
 	return u.finalETag
 }
 
+// withTimeout returns a copy of cl whose Timeout and, if
+// its transport is an *http.Transport, ResponseHeaderTimeout
+// are raised to d if they are shorter, along with a function
+// that releases the idle connections of a copied transport.
+func withTimeout(cl *http.Client, d time.Duration) (*http.Client, func()) {
+	out := *cl
+	if out.Timeout > 0 && out.Timeout < d {
+		out.Timeout = d
+	}
+	tr, ok := out.Transport.(*http.Transport)
+	if !ok || tr.ResponseHeaderTimeout <= 0 || tr.ResponseHeaderTimeout >= d {
+		return &out, func() {}
+	}
+	tr = tr.Clone()
+	tr.ResponseHeaderTimeout = d
+	out.Transport = tr
+	return &out, tr.CloseIdleConnections
+}
+
+// maxParallel is the default number
+// of parts uploaded at once.
+const maxParallel = 40
+
 func (u *uploader) idealParallel(parts int64) int {
-	const max = 40
-	res := max
+	res := maxParallel
 	if u.Mbps != 0 {
 		// guess 640Mbps = 80MB/s per connection
 		// (S3 guidelines say 85-90MB/s)
@@ -544,21 +960,26 @@ func (u *uploader) idealParallel(parts int64) int {
 // and returns without an error, then the state of
 // the Uploader is reset so that Start may be called
 // again to re-try the upload.
+//
+// Like Close, Abort gives up once ctx is done, in which
+// case the upload is left in progress.
 func (u *uploader) Abort(ctx context.Context) error {
 	if !u.started || u.finished {
 		return nil
 	}
-	u.bg.Wait()
+	if err := u.wait(ctx); err != nil {
+		return fmt.Errorf("s3.Uploader.Abort: %w", err)
+	}
 	req := u.req(ctx, "DELETE", u.Object, fmt.Sprintf("uploadId=%s", u.id))
 	u.Key.SignV4(req, nil)
 
-	res, err := u.Client.Do(req)
+	res, err := u.do(req)
 	if err != nil {
 		return fmt.Errorf("s3.Uploader.Abort: %w", err)
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 204 {
-		return fmt.Errorf("s3.Uploader.Abort: %s %s", res.Status, extractMessage(res.Body))
+		return responseError("s3.Uploader.Abort", res)
 	}
 
 	// reset internal state
@@ -570,16 +991,256 @@ func (u *uploader) Abort(ctx context.Context) error {
 	return nil
 }
 
+// wait waits for the background CopyFrom operations
+// to finish, or for ctx to be done, whichever is first.
+func (u *uploader) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		u.bg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// partBuffers pools the part buffers of UploadFrom
+// so that they are reused across parts and uploads.
+var partBuffers sync.Pool
+
+// bufferPool lends the buffers of partBuffers and caps
+// the number of buffers that are held at once.
+type bufferPool struct {
+	sem    chan struct{} // one token per buffer held
+	allocs atomic.Int64  // buffers allocated because none of a sufficient size was pooled
+	peak   atomic.Int64  // highest number of buffers held at once
+}
+
+func newBufferPool(max int) *bufferPool {
+	return &bufferPool{sem: make(chan struct{}, max)}
+}
+
+// get returns a buffer of size bytes, reusing a pooled
+// one if it is large enough, once fewer than the maximum
+// number of buffers are held, or fails if ctx is done.
+// The buffer must be returned with put.
+func (p *bufferPool) get(ctx context.Context, size int64) ([]byte, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for held := int64(len(p.sem)); ; {
+		peak := p.peak.Load()
+		if held <= peak || p.peak.CompareAndSwap(peak, held) {
+			break
+		}
+	}
+	if b, ok := partBuffers.Get().(*[]byte); ok && int64(cap(*b)) >= size {
+		return (*b)[:size], nil
+	}
+	p.allocs.Add(1)
+	return make([]byte, size), nil
+}
+
+// put returns buf, which was obtained with get, to the pool.
+func (p *bufferPool) put(buf []byte) {
+	partBuffers.Put(&buf)
+	<-p.sem
+}
+
+// buffers returns the pool of the part buffers of
+// an upload of which parallel parts run at once.
+func (u *uploader) buffers(parallel int) *bufferPool {
+	if u.bufpool == nil {
+		return newBufferPool(parallel)
+	}
+	return u.bufpool
+}
+
+func (u *uploader) partRetries() int {
+	switch {
+	case u.PartRetries < 0:
+		return 0
+	case u.PartRetries == 0:
+		return DefaultPartRetries
+	default:
+		return u.PartRetries
+	}
+}
+
+// partTee copies parts that are read concurrently
+// to a writer in the order of their part numbers.
+type partTee struct {
+	w    io.Writer
+	lock sync.Mutex
+	cond sync.Cond
+	next int64 // next part number to be written
+	err  error // sticky error; stops the parts waiting their turn
+}
+
+func newPartTee(w io.Writer) *partTee {
+	t := &partTee{w: w, next: 1}
+	t.cond.L = &t.lock
+	return t
+}
+
+// write waits for the parts preceding num
+// to be written and then writes p.
+func (t *partTee) write(num int64, p []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for t.next != num && t.err == nil {
+		t.cond.Wait()
+	}
+	if t.err != nil {
+		return t.err
+	}
+	if _, err := t.w.Write(p); err != nil {
+		t.err = fmt.Errorf("writing to tee: %w", err)
+	}
+	t.next++
+	t.cond.Broadcast()
+	return t.err
+}
+
+// fail releases any parts waiting their turn,
+// making them return err.
+func (t *partTee) fail(err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+	t.cond.Broadcast()
+}
+
+// uploadPart reads the part number num from r at offset off
+// into buf and uploads it, re-reading and re-uploading the
+// part up to u.partRetries() times if the upload fails.
+// If tee is not nil, the part is written to it once read.
+func (u *uploader) uploadPart(ctx context.Context, r io.ReaderAt, tee *partTee, gate *aimd, buf []byte, num, off int64) error {
+	var err error
+	for attempt := 0; attempt <= u.partRetries(); attempt++ {
+		n, rerr := r.ReadAt(buf, off)
+		if n < len(buf) {
+			if rerr == nil || errors.Is(rerr, io.EOF) {
+				rerr = io.ErrUnexpectedEOF
+			}
+			return rerr
+		}
+		// the tee waits for the preceding parts, which may
+		// still need a slot, so it must not hold one itself
+		if tee != nil && attempt == 0 {
+			if err := tee.write(num, buf); err != nil {
+				return err
+			}
+		}
+		if err := gate.acquire(ctx); err != nil {
+			return err
+		}
+		err = u.uploadWithContext(ctx, num, buf)
+		gate.release(err)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// aimd limits the number of concurrent part uploads,
+// adapting to throttling with additive increase and
+// multiplicative decrease: the limit is halved (down
+// to 1) every time a part is throttled, and raised by
+// one, up to max, once limit parts have succeeded.
+type aimd struct {
+	lock     sync.Mutex
+	cond     sync.Cond
+	limit    int // current number of concurrent uploads allowed
+	max      int // initial and highest limit
+	inflight int // number of uploads in progress
+	acked    int // successful uploads since the limit last changed
+}
+
+func newAIMD(max int) *aimd {
+	a := &aimd{limit: max, max: max}
+	a.cond.L = &a.lock
+	return a
+}
+
+// acquire waits until another upload may start,
+// or until ctx is canceled.
+func (a *aimd) acquire(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		a.lock.Lock()
+		a.cond.Broadcast()
+		a.lock.Unlock()
+	})
+	defer stop()
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for a.inflight >= a.limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		a.cond.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	a.inflight++
+	return nil
+}
+
+// release ends an upload that finished with err,
+// adjusting the limit if it succeeded or was throttled.
+func (a *aimd) release(err error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.inflight--
+	switch {
+	case errors.Is(err, ErrThrottled):
+		a.limit = max(1, a.limit/2)
+		a.acked = 0
+	case err == nil:
+		if a.acked++; a.acked >= a.limit && a.limit < a.max {
+			a.limit++
+			a.acked = 0
+		}
+	}
+	a.cond.Broadcast()
+}
+
 // UploadFrom is a utility method that performs
 // a parallel upload of an io.ReaderAt of a given size.
 //
 // UploadFrom closes the Uploader after uploading
 // the entirety of the contents of r.
 //
+// Each of the parallel workers (see u.Mbps) holds a
+// single part buffer, taken from a pool shared by all
+// uploads, so the peak memory use of UploadFrom is
+// parallel * partSize regardless of the number of parts.
+//
+// If parts are throttled with a 503 SlowDown, fewer
+// of the workers upload at once, down to a single one,
+// and more are let through again as parts succeed.
+//
+// If u.Options.Tee is set, it receives the contents
+// of r in order as they are read.
+//
+// If the upload was resumed, the parts that were listed
+// by Resume and are numbered above the parts of r are
+// left out of the object.
+//
 // UploadFrom is not safe to call concurrently with
 // UploadPart or Close.
 func (u *uploader) UploadFrom(ctx context.Context, r io.ReaderAt, size int64) error {
-	partSize := calculatePartSize(size)
+	partSize := calculatePartSize(size, int64(u.MinPartSize()))
 	nonfinal := size / partSize
 	endparts := nonfinal * partSize
 	offset := int64(0)
@@ -588,9 +1249,33 @@ func (u *uploader) UploadFrom(ctx context.Context, r io.ReaderAt, size int64) er
 	g, uploadCtx := errgroup.WithContext(ctx)
 	g.SetLimit(parallel)
 
+	// throttled parts lower the number of
+	// workers that may upload at once
+	gate := newAIMD(parallel)
+
+	// parts waiting for their turn to be written
+	// to the tee must be released if any part fails
+	var tee *partTee
+	fail := func(err error) error { return err }
+	if u.Options.Tee != nil {
+		tee = newPartTee(u.Options.Tee)
+		fail = func(err error) error {
+			tee.fail(err)
+			return err
+		}
+	}
+
+	bufs := u.buffers(parallel)
 	for i := 0; i < parallel; i++ {
 		g.Go(func() error {
-			buf := make([]byte, partSize)
+			// the buffer is taken before claiming any part,
+			// so that every part claimed before has one and
+			// the parts waiting for the tee cannot hold all
+			buf, err := bufs.get(uploadCtx, partSize)
+			if err != nil {
+				return fail(err)
+			}
+			defer bufs.put(buf)
 			for {
 				loff := atomic.AddInt64(&offset, partSize) - partSize
 				if loff >= endparts {
@@ -600,22 +1285,15 @@ func (u *uploader) UploadFrom(ctx context.Context, r io.ReaderAt, size int64) er
 				// Check if context was cancelled
 				select {
 				case <-uploadCtx.Done():
-					return uploadCtx.Err()
+					return fail(uploadCtx.Err())
 				default:
 				}
 
 				// 1-based part numbers
 				part := (loff / partSize) + 1
-				n, err := r.ReadAt(buf, loff)
-				if int64(n) < partSize {
-					if err == nil || errors.Is(err, io.EOF) {
-						err = io.ErrUnexpectedEOF
-					}
-					return err
-				}
-				err = u.uploadWithContext(uploadCtx, part, buf)
+				err := u.uploadPart(uploadCtx, r, tee, gate, buf, part, loff)
 				if err != nil {
-					return fmt.Errorf("s3.UploadReaderAt part %d: %w", part, err)
+					return fail(fmt.Errorf("s3.UploadReaderAt part %d: %w", part, err))
 				}
 			}
 			return nil
@@ -625,6 +1303,7 @@ func (u *uploader) UploadFrom(ctx context.Context, r io.ReaderAt, size int64) er
 	if err := g.Wait(); err != nil {
 		return err
 	}
+	u.trimParts(nonfinal)
 
 	var tail []byte
 	tailsize := int(size - endparts)
@@ -637,6 +1316,11 @@ func (u *uploader) UploadFrom(ctx context.Context, r io.ReaderAt, size int64) er
 			}
 			return err
 		}
+		if u.Options.Tee != nil {
+			if _, err := u.Options.Tee.Write(tail); err != nil {
+				return fmt.Errorf("writing to tee: %w", err)
+			}
+		}
 	}
 	return u.Close(ctx, tail)
 }
@@ -17,11 +17,23 @@ package s3
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/kelindar/s3/aws"
 	"github.com/kelindar/s3/mock"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
 )
 
 // Test the public API through Bucket.WriteFrom
@@ -115,14 +127,24 @@ func TestWriteFrom(t *testing.T) {
 // Test calculatePartSize function
 func TestPartSize(t *testing.T) {
 	// Test small size
-	partSize := calculatePartSize(MinPartSize)
+	partSize := calculatePartSize(MinPartSize, MinPartSize)
 	assert.Equal(t, int64(MinPartSize), partSize)
 
 	// Test large size that requires multiple parts
 	largeSize := int64(MinPartSize) * MaxParts * 2 // Requires doubling part size
-	partSize = calculatePartSize(largeSize)
+	partSize = calculatePartSize(largeSize, MinPartSize)
 	assert.Greater(t, partSize, int64(MinPartSize))
 	assert.LessOrEqual(t, largeSize/partSize, int64(MaxParts))
+
+	// Test a short final part that would be part number MaxParts+1
+	overSize := int64(MinPartSize)*MaxParts + 1
+	partSize = calculatePartSize(overSize, MinPartSize)
+	assert.Equal(t, int64(MinPartSize)*2, partSize)
+	assert.LessOrEqual(t, (overSize+partSize-1)/partSize, int64(MaxParts))
+
+	// Test exactly MaxParts full parts, which needs no doubling
+	partSize = calculatePartSize(int64(MinPartSize)*MaxParts, MinPartSize)
+	assert.Equal(t, int64(MinPartSize), partSize)
 }
 
 // Test multipart upload through mock server directly
@@ -590,3 +612,598 @@ func TestUploader(t *testing.T) {
 		assert.Equal(t, int64(len(data)), u2.Size())
 	})
 }
+
+// roundTripFunc adapts a function to an http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return fn(req) }
+
+func TestPartRetry(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	// fail the first attempt at uploading part 2 with a non-retryable status
+	var failed atomic.Bool
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPut && req.URL.Query().Get("partNumber") == "2" && failed.CompareAndSwap(false, true) {
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Status:     "400 Bad Request",
+				Body:       io.NopCloser(strings.NewReader("<Error><Message>injected</Message></Error>")),
+				Request:    req,
+			}, nil
+		}
+		return DefaultClient.Transport.RoundTrip(req)
+	})}
+
+	testData := make([]byte, MinPartSize*3+1000)
+	for i := range testData {
+		testData[i] = byte(i % 256)
+	}
+
+	u := &uploader{Key: key, Client: client, Bucket: bucket, Object: "test/retry.bin"}
+	assert.NoError(t, u.Start(context.Background()))
+	assert.NoError(t, u.UploadFrom(context.Background(), bytes.NewReader(testData), int64(len(testData))))
+	assert.True(t, failed.Load())
+
+	content, found := mockServer.ObjectContent("test/retry.bin")
+	assert.True(t, found)
+	assert.Equal(t, testData, content)
+
+	// without retries, the same failure fails the upload
+	failed.Store(false)
+	u = &uploader{Key: key, Client: client, Bucket: bucket, Object: "test/noretry.bin", PartRetries: -1}
+	assert.NoError(t, u.Start(context.Background()))
+	assert.Error(t, u.UploadFrom(context.Background(), bytes.NewReader(testData), int64(len(testData))))
+}
+
+func TestUploadThrottled(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	// count the part uploads that were throttled
+	var throttled atomic.Int32
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		res, err := DefaultClient.Transport.RoundTrip(req)
+		if err == nil && res.StatusCode == http.StatusServiceUnavailable {
+			throttled.Add(1)
+		}
+		return res, err
+	})}
+
+	testData := make([]byte, 16*1024+100)
+	for i := range testData {
+		testData[i] = byte(i % 256)
+	}
+
+	mockServer.ThrottleParts(5)
+	u := &uploader{Key: key, Client: client, Bucket: bucket, Object: "test/throttled.bin", MinPartOverride: 1024}
+	assert.NoError(t, u.Start(context.Background()))
+	assert.NoError(t, u.UploadFrom(context.Background(), bytes.NewReader(testData), int64(len(testData))))
+	assert.Equal(t, int32(5), throttled.Load())
+
+	content, found := mockServer.ObjectContent("test/throttled.bin")
+	assert.True(t, found)
+	assert.Equal(t, testData, content)
+}
+
+func TestUploadThrottledTee(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	// throttle about a third of the part uploads
+	var lock sync.Mutex
+	rnd := rand.New(rand.NewSource(1))
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		lock.Lock()
+		throttle := rnd.Intn(10) < 3
+		lock.Unlock()
+		if throttle && req.Method == http.MethodPut && req.URL.Query().Has("partNumber") {
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Status:     "503 Service Unavailable",
+				Body:       io.NopCloser(strings.NewReader("<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>")),
+				Request:    req,
+			}, nil
+		}
+		return DefaultClient.Transport.RoundTrip(req)
+	})}
+
+	testData := make([]byte, 300*1024)
+	for i := range testData {
+		testData[i] = byte(i % 251)
+	}
+
+	for i := 0; i < 5; i++ {
+		var tee bytes.Buffer
+		u := &uploader{Key: key, Client: client, Bucket: bucket, Object: "test/tee.bin", MinPartOverride: 1024,
+			Options: UploadOptions{Tee: &tee}, PartRetries: 100}
+		assert.NoError(t, u.Start(context.Background()))
+
+		// a deadlock between the tee and the
+		// throttled parts would never return
+		done := make(chan error, 1)
+		go func() { done <- u.UploadFrom(context.Background(), bytes.NewReader(testData), int64(len(testData))) }()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("UploadFrom did not return")
+		}
+		assert.Equal(t, testData, tee.Bytes())
+
+		content, found := mockServer.ObjectContent("test/tee.bin")
+		assert.True(t, found)
+		assert.Equal(t, testData, content)
+	}
+}
+
+func TestUploadCompleteTimeout(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	// parts are answered quickly, but not the completion
+	tr := DefaultClient.Transport.(*http.Transport).Clone()
+	tr.ResponseHeaderTimeout = 100 * time.Millisecond
+	defer tr.CloseIdleConnections()
+	client := &http.Client{Transport: tr}
+	mockServer.DelayComplete(300 * time.Millisecond)
+
+	upload := func(object string, opts UploadOptions) error {
+		u := &uploader{Key: key, Client: client, Bucket: bucket, Object: object, Options: opts, MinPartOverride: 1024}
+		if err := u.Start(context.Background()); err != nil {
+			return err
+		}
+		if err := u.Upload(1, bytes.Repeat([]byte("a"), 1024)); err != nil {
+			return err
+		}
+		return u.Close(context.Background(), []byte("b"))
+	}
+
+	t.Run("default", func(t *testing.T) {
+		assert.Error(t, upload("test/short.bin", UploadOptions{}))
+		_, found := mockServer.ObjectContent("test/short.bin")
+		assert.False(t, found)
+	})
+
+	t.Run("longer", func(t *testing.T) {
+		assert.NoError(t, upload("test/long.bin", UploadOptions{CompleteTimeout: 5 * time.Second}))
+		content, found := mockServer.ObjectContent("test/long.bin")
+		assert.True(t, found)
+		assert.Equal(t, append(bytes.Repeat([]byte("a"), 1024), 'b'), content)
+		assert.Equal(t, 100*time.Millisecond, tr.ResponseHeaderTimeout)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		u := &uploader{Key: key, Client: client, Bucket: bucket, Object: "test/ctx.bin", MinPartOverride: 1024,
+			Options: UploadOptions{CompleteTimeout: 5 * time.Second}}
+		assert.NoError(t, u.Start(context.Background()))
+		assert.ErrorIs(t, u.Close(ctx, []byte("c")), context.DeadlineExceeded)
+	})
+}
+
+func TestAIMD(t *testing.T) {
+	ctx := context.Background()
+	slowdown := &Error{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown"}
+
+	// blocked reports whether acquire would block
+	blocked := func(a *aimd) bool {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if err := a.acquire(ctx); err != nil {
+			return true
+		}
+		a.release(nil)
+		return false
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		a := newAIMD(4)
+		for range 4 {
+			assert.NoError(t, a.acquire(ctx))
+		}
+		assert.True(t, blocked(a))
+
+		// a released slot lets another upload start
+		a.release(nil)
+		assert.NoError(t, a.acquire(ctx))
+	})
+
+	t.Run("decrease", func(t *testing.T) {
+		a := newAIMD(8)
+		for range 3 {
+			assert.NoError(t, a.acquire(ctx))
+			a.release(&RetryError{Attempts: 2, StatusCode: 503, Err: slowdown})
+		}
+		assert.Equal(t, 1, a.limit)
+
+		// it never goes below a single upload
+		assert.NoError(t, a.acquire(ctx))
+		a.release(slowdown)
+		assert.Equal(t, 1, a.limit)
+
+		// other errors leave the limit alone
+		assert.NoError(t, a.acquire(ctx))
+		a.release(io.ErrUnexpectedEOF)
+		assert.Equal(t, 1, a.limit)
+	})
+
+	t.Run("increase", func(t *testing.T) {
+		a := newAIMD(3)
+		assert.NoError(t, a.acquire(ctx))
+		a.release(slowdown)
+		assert.Equal(t, 1, a.limit)
+
+		// the limit is raised by one for every limit successes
+		for _, want := range []int{2, 2, 3, 3, 3, 3} {
+			assert.NoError(t, a.acquire(ctx))
+			a.release(nil)
+			assert.Equal(t, want, a.limit)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		a := newAIMD(1)
+		assert.NoError(t, a.acquire(ctx))
+
+		ctx, cancel := context.WithCancel(ctx)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		assert.ErrorIs(t, a.acquire(ctx), context.Canceled)
+	})
+}
+
+func TestUploadTee(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	b := NewBucket(key, bucket)
+	ctx := context.Background()
+
+	t.Run("multipart", func(t *testing.T) {
+		testData := make([]byte, MinPartSize*4+1000)
+		rand.New(rand.NewSource(1)).Read(testData)
+
+		h := sha256.New()
+		err := b.WriteFrom(ctx, "tee/large.bin", bytes.NewReader(testData), int64(len(testData)), UploadOptions{Tee: h})
+		assert.NoError(t, err)
+		want := sha256.Sum256(testData)
+		assert.Equal(t, want[:], h.Sum(nil))
+
+		content, found := mockServer.ObjectContent("tee/large.bin")
+		assert.True(t, found)
+		assert.Equal(t, testData, content)
+	})
+
+	t.Run("put", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := b.Write(ctx, "tee/small.txt", []byte("hello"), UploadOptions{Tee: &buf})
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", buf.String())
+	})
+
+	t.Run("failing tee", func(t *testing.T) {
+		testData := make([]byte, MinPartSize*4)
+		err := b.WriteFrom(ctx, "tee/failed.bin", bytes.NewReader(testData), int64(len(testData)), UploadOptions{Tee: failingWriter{}})
+		assert.ErrorIs(t, err, errTeeFailed)
+	})
+}
+
+var errTeeFailed = errors.New("tee failed")
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errTeeFailed }
+
+func TestUploadResume(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+	ctx := context.Background()
+
+	part1 := bytes.Repeat([]byte{1}, 1024)
+	part2 := bytes.Repeat([]byte{2}, 1024)
+	part3 := []byte("the final part")
+
+	t.Run("parts", func(t *testing.T) {
+		id := mockServer.CreateMultipartUpload("resume/parts.bin")
+
+		// one process uploads the first part...
+		u1 := &uploader{Key: key, Bucket: bucket, Object: "resume/parts.bin", MinPartOverride: 1024}
+		assert.NoError(t, u1.Resume(ctx, id))
+		assert.Equal(t, id, u1.ID())
+		assert.NoError(t, u1.Upload(u1.NextPart(), part1))
+
+		// ...and another uploads the rest and completes the upload
+		u2 := &uploader{Key: key, Bucket: bucket, Object: "resume/parts.bin", MinPartOverride: 1024}
+		assert.NoError(t, u2.Resume(ctx, id))
+		assert.Equal(t, 1, u2.CompletedParts())
+		assert.Equal(t, int64(2), u2.NextPart())
+		assert.NoError(t, u2.Upload(2, part2))
+		assert.NoError(t, u2.Close(ctx, part3))
+		assert.Equal(t, int64(len(part1)+len(part2)+len(part3)), u2.Size())
+
+		content, ok := mockServer.ObjectContent("resume/parts.bin")
+		assert.True(t, ok)
+		assert.Equal(t, slices.Concat(part1, part2, part3), content)
+		assert.Empty(t, mockServer.ListMultipartUploads())
+		assert.False(t, slices.ContainsFunc(mockServer.GetRequestsWithMethod("POST"), func(r mock.RequestLog) bool {
+			return r.Query == "uploads="
+		}), "Resume must not initiate a new upload")
+	})
+
+	t.Run("reupload", func(t *testing.T) {
+		id := mockServer.CreateMultipartUpload("resume/again.bin")
+		u1 := &uploader{Key: key, Bucket: bucket, Object: "resume/again.bin", MinPartOverride: 1024}
+		assert.NoError(t, u1.Resume(ctx, id))
+		assert.NoError(t, u1.Upload(1, part2))
+
+		// uploading a part again replaces it rather than duplicating it
+		data := slices.Concat(part1, part3)
+		b := NewBucket(key, bucket)
+		b.MinPartOverride = 1024
+		assert.NoError(t, b.ResumeUpload(ctx, "resume/again.bin", id, bytes.NewReader(data), int64(len(data))))
+
+		content, ok := mockServer.ObjectContent("resume/again.bin")
+		assert.True(t, ok)
+		assert.Equal(t, data, content)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		id := mockServer.CreateMultipartUpload("resume/stale.bin")
+		u1 := &uploader{Key: key, Bucket: bucket, Object: "resume/stale.bin", MinPartOverride: 1024}
+		assert.NoError(t, u1.Resume(ctx, id))
+		assert.NoError(t, u1.Upload(1, part1))
+		assert.NoError(t, u1.Upload(2, part2))
+
+		// the data only needs one part and a tail, so
+		// the second part uploaded earlier is left out
+		data := slices.Concat(part2[:1024], []byte("tail"))
+		b := NewBucket(key, bucket)
+		b.MinPartOverride = 1024
+		assert.NoError(t, b.ResumeUpload(ctx, "resume/stale.bin", id, bytes.NewReader(data), int64(len(data))))
+
+		content, ok := mockServer.ObjectContent("resume/stale.bin")
+		assert.True(t, ok)
+		assert.Equal(t, data, content)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		u := &uploader{Key: key, Bucket: bucket, Object: "resume/invalid.bin"}
+		err := u.Resume(ctx, "no-such-upload")
+		var s3err *Error
+		if assert.ErrorAs(t, err, &s3err) {
+			assert.Equal(t, "NoSuchUpload", s3err.Code)
+		}
+
+		// the ID of an upload of another key is rejected too
+		id := mockServer.CreateMultipartUpload("resume/other.bin")
+		b := NewBucket(key, bucket)
+		err = b.ResumeUpload(ctx, "resume/invalid.bin", id, bytes.NewReader(part3), int64(len(part3)))
+		assert.ErrorAs(t, err, &s3err)
+		assert.False(t, mockServer.ObjectExists("resume/invalid.bin"))
+	})
+}
+
+func TestUploadSeeker(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	// fail the first attempt at uploading part 2 so that it is re-read
+	var failed atomic.Bool
+	b := NewBucket(key, bucket)
+	b.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPut && req.URL.Query().Get("partNumber") == "2" && failed.CompareAndSwap(false, true) {
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Status:     "400 Bad Request",
+				Body:       io.NopCloser(strings.NewReader("<Error><Message>injected</Message></Error>")),
+				Request:    req,
+			}, nil
+		}
+		return DefaultClient.Transport.RoundTrip(req)
+	})}
+
+	testData := make([]byte, MinPartSize*3+1000)
+	rand.New(rand.NewSource(1)).Read(testData)
+
+	// hide the ReadAt method of *bytes.Reader
+	rs := struct{ io.ReadSeeker }{bytes.NewReader(testData)}
+	etag, err := b.UploadSeeker(context.Background(), "test/seeker.bin", rs, int64(len(testData)))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, etag)
+	assert.True(t, failed.Load())
+
+	content, found := mockServer.ObjectContent("test/seeker.bin")
+	assert.True(t, found)
+	assert.Equal(t, testData, content)
+
+	// a source shorter than size fails the upload
+	short := struct{ io.ReadSeeker }{bytes.NewReader(testData[:MinPartSize])}
+	_, err = b.UploadSeeker(context.Background(), "test/short.bin", short, int64(len(testData)))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestUploadCloseCancel(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	// the final part and the abort hang until their request is cancelled
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if (req.Method == http.MethodPut && req.URL.Query().Has("partNumber")) || req.Method == http.MethodDelete {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return DefaultClient.Transport.RoundTrip(req)
+	})}
+
+	u := &uploader{Key: key, Client: client, Bucket: bucket, Object: "test/cancel.bin"}
+	assert.NoError(t, u.Start(context.Background()))
+
+	t.Run("close", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := u.Close(ctx, []byte("final part"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+		assert.False(t, u.Closed())
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		err := u.Abort(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestUploadBufferReuse(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	testData := make([]byte, 1024*50+10)
+	rand.New(rand.NewSource(1)).Read(testData)
+
+	// 2 workers upload 50 parts of 1KiB
+	pool := newBufferPool(maxParallel)
+	u := &uploader{Key: key, Bucket: bucket, Object: "test/pooled.bin", MinPartOverride: 1024, Mbps: 1600, bufpool: pool}
+	assert.Equal(t, 2, u.idealParallel(50))
+
+	assert.NoError(t, u.Start(context.Background()))
+	assert.NoError(t, u.UploadFrom(context.Background(), bytes.NewReader(testData), int64(len(testData))))
+	assert.LessOrEqual(t, pool.allocs.Load(), int64(2))
+	assert.Equal(t, 51, u.CompletedParts())
+
+	content, found := mockServer.ObjectContent("test/pooled.bin")
+	assert.True(t, found)
+	assert.Equal(t, testData, content)
+}
+
+func TestUploadBufferCap(t *testing.T) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	testData := make([]byte, 1024*30+10)
+	rand.New(rand.NewSource(1)).Read(testData)
+
+	// 4 uploads of 2 workers each share a pool
+	// of 3 buffers, fewer than their workers
+	pool := newBufferPool(3)
+	var g errgroup.Group
+	tees := make([]bytes.Buffer, 4)
+	for i := range tees {
+		u := &uploader{Key: key, Bucket: bucket, Object: fmt.Sprintf("test/capped-%d.bin", i), MinPartOverride: 1024, Mbps: 1600,
+			Options: UploadOptions{Tee: &tees[i]}, bufpool: pool}
+		assert.Equal(t, 2, u.idealParallel(30))
+		g.Go(func() error {
+			if err := u.Start(context.Background()); err != nil {
+				return err
+			}
+			return u.UploadFrom(context.Background(), bytes.NewReader(testData), int64(len(testData)))
+		})
+	}
+	assert.NoError(t, g.Wait())
+	assert.LessOrEqual(t, pool.peak.Load(), int64(3))
+	assert.Zero(t, len(pool.sem))
+
+	for i := range tees {
+		assert.Equal(t, testData, tees[i].Bytes())
+		content, found := mockServer.ObjectContent(fmt.Sprintf("test/capped-%d.bin", i))
+		assert.True(t, found)
+		assert.Equal(t, testData, content)
+	}
+}
+
+func TestUploadBufferLimit(t *testing.T) {
+	// the buffers of an upload are capped by its own
+	// parallelism rather than by a limit shared with
+	// other uploads
+	u := &uploader{Mbps: 100 * 800}
+	parallel := u.idealParallel(1000)
+	assert.Equal(t, 100, parallel)
+	bufs := u.buffers(parallel)
+	assert.Equal(t, parallel, cap(bufs.sem))
+
+	// every worker of each of two uploads
+	// holds a buffer at once
+	other := u.buffers(parallel)
+	assert.NotSame(t, bufs, other)
+	ctx := context.Background()
+	for _, p := range []*bufferPool{bufs, other} {
+		for i := 0; i < parallel; i++ {
+			_, err := p.get(ctx, 16)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int64(parallel), p.peak.Load())
+	}
+}
+
+func BenchmarkUploadFrom(b *testing.B) {
+	bucket := "test-bucket"
+	mockServer := mock.New(bucket, "us-east-1")
+	defer mockServer.Close()
+
+	key := aws.DeriveKey("", "fake-access-key", "fake-secret-key", "us-east-1", "s3")
+	key.BaseURI = mockServer.URL()
+
+	testData := make([]byte, 64*1024*8)
+	b.SetBytes(int64(len(testData)))
+	b.ReportAllocs()
+	for b.Loop() {
+		u := &uploader{Key: key, Bucket: bucket, Object: "bench.bin", MinPartOverride: 64 * 1024, Mbps: 3200}
+		if err := u.Start(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+		if err := u.UploadFrom(context.Background(), bytes.NewReader(testData), int64(len(testData))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}